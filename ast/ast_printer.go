@@ -1,103 +1,909 @@
 package ast
 
 import (
-	"bytes"
-	"fmt"
+	"Glox/token"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// Beautify prints a node as Lox source in the canonical layout: nested
+// blocks are indented by two spaces and every statement ends in a
+// semicolon or a closing brace. Parsing the result gives back the node.
 func Beautify(node interface{}) string {
-	var out bytes.Buffer
+	p := &printer{lineStart: true}
 	switch node := node.(type) {
-	case *BlockStmt:
-		out.WriteString("{\n")
-		if len(node.Statements) > 0 {
-			for _, stmt := range node.Statements {
-				out.WriteString(fmt.Sprintf("%s\n", stmt.String()))
-			}
+	case Statement:
+		p.statement(node)
+	case Expression:
+		p.expression(node)
+	case Pattern:
+		p.pattern(node)
+	}
+	return p.out.String()
+}
+
+// Format prints statements in the layout of Beautify and keeps the
+// comments of the source and the blank lines between its statements.
+// tokens are what a scanner in trivia mode returned for the source.
+func Format(statements []Statement, tokens []token.Token) string {
+	p := &printer{lineStart: true, blockStart: true}
+	for _, tok := range tokens {
+		switch tok.Type {
+		case token.COMMENT:
+			p.comments = append(p.comments, tok)
+		case token.WHITESPACE:
+		default:
+			p.tokens = append(p.tokens, tok)
 		}
-		out.WriteString("\n}")
-	case *ClassStmt:
-		out.WriteString("class ")
-		out.WriteString(node.Name.Lexeme)
+	}
+	for _, stmt := range statements {
+		p.statement(stmt)
+		p.newline()
+	}
+	p.leadingComments()
+	return p.out.String()
+}
+
+// printer writes the source of a tree. When it has the tokens the tree was
+// parsed from, it walks them along with the tree: the output has the same
+// tokens in the same order, apart from trailing commas, so every comment
+// can be written next to the token it was next to.
+type printer struct {
+	out        strings.Builder
+	indent     int
+	lineStart  bool     // nothing has been written on the current line.
+	blockStart bool     // the current line is the first one of a block.
+	trailing   []string // comments to write at the end of the current line.
+
+	tokens   []token.Token
+	next     int // the source token the next output token matches.
+	comments []token.Token
+	comment  int // the next comment to write.
+	line     int // the last source line written.
+}
 
-		if node.Superclass != nil {
-			out.WriteString("< ")
-			out.WriteString(node.Superclass.String())
+func (p *printer) write(s string) {
+	if p.lineStart {
+		p.out.WriteString(strings.Repeat("  ", p.indent))
+		p.lineStart = false
+		p.blockStart = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	for _, comment := range p.trailing {
+		p.write(" " + comment)
+	}
+	p.trailing = nil
+	p.out.WriteString("\n")
+	p.lineStart = true
+}
+
+// token writes a token. With a source, the token is matched with the next
+// source token and the comments before and after that one are written
+// along.
+func (p *printer) token(lexeme string) {
+	if p.tokens != nil {
+		p.sync(lexeme)
+	}
+	p.write(lexeme)
+}
+
+func (p *printer) sync(lexeme string) {
+	src := p.tokens[p.next]
+	if src.Lexeme != lexeme && src.Type == token.COMMA {
+		// A trailing comma that the output leaves out.
+		p.next++
+		src = p.tokens[p.next]
+	}
+	if src.Lexeme != lexeme || src.Type == token.EOF {
+		// A trailing comma that only the output has.
+		return
+	}
+	p.leadingComments()
+	if p.lineStart && lexeme != "}" {
+		p.blankLine(startLine(src))
+	}
+	p.next++
+	p.line = src.Line
+
+	// Comments that follow the token on its line stay at the end of it,
+	// except block comments with more code after them on that line.
+	for p.comment < len(p.comments) {
+		comment := p.comments[p.comment]
+		next := p.tokens[p.next]
+		if startLine(comment) != src.Line || !before(comment, next) {
+			break
+		}
+		if strings.HasPrefix(comment.Lexeme, "/*") && next.Type != token.EOF && startLine(next) == comment.Line {
+			break
 		}
-		out.WriteString(" {\n")
-		if len(node.Methods) > 0 {
-			for _, met := range node.Methods {
-				out.WriteString(met.String())
+		p.trailing = append(p.trailing, comment.Lexeme)
+		p.line = comment.Line
+		p.comment++
+	}
+}
+
+// leadingComments writes the comments before the next source token. At the
+// start of a line they get lines of their own; elsewhere block comments
+// stay in place and line comments move to the end of the line.
+func (p *printer) leadingComments() {
+	for p.comment < len(p.comments) && (p.next >= len(p.tokens) || before(p.comments[p.comment], p.tokens[p.next])) {
+		comment := p.comments[p.comment]
+		switch {
+		case p.lineStart:
+			p.blankLine(startLine(comment))
+			p.write(comment.Lexeme)
+			p.newline()
+		case strings.HasPrefix(comment.Lexeme, "/*") && !strings.Contains(comment.Lexeme, "\n"):
+			// A space sets the comment apart from the code around it, but
+			// not from the brackets or the punctuation next to it.
+			if out := p.out.String(); !strings.ContainsAny(out[len(out)-1:], " ([{") {
+				p.write(" ")
 			}
+			p.write(comment.Lexeme)
+			if p.next < len(p.tokens) && !strings.Contains(")]},;:.", p.tokens[p.next].Lexeme) {
+				p.write(" ")
+			}
+		default:
+			p.trailing = append(p.trailing, comment.Lexeme)
+		}
+		p.line = comment.Line
+		p.comment++
+	}
+}
+
+// blankLine keeps one blank line where the source had at least one before
+// line, except at the start of a block.
+func (p *printer) blankLine(line int) {
+	if p.line > 0 && line-p.line > 1 && !p.blockStart {
+		p.out.WriteString("\n")
+	}
+}
+
+// hasComments reports whether comments come before the next source token.
+func (p *printer) hasComments() bool {
+	return p.comment < len(p.comments) && p.next < len(p.tokens) && before(p.comments[p.comment], p.tokens[p.next])
+}
+
+// nextIs reports whether the next source token has type t.
+func (p *printer) nextIs(t token.TokenType) bool {
+	return p.tokens != nil && p.tokens[p.next].Type == t
+}
+
+// before reports whether comment comes before tok in the source. Tokens
+// are placed at their last character and never overlap comments.
+func before(comment token.Token, tok token.Token) bool {
+	if tok.Type == token.EOF {
+		return true
+	}
+	return comment.Line < tok.Line || comment.Line == tok.Line && comment.Col < tok.Col
+}
+
+// startLine is the line a token starts on. Strings and block comments may
+// span several lines, and Line is the one they end on.
+func startLine(tok token.Token) int {
+	return tok.Line - strings.Count(tok.Lexeme, "\n")
+}
+
+func (p *printer) statements(statements []Statement) {
+	for _, stmt := range statements {
+		p.statement(stmt)
+		p.newline()
+	}
+}
+
+func (p *printer) block(statements []Statement) {
+	p.token("{")
+	if len(statements) == 0 && !p.hasComments() {
+		p.token("}")
+		return
+	}
+	p.indent++
+	p.newline()
+	p.blockStart = true
+	p.statements(statements)
+	p.leadingComments()
+	p.indent--
+	p.token("}")
+}
+
+// isFor reports whether a block is the desugaring of a for loop with an
+// initializer rather than a block written in braces.
+func (p *printer) isFor(block *BlockStmt) bool {
+	if p.tokens != nil {
+		return p.nextIs(token.FOR)
+	}
+	if block.Brace.Lexeme != "" || len(block.Statements) != 2 {
+		return false
+	}
+	loop, ok := block.Statements[1].(*WhileStmt)
+	return ok && p.isForLoop(loop)
+}
+
+// isForLoop reports whether a while statement was written as a for loop.
+func (p *printer) isForLoop(loop *WhileStmt) bool {
+	if p.tokens != nil {
+		return p.nextIs(token.FOR)
+	}
+	literal, ok := loop.Condition.(*Literal)
+	return loop.Increment != nil || ok && literal.Token.Line == 0
+}
+
+// body writes the body of an if, while or for statement after a space.
+func (p *printer) body(stmt Statement) {
+	p.write(" ")
+	p.statement(stmt)
+}
+
+func (p *printer) isBlock(stmt Statement) bool {
+	block, ok := stmt.(*BlockStmt)
+	return ok && !p.isFor(block)
+}
+
+func (p *printer) statement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *BlockStmt:
+		if p.isFor(stmt) {
+			p.forLoop(stmt.Statements[0], stmt.Statements[1].(*WhileStmt))
+		} else {
+			p.block(stmt.Statements)
 		}
-		out.WriteString(" }\n")
+	case *BreakStmt:
+		p.token("break")
+		p.token(";")
+	case *ContinueStmt:
+		p.token("continue")
+		p.token(";")
+	case *ClassStmt:
+		p.class(stmt)
+	case *ExportStmt:
+		p.token("export")
+		p.write(" ")
+		p.statement(stmt.Declaration)
 	case *ExpressionStmt:
-		out.WriteString(node.Expression.String())
+		p.expression(stmt.Expression)
+		p.token(";")
 	case *FunStmt:
-		out.WriteString(fmt.Sprintf("func %s", node.Name.Lexeme))
-		out.WriteString("(")
-		if len(node.Params) > 0 {
-			var pars []string
-			for _, param := range node.Params {
-				pars = append(pars, param.Lexeme)
+		p.token("fun")
+		p.write(" ")
+		p.function(stmt)
+	case *IfStmt:
+		p.token("if")
+		p.write(" ")
+		p.token("(")
+		p.expression(stmt.Condition)
+		p.token(")")
+		thenBlock := p.isBlock(stmt.ThenBranch)
+		p.body(stmt.ThenBranch)
+		if stmt.ElseBranch != nil {
+			if thenBlock {
+				p.write(" ")
+			} else {
+				p.newline()
 			}
-			out.WriteString(fmt.Sprint(strings.Join(pars, ",")))
+			p.token("else")
+			p.body(stmt.ElseBranch)
 		}
-		out.WriteString("){")
-		if node.Body != nil {
-			for _, stmt := range node.Body {
-				out.WriteString(fmt.Sprintf("%s\n", stmt.String()))
+	case *ImportStmt:
+		if stmt.Names != nil {
+			p.token("from")
+			p.write(" ")
+			p.token(stmt.Path.Lexeme)
+			p.write(" ")
+			p.token("import")
+			p.write(" ")
+			for n, name := range stmt.Names {
+				if n > 0 {
+					p.token(",")
+					p.write(" ")
+				}
+				p.token(name.Lexeme)
 			}
+		} else {
+			p.token("import")
+			p.write(" ")
+			p.token(stmt.Path.Lexeme)
+			p.write(" ")
+			p.token("as")
+			p.write(" ")
+			p.token(stmt.Alias.Lexeme)
 		}
-		out.WriteString("\n}")
-	case *IfStmt:
-		out.WriteString(fmt.Sprintf("if (%s) then", node.Condition.String()))
-		out.WriteString(node.ThenBranch.String())
-
-		if node.ElseBranch != nil {
-			out.WriteString("else ")
-			out.WriteString(node.ElseBranch.String())
-		}
+		p.token(";")
+	case *MatchStmt:
+		p.matchStatement(stmt)
 	case *PrintStmt:
-		out.WriteString(fmt.Sprintf("print(%s)", node.Expression.String()))
+		p.token("print")
+		p.write(" ")
+		p.expression(stmt.Expression)
+		p.token(";")
 	case *ReturnStmt:
-		out.WriteString(fmt.Sprintf("return %s", node.Value.String()))
+		p.token("return")
+		if stmt.Value != nil {
+			p.write(" ")
+			p.expression(stmt.Value)
+		}
+		p.token(";")
+	case *ThrowStmt:
+		p.token("throw")
+		p.write(" ")
+		p.expression(stmt.Value)
+		p.token(";")
+	case *TryStmt:
+		p.token("try")
+		p.write(" ")
+		p.block(stmt.Body)
+		if stmt.Catch != nil {
+			p.write(" ")
+			p.token("catch")
+			p.write(" ")
+			p.token("(")
+			p.token(stmt.Param.Lexeme)
+			p.token(")")
+			p.write(" ")
+			p.block(stmt.Catch.Statements)
+		}
+		if stmt.Finally != nil {
+			p.write(" ")
+			p.token("finally")
+			p.write(" ")
+			p.block(stmt.Finally.Statements)
+		}
 	case *VarStmt:
-		out.WriteString(fmt.Sprintf("var %s = %s", node.Name.Lexeme, node.Initializer.String()))
+		if stmt.Const {
+			p.token("const")
+		} else {
+			p.token("var")
+		}
+		p.write(" ")
+		p.token(stmt.Name.Lexeme)
+		p.annotation(stmt.Type)
+		if stmt.Initializer != nil {
+			p.write(" ")
+			p.token("=")
+			p.write(" ")
+			p.expression(stmt.Initializer)
+		}
+		p.token(";")
 	case *WhileStmt:
-		out.WriteString(fmt.Sprintf("while (%s)%s", node.Condition.String(), node.Body.String()))
+		if p.isForLoop(stmt) {
+			p.forLoop(nil, stmt)
+			return
+		}
+		p.token("while")
+		p.write(" ")
+		p.token("(")
+		p.expression(stmt.Condition)
+		p.token(")")
+		p.body(stmt.Body)
+	}
+}
+
+// forLoop writes a while statement as the for loop it was parsed from.
+// The condition is left out when the parser supplied it.
+func (p *printer) forLoop(initializer Statement, loop *WhileStmt) {
+	p.token("for")
+	p.write(" ")
+	p.token("(")
+	if initializer != nil {
+		p.statement(initializer)
+	} else {
+		p.token(";")
+	}
+	if literal, ok := loop.Condition.(*Literal); !ok || literal.Token.Lexeme != "" {
+		p.write(" ")
+		p.expression(loop.Condition)
+	}
+	p.token(";")
+	if loop.Increment != nil {
+		p.write(" ")
+		p.expression(loop.Increment)
+	}
+	p.token(")")
+	p.body(loop.Body)
+}
+
+func (p *printer) class(stmt *ClassStmt) {
+	p.token("class")
+	p.write(" ")
+	p.token(stmt.Name.Lexeme)
+	if stmt.Superclass != nil {
+		p.write(" ")
+		p.token("<")
+		p.write(" ")
+		p.token(stmt.Superclass.Name.Lexeme)
+	}
+	p.write(" ")
+
+	// Fields and methods are kept apart in the tree; put them back in
+	// source order.
+	type member struct {
+		name  token.Token
+		field *Field
+		fun   *FunStmt
+	}
+	var members []member
+	for _, field := range stmt.Fields {
+		members = append(members, member{name: field.Name, field: field})
+	}
+	for _, method := range stmt.Methods {
+		fun := method.(*FunStmt)
+		members = append(members, member{name: fun.Name, fun: fun})
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i].name, members[j].name
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+
+	p.token("{")
+	if len(members) == 0 && !p.hasComments() {
+		p.token("}")
+		return
+	}
+	p.indent++
+	p.newline()
+	p.blockStart = true
+	for _, m := range members {
+		if m.field != nil {
+			p.token(m.field.Name.Lexeme)
+			p.annotation(m.field.Type)
+			p.token(";")
+		} else {
+			switch m.fun.Kind {
+			case StaticMethod:
+				p.token("static")
+				p.write(" ")
+			case Setter:
+				p.token("set")
+				p.write(" ")
+			}
+			p.function(m.fun)
+		}
+		p.newline()
+	}
+	p.leadingComments()
+	p.indent--
+	p.token("}")
+}
+
+// function writes a function declaration or a method from its name on.
+// Getters have no parameter list.
+func (p *printer) function(stmt *FunStmt) {
+	p.token(stmt.Name.Lexeme)
+	if stmt.Kind != Getter {
+		p.parameters(stmt.Params, stmt.ParamTypes)
+	}
+	p.annotation(stmt.ReturnType)
+	p.write(" ")
+	p.block(stmt.Body)
+}
+
+func (p *printer) parameters(params []token.Token, types []*TypeAnnotation) {
+	p.token("(")
+	for n, param := range params {
+		if n > 0 {
+			p.token(",")
+			p.write(" ")
+		}
+		p.token(param.Lexeme)
+		if n < len(types) {
+			p.annotation(types[n])
+		}
+	}
+	p.token(")")
+}
+
+// annotation writes ': Type' when there is an annotation.
+func (p *printer) annotation(t *TypeAnnotation) {
+	if t == nil {
+		return
+	}
+	p.token(":")
+	p.write(" ")
+	p.typeAnnotation(t)
+}
+
+func (p *printer) typeAnnotation(t *TypeAnnotation) {
+	p.token(t.Name.Lexeme)
+	if len(t.Arguments) == 0 {
+		return
+	}
+	p.token("<")
+	for n, argument := range t.Arguments {
+		if n > 0 {
+			p.token(",")
+			p.write(" ")
+		}
+		p.typeAnnotation(argument)
+	}
+	p.token(">")
+}
+
+func (p *printer) matchStatement(stmt *MatchStmt) {
+	p.token("match")
+	p.write(" ")
+	p.token("(")
+	p.expression(stmt.Subject)
+	p.token(")")
+	p.write(" ")
+	p.token("{")
+	p.indent++
+	p.newline()
+	p.blockStart = true
+	for _, c := range stmt.Cases {
+		p.token("case")
+		p.write(" ")
+		for n, pattern := range c.Patterns {
+			if n > 0 {
+				p.token(",")
+				p.write(" ")
+			}
+			p.pattern(pattern)
+		}
+		if c.Guard != nil {
+			p.write(" ")
+			p.token("if")
+			p.write(" ")
+			p.expression(c.Guard)
+		}
+		p.write(" ")
+		p.token("=>")
+		p.body(c.Body)
+		p.newline()
+	}
+	if stmt.Default != nil {
+		p.token("default")
+		p.write(" ")
+		p.token("=>")
+		p.body(stmt.Default)
+		p.newline()
+	}
+	p.leadingComments()
+	p.indent--
+	p.token("}")
+}
+
+func (p *printer) pattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *LiteralPattern:
+		p.expression(pattern.Value)
+	case *BindingPattern:
+		p.token(pattern.Name.Lexeme)
+	case *ListPattern:
+		p.token("[")
+		for n, element := range pattern.Elements {
+			if n > 0 {
+				p.token(",")
+				p.write(" ")
+			}
+			p.pattern(element)
+		}
+		p.token("]")
+	case *InstancePattern:
+		p.token(pattern.Class.Name.Lexeme)
+		p.token("{")
+		for n, field := range pattern.Fields {
+			if n > 0 {
+				p.token(",")
+				p.write(" ")
+			}
+			p.token(field.Lexeme)
+			// A bare field name binds the property under its own name and
+			// is parsed into a binding on the same token.
+			if binding, ok := pattern.Patterns[n].(*BindingPattern); !ok || binding.Name != field {
+				p.token(":")
+				p.write(" ")
+				p.pattern(pattern.Patterns[n])
+			}
+		}
+		p.token("}")
+	}
+}
+
+func (p *printer) expression(expr Expression) {
+	switch expr := expr.(type) {
 	case *Assign:
-		out.WriteString(fmt.Sprintf("var %s = %s", node.Name.Lexeme, node.Value.String()))
-	case *Variable:
-		out.WriteString(node.Name.Lexeme)
-	case *Unary:
-		out.WriteString(fmt.Sprintf("(%s %s)", node.Operator.Lexeme, node.Right.String()))
+		p.token(expr.Name.Lexeme)
+		p.operator("=")
+		p.expression(expr.Value)
 	case *Binary:
-		out.WriteString(fmt.Sprintf("(%s %s %s)", node.Left.String(), node.Operator.Lexeme, node.Right.String()))
+		p.expression(expr.Left)
+		p.operator(expr.Operator.Lexeme)
+		p.expression(expr.Right)
 	case *Call:
-		out.WriteString(node.Callee.String())
-		if len(node.Arguments) > 0 {
-			var args []string
-			for _, arg := range node.Arguments {
-				out.WriteString(arg.String())
+		p.expression(expr.Callee)
+		p.token("(")
+		for n, argument := range expr.Arguments {
+			if n > 0 {
+				p.token(",")
+				p.write(" ")
 			}
-			out.WriteString(strings.Join(args, ","))
+			p.expression(argument)
 		}
+		p.token(")")
+	case *Comma:
+		p.expression(expr.Left)
+		p.token(",")
+		p.write(" ")
+		p.expression(expr.Right)
+	case *CompoundAssign:
+		p.expression(expr.Target)
+		p.operator(expr.Operator.Lexeme)
+		p.expression(expr.Value)
+	case *Conditional:
+		p.expression(expr.Condition)
+		p.operator("?")
+		p.expression(expr.Then)
+		p.operator(":")
+		p.expression(expr.Else)
 	case *Get:
-		out.WriteString(fmt.Sprintf("get object: %s name: %s>", node.Object.String(), node.Name.Lexeme))
-	case *Set:
-		out.WriteString(fmt.Sprintf("set object: %s name: %s value: %s", node.Object.String(), node.Name.Lexeme, node.Value.String()))
+		p.expression(expr.Object)
+		p.token(".")
+		p.token(expr.Name.Lexeme)
 	case *Grouping:
-		out.WriteString(node.Expression.String())
+		p.token("(")
+		p.expression(expr.Expression)
+		p.token(")")
+	case *Increment:
+		if expr.Prefix {
+			p.token(expr.Operator.Lexeme)
+			p.expression(expr.Target)
+		} else {
+			p.expression(expr.Target)
+			p.token(expr.Operator.Lexeme)
+		}
+	case *Index:
+		p.expression(expr.Object)
+		p.token("[")
+		p.expression(expr.Index)
+		p.token("]")
+	case *Interpolation:
+		p.interpolation(expr)
+	case *Lambda:
+		p.lambda(expr)
+	case *List:
+		p.elements("[", "]", expr.Bracket, len(expr.Elements), func(n int) {
+			p.expression(expr.Elements[n])
+		})
 	case *Literal:
-		out.WriteString(fmt.Sprintf("%v", node.Value))
+		p.literal(expr)
 	case *Logical:
-		out.WriteString(fmt.Sprintf("(%s %s %s)", node.Left.String(), node.Operator.Lexeme, node.Right.String()))
+		p.expression(expr.Left)
+		p.operator(expr.Operator.Lexeme)
+		p.expression(expr.Right)
+	case *Map:
+		p.elements("{", "}", expr.Brace, len(expr.Keys), func(n int) {
+			p.expression(expr.Keys[n])
+			p.token(":")
+			p.write(" ")
+			p.expression(expr.Values[n])
+		})
+	case *Set:
+		p.expression(expr.Object)
+		p.token(".")
+		p.token(expr.Name.Lexeme)
+		p.operator("=")
+		p.expression(expr.Value)
+	case *SetIndex:
+		p.expression(expr.Object)
+		p.token("[")
+		p.expression(expr.Index)
+		p.token("]")
+		p.operator("=")
+		p.expression(expr.Value)
+	case *Slice:
+		p.expression(expr.Object)
+		p.token("[")
+		if expr.Start != nil {
+			p.expression(expr.Start)
+		}
+		p.token(":")
+		if expr.End != nil {
+			p.expression(expr.End)
+		}
+		p.token("]")
 	case *Super:
-		out.WriteString(fmt.Sprintf("super<kw: %s, met: %s>", node.Keyword.Lexeme, node.Method.Lexeme))
+		p.token("super")
+		p.token(".")
+		p.token(expr.Method.Lexeme)
 	case *This:
-		out.WriteString(node.Keyword.Lexeme)
+		p.token("this")
+	case *Unary:
+		p.token(expr.Operator.Lexeme)
+		// - -x must not run together into the -- operator.
+		if expr.Operator.Type == token.MINUS && startsWithMinus(expr.Right) {
+			p.write(" ")
+		}
+		p.expression(expr.Right)
+	case *Variable:
+		p.token(expr.Name.Lexeme)
+	}
+}
+
+// operator writes a binary operator with a space on either side.
+func (p *printer) operator(lexeme string) {
+	p.write(" ")
+	p.token(lexeme)
+	p.write(" ")
+}
+
+func startsWithMinus(expr Expression) bool {
+	switch expr := expr.(type) {
+	case *Unary:
+		return expr.Operator.Type == token.MINUS
+	case *Increment:
+		return expr.Prefix && expr.Operator.Type == token.MINUS_MINUS
+	}
+	return false
+}
+
+// elements writes the items of a list or map literal on one line, or one
+// per line with a trailing comma when the source put the first item on a
+// line of its own or has line comments between the brackets.
+func (p *printer) elements(open string, close string, bracket token.Token, count int, item func(n int)) {
+	multiline := false
+	if p.tokens != nil && count > 0 {
+		multiline = startLine(p.tokens[p.next+1]) > bracket.Line
+		end := p.tokens[p.closing(p.next)]
+		for n := p.comment; n < len(p.comments) && before(p.comments[n], end); n++ {
+			multiline = multiline || strings.HasPrefix(p.comments[n].Lexeme, "//")
+		}
+	}
+	p.token(open)
+	if !multiline {
+		for n := 0; n < count; n++ {
+			if n > 0 {
+				p.token(",")
+				p.write(" ")
+			}
+			item(n)
+		}
+		p.token(close)
+		return
+	}
+	p.indent++
+	p.newline()
+	p.blockStart = true
+	for n := 0; n < count; n++ {
+		item(n)
+		p.token(",")
+		p.newline()
+	}
+	p.leadingComments()
+	p.indent--
+	p.token(close)
+}
+
+// closing finds the bracket that closes the one at source token n.
+func (p *printer) closing(n int) int {
+	depth := 0
+	for ; n < len(p.tokens)-1; n++ {
+		switch p.tokens[n].Type {
+		case token.LEFT_PAREN, token.LEFT_BRACE, token.LEFT_BRACKET:
+			depth++
+		case token.RIGHT_PAREN, token.RIGHT_BRACE, token.RIGHT_BRACKET:
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	return n
+}
+
+func (p *printer) lambda(expr *Lambda) {
+	if expr.Arrow {
+		p.parameters(expr.Params, expr.ParamTypes)
+		p.annotation(expr.ReturnType)
+		p.operator("=>")
+		p.expression(expr.Body[0].(*ReturnStmt).Value)
+		return
+	}
+	p.token("fun")
+	p.write(" ")
+	p.parameters(expr.Params, expr.ParamTypes)
+	p.annotation(expr.ReturnType)
+	p.write(" ")
+	p.block(expr.Body)
+}
+
+func (p *printer) literal(expr *Literal) {
+	if expr.Token.Lexeme != "" {
+		p.token(expr.Token.Lexeme)
+		return
+	}
+	switch value := expr.Value.(type) {
+	case nil:
+		p.token("nil")
+	case bool:
+		p.token(strconv.FormatBool(value))
+	case int64:
+		p.token(strconv.FormatInt(value, 10))
+	case float64:
+		text := strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eEn") {
+			text += ".0"
+		}
+		p.token(text)
+	case string:
+		p.token(`"` + escape(value) + `"`)
+	}
+}
+
+// interpolation writes an interpolated string. The segments between the
+// embedded expressions are written as they were in the source, delimiters
+// included, so they match the scanner's INTERPOLATION tokens; the parser
+// drops empty segments.
+func (p *printer) interpolation(expr *Interpolation) {
+	open, text := `"`, ""
+	for _, part := range expr.Parts {
+		if literal, ok := part.(*Literal); ok && isSegment(literal) {
+			text = segmentText(literal)
+			continue
+		}
+		p.token(open + text + "${")
+		p.expression(part)
+		open, text = "}", ""
+	}
+	p.token(open + text + `"`)
+}
+
+// isSegment reports whether a part of an interpolation is the text between
+// embedded expressions rather than an embedded string literal.
+func isSegment(literal *Literal) bool {
+	switch literal.Token.Type {
+	case token.INTERPOLATION:
+		return true
+	case token.STRING:
+		return strings.HasPrefix(literal.Token.Lexeme, "}")
+	}
+	_, ok := literal.Value.(string)
+	return ok && literal.Token.Lexeme == ""
+}
+
+// segmentText is the source of a segment without its delimiters.
+func segmentText(literal *Literal) string {
+	lexeme := literal.Token.Lexeme
+	if lexeme == "" {
+		return escape(literal.Value.(string))
+	}
+	lexeme = lexeme[1:]
+	if strings.HasSuffix(lexeme, "${") {
+		return strings.TrimSuffix(lexeme, "${")
+	}
+	return strings.TrimSuffix(lexeme, `"`)
+}
+
+// escape writes a string value as the inside of a string literal.
+func escape(value string) string {
+	var out strings.Builder
+	for n, c := range value {
+		switch c {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '$':
+			if strings.HasPrefix(value[n:], "${") {
+				out.WriteString(`\$`)
+			} else {
+				out.WriteRune(c)
+			}
+		default:
+			if c < ' ' {
+				out.WriteString(`\u{` + strconv.FormatInt(int64(c), 16) + `}`)
+			} else {
+				out.WriteRune(c)
+			}
+		}
 	}
 	return out.String()
 }
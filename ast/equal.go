@@ -0,0 +1,53 @@
+package ast
+
+import (
+	"Glox/token"
+	"reflect"
+)
+
+// Equal reports whether two trees are the same apart from where their
+// tokens are in the source.
+func Equal(a interface{}, b interface{}) bool {
+	return equal(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func equal(a reflect.Value, b reflect.Value) bool {
+	if a.IsValid() != b.IsValid() {
+		return false
+	}
+	if !a.IsValid() {
+		return true
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equal(a.Elem(), b.Elem())
+	case reflect.Slice:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		for n := 0; n < a.Len(); n++ {
+			if !equal(a.Index(n), b.Index(n)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if a.Type() == tokenType {
+			x, y := a.Interface().(token.Token), b.Interface().(token.Token)
+			return x.Type == y.Type && x.Lexeme == y.Lexeme && x.Literal == y.Literal
+		}
+		for n := 0; n < a.NumField(); n++ {
+			if !equal(a.Field(n), b.Field(n)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Interface() == b.Interface()
+}
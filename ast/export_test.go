@@ -0,0 +1,4 @@
+package ast
+
+// NodeTypes lets the tests in ast_test check that they cover every node.
+var NodeTypes = nodeTypes
@@ -8,11 +8,22 @@ type ExprVisitor interface {
 	VisitAssignExpr(expr *Assign) interface{}
 	VisitBinaryExpr(expr *Binary) interface{}
 	VisitCallExpr(expr *Call) interface{}
+	VisitCommaExpr(expr *Comma) interface{}
+	VisitCompoundAssignExpr(expr *CompoundAssign) interface{}
+	VisitConditionalExpr(expr *Conditional) interface{}
 	VisitGetExpr(expr *Get) interface{}
 	VisitGroupingExpr(expr *Grouping) interface{}
+	VisitIncrementExpr(expr *Increment) interface{}
+	VisitIndexExpr(expr *Index) interface{}
+	VisitInterpolationExpr(expr *Interpolation) interface{}
+	VisitLambdaExpr(expr *Lambda) interface{}
+	VisitListExpr(expr *List) interface{}
 	VisitLiteralExpr(expr *Literal) interface{}
 	VisitLogicalExpr(expr *Logical) interface{}
+	VisitMapExpr(expr *Map) interface{}
 	VisitSetExpr(expr *Set) interface{}
+	VisitSetIndexExpr(expr *SetIndex) interface{}
+	VisitSliceExpr(expr *Slice) interface{}
 	VisitSuperExpr(expr *Super) interface{}
 	VisitThisExpr(expr *This) interface{}
 	VisitUnaryExpr(expr *Unary) interface{}
@@ -36,6 +47,36 @@ func (expr *Assign) String() string {
 	return Beautify(expr)
 }
 
+// CompoundAssign is Target op= Value. Target is a Variable, Get or Index
+// expression whose object and index are evaluated only once.
+type CompoundAssign struct {
+	Target   Expression
+	Operator token.Token
+	Value    Expression
+}
+
+func (expr *CompoundAssign) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitCompoundAssignExpr(expr)
+}
+func (expr *CompoundAssign) String() string {
+	return Beautify(expr)
+}
+
+// Increment is ++Target, --Target, Target++ or Target--. The prefix forms
+// yield the updated value and the postfix forms the original one.
+type Increment struct {
+	Target   Expression
+	Operator token.Token
+	Prefix   bool
+}
+
+func (expr *Increment) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitIncrementExpr(expr)
+}
+func (expr *Increment) String() string {
+	return Beautify(expr)
+}
+
 type Variable struct {
 	Name token.Token
 }
@@ -85,6 +126,35 @@ func (expr *Call) String() string {
 	return Beautify(expr)
 }
 
+// Comma evaluates Left, discards its value and yields Right.
+type Comma struct {
+	Left     Expression
+	Operator token.Token
+	Right    Expression
+}
+
+func (expr *Comma) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitCommaExpr(expr)
+}
+func (expr *Comma) String() string {
+	return Beautify(expr)
+}
+
+// Conditional is the ternary Condition ? Then : Else.
+type Conditional struct {
+	Condition Expression
+	Question  token.Token
+	Then      Expression
+	Else      Expression
+}
+
+func (expr *Conditional) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitConditionalExpr(expr)
+}
+func (expr *Conditional) String() string {
+	return Beautify(expr)
+}
+
 type Get struct {
 	Object Expression
 	Name   token.Token
@@ -110,6 +180,48 @@ func (expr *Set) String() string {
 	return Beautify(expr)
 }
 
+type Index struct {
+	Object  Expression
+	Bracket token.Token
+	Index   Expression
+}
+
+func (expr *Index) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitIndexExpr(expr)
+}
+func (expr *Index) String() string {
+	return Beautify(expr)
+}
+
+type SetIndex struct {
+	Object  Expression
+	Bracket token.Token
+	Index   Expression
+	Value   Expression
+}
+
+func (expr *SetIndex) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSetIndexExpr(expr)
+}
+func (expr *SetIndex) String() string {
+	return Beautify(expr)
+}
+
+// Slice is object[Start:End]. Either bound may be nil when omitted.
+type Slice struct {
+	Object  Expression
+	Bracket token.Token
+	Start   Expression
+	End     Expression
+}
+
+func (expr *Slice) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitSliceExpr(expr)
+}
+func (expr *Slice) String() string {
+	return Beautify(expr)
+}
+
 type Grouping struct {
 	Expression Expression
 }
@@ -121,7 +233,56 @@ func (expr *Grouping) String() string {
 	return Beautify(expr)
 }
 
+// Interpolation is a string with embedded expressions. Parts holds the
+// literal segments and the embedded expressions in source order.
+type Interpolation struct {
+	Parts []Expression
+}
+
+func (expr *Interpolation) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitInterpolationExpr(expr)
+}
+func (expr *Interpolation) String() string {
+	return Beautify(expr)
+}
+
+// Lambda is an anonymous function, fun (Params) { Body }. The arrow form
+// (Params) => expression sets Arrow and its Body is a single return
+// statement. Name is the variable or property the lambda was assigned to
+// when the parser could tell, and is empty otherwise.
+type Lambda struct {
+	Keyword    token.Token
+	Name       token.Token
+	Params     []token.Token
+	ParamTypes []*TypeAnnotation // one per parameter, nil where unannotated.
+	ReturnType *TypeAnnotation
+	Body       []Statement
+	Arrow      bool
+}
+
+func (expr *Lambda) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitLambdaExpr(expr)
+}
+func (expr *Lambda) String() string {
+	return Beautify(expr)
+}
+
+type List struct {
+	Bracket  token.Token
+	Elements []Expression
+}
+
+func (expr *List) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitListExpr(expr)
+}
+func (expr *List) String() string {
+	return Beautify(expr)
+}
+
+// Literal is a constant value. Token is the literal's source token, and is
+// the zero token for literals the parser synthesizes.
 type Literal struct {
+	Token token.Token
 	Value interface{}
 }
 
@@ -145,6 +306,20 @@ func (expr *Logical) String() string {
 	return Beautify(expr)
 }
 
+// Map is a map literal. Keys[n] is associated with Values[n].
+type Map struct {
+	Brace  token.Token
+	Keys   []Expression
+	Values []Expression
+}
+
+func (expr *Map) Accept(visitor ExprVisitor) interface{} {
+	return visitor.VisitMapExpr(expr)
+}
+func (expr *Map) String() string {
+	return Beautify(expr)
+}
+
 type Super struct {
 	Keyword token.Token
 	Method  token.Token
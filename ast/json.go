@@ -0,0 +1,370 @@
+package ast
+
+import (
+	"Glox/token"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The JSON form of a tree mirrors the Go structs. A node is an object with
+// its type name under "node", the position of its first token under "pos",
+// and its fields under their Go names:
+//
+//	{"node": "Binary", "pos": {"line": 1, "col": 1},
+//	 "Left": {...}, "Operator": {...}, "Right": {...}}
+//
+// A token is an object with its "type" name, "lexeme", "line" and "col",
+// and its decoded "literal" for literal tokens. Literal values are JSON
+// strings, booleans, null and numbers, where floats always have a fraction
+// or an exponent so they don't read back as integers. A nil slice is null
+// and an empty one is [], since the parser tells them apart. "pos" is
+// derived from the tokens, so FromJSON ignores it.
+
+// nodeTypes are the structs that may appear in a tree, by name.
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []interface{}{
+		// Statements.
+		&BlockStmt{}, &BreakStmt{}, &ClassStmt{}, &ContinueStmt{}, &ExportStmt{},
+		&ExpressionStmt{}, &FunStmt{}, &IfStmt{}, &ImportStmt{}, &MatchStmt{},
+		&PrintStmt{}, &ReturnStmt{}, &ThrowStmt{}, &TryStmt{}, &VarStmt{}, &WhileStmt{},
+		// Expressions.
+		&Assign{}, &Binary{}, &Call{}, &Comma{}, &CompoundAssign{}, &Conditional{},
+		&Get{}, &Grouping{}, &Increment{}, &Index{}, &Interpolation{}, &Lambda{},
+		&List{}, &Literal{}, &Logical{}, &Map{}, &Set{}, &SetIndex{}, &Slice{},
+		&Super{}, &This{}, &Unary{}, &Variable{},
+		// Patterns.
+		&LiteralPattern{}, &BindingPattern{}, &ListPattern{}, &InstancePattern{},
+		// Parts of other nodes.
+		&MatchCase{}, &Field{}, &TypeAnnotation{},
+	} {
+		t := reflect.TypeOf(node).Elem()
+		nodeTypes[t.Name()] = t
+	}
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// optionalFields are the node fields, as Type.Field, that may be null
+// because the source can leave them out. For a slice, its elements may be
+// null. Any other node field, or element of a slice of nodes, must be a
+// node.
+var optionalFields = map[string]bool{
+	"ClassStmt.Superclass": true,
+	"Field.Type":           true,
+	"FunStmt.ParamTypes":   true,
+	"FunStmt.ReturnType":   true,
+	"IfStmt.ElseBranch":    true,
+	"Lambda.ParamTypes":    true,
+	"Lambda.ReturnType":    true,
+	"MatchCase.Guard":      true,
+	"MatchStmt.Default":    true,
+	"ReturnStmt.Value":     true,
+	"Slice.Start":          true,
+	"Slice.End":            true,
+	"TryStmt.Catch":        true,
+	"TryStmt.Finally":      true,
+	"VarStmt.Type":         true,
+	"VarStmt.Initializer":  true,
+	"WhileStmt.Increment":  true,
+}
+
+// ToJSON encodes statements as an indented JSON array of nodes.
+func ToJSON(statements []Statement) ([]byte, error) {
+	compact, err := json.Marshal(encodeJSON(reflect.ValueOf(statements)))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// FromJSON rebuilds the statements ToJSON encoded.
+func FromJSON(data []byte) (statements []Statement, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			jsonErr, ok := r.(jsonError)
+			if !ok {
+				panic(r)
+			}
+			statements, err = nil, jsonErr
+		}
+	}()
+	decodeJSON(value, reflect.ValueOf(&statements).Elem(), "$", false)
+	return statements, nil
+}
+
+// object is a JSON object that keeps its keys in order.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for n, m := range o {
+		if n > 0 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// float is a float64 that encodes with a fraction or an exponent.
+type float float64
+
+func (f float) MarshalJSON() ([]byte, error) {
+	text := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eEn") {
+		text += ".0"
+	}
+	return []byte(text), nil
+}
+
+func encodeJSON(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			return encodeNode(v)
+		}
+		return encodeJSON(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		elements := make([]interface{}, v.Len())
+		for n := range elements {
+			elements[n] = encodeJSON(v.Index(n))
+		}
+		return elements
+	case reflect.Struct:
+		return encodeToken(v.Interface().(token.Token))
+	case reflect.Float64:
+		return float(v.Float())
+	default:
+		// Strings, booleans, integers and FunctionKind.
+		return v.Interface()
+	}
+}
+
+func encodeNode(v reflect.Value) object {
+	t := v.Elem().Type()
+	node := object{{"node", t.Name()}}
+	if first, ok := firstToken(v); ok {
+		node = append(node, member{"pos", object{{"line", first.Line}, {"col", first.Col}}})
+	}
+	for n := 0; n < t.NumField(); n++ {
+		node = append(node, member{t.Field(n).Name, encodeJSON(v.Elem().Field(n))})
+	}
+	return node
+}
+
+func encodeToken(tok token.Token) object {
+	encoded := object{{"type", tok.Type.String()}, {"lexeme", tok.Lexeme}}
+	if tok.Literal != nil {
+		encoded = append(encoded, member{"literal", encodeJSON(reflect.ValueOf(tok.Literal))})
+	}
+	return append(encoded, member{"line", tok.Line}, member{"col", tok.Col})
+}
+
+// FirstToken returns the first token of a node in field order that comes
+// from the source, which locates the node in messages. It returns false
+// for nodes made of synthesized tokens only.
+func FirstToken(node interface{}) (token.Token, bool) {
+	return firstToken(reflect.ValueOf(node))
+}
+
+// firstToken finds the first token of a node in field order that comes
+// from the source. Synthesized tokens are zero.
+func firstToken(v reflect.Value) (token.Token, bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			return firstToken(v.Elem())
+		}
+	case reflect.Slice:
+		for n := 0; n < v.Len(); n++ {
+			if tok, ok := firstToken(v.Index(n)); ok {
+				return tok, true
+			}
+		}
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			tok := v.Interface().(token.Token)
+			return tok, tok.Line > 0
+		}
+		for n := 0; n < v.NumField(); n++ {
+			if tok, ok := firstToken(v.Field(n)); ok {
+				return tok, true
+			}
+		}
+	}
+	return token.Token{}, false
+}
+
+type jsonError struct {
+	path    string
+	message string
+}
+
+func (e jsonError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.message)
+}
+
+func failJSON(path string, format string, args ...interface{}) {
+	panic(jsonError{path, fmt.Sprintf(format, args...)})
+}
+
+// decodeJSON stores value, decoded with UseNumber, in v. path locates
+// value in the document for error messages. optional allows a null node,
+// or null elements in a slice of nodes.
+func decodeJSON(value interface{}, v reflect.Value, path string, optional bool) {
+	t := v.Type()
+	switch {
+	case t == tokenType:
+		v.Set(reflect.ValueOf(decodeToken(value, path)))
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		if literal := decodeLiteral(value, path); literal != nil {
+			v.Set(reflect.ValueOf(literal))
+		}
+	case t.Kind() == reflect.Interface, t.Kind() == reflect.Ptr:
+		if value == nil {
+			if !optional {
+				failJSON(path, "expected a node")
+			}
+			return
+		}
+		v.Set(decodeNode(value, t, path))
+	case t.Kind() == reflect.Slice:
+		if value == nil {
+			return
+		}
+		elements, ok := value.([]interface{})
+		if !ok {
+			failJSON(path, "expected an array")
+		}
+		slice := reflect.MakeSlice(t, len(elements), len(elements))
+		for n, element := range elements {
+			decodeJSON(element, slice.Index(n), fmt.Sprintf("%s[%d]", path, n), optional)
+		}
+		v.Set(slice)
+	case t.Kind() == reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			failJSON(path, "expected a boolean")
+		}
+		v.SetBool(b)
+	case t.Kind() == reflect.Int:
+		v.SetInt(int64(decodeInt(value, path)))
+	default:
+		failJSON(path, "can't decode %s", t)
+	}
+}
+
+// decodeNode decodes a node that can be stored in a field of type want.
+func decodeNode(value interface{}, want reflect.Type, path string) reflect.Value {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		failJSON(path, "expected a node")
+	}
+	name, _ := fields["node"].(string)
+	t, ok := nodeTypes[name]
+	if !ok {
+		failJSON(path, "unknown node %q", name)
+	}
+	if !reflect.PtrTo(t).AssignableTo(want) {
+		failJSON(path, "%s is not a %s", name, strings.TrimPrefix(want.String(), "*"))
+	}
+	node := reflect.New(t)
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		decodeJSON(fields[field.Name], node.Elem().Field(n), path+"."+field.Name, optionalFields[t.Name()+"."+field.Name])
+	}
+	return node
+}
+
+func decodeToken(value interface{}, path string) token.Token {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		failJSON(path, "expected a token")
+	}
+	name, _ := fields["type"].(string)
+	tokenType, ok := token.TypeNamed(name)
+	if !ok {
+		failJSON(path, "unknown token type %q", name)
+	}
+	lexeme, ok := fields["lexeme"].(string)
+	if !ok {
+		failJSON(path, "expected a lexeme")
+	}
+	return token.Token{
+		Type:    tokenType,
+		Lexeme:  lexeme,
+		Literal: decodeLiteral(fields["literal"], path+".literal"),
+		Line:    decodeInt(fields["line"], path+".line"),
+		Col:     decodeInt(fields["col"], path+".col"),
+	}
+}
+
+func decodeLiteral(value interface{}, path string) interface{} {
+	switch value := value.(type) {
+	case nil, bool, string:
+		return value
+	case json.Number:
+		if strings.ContainsAny(string(value), ".eE") {
+			f, err := value.Float64()
+			if err != nil {
+				failJSON(path, "invalid number %s", value)
+			}
+			return f
+		}
+		n, err := value.Int64()
+		if err != nil {
+			failJSON(path, "invalid integer %s", value)
+		}
+		return n
+	}
+	failJSON(path, "expected a literal value")
+	return nil
+}
+
+func decodeInt(value interface{}, path string) int {
+	number, ok := value.(json.Number)
+	if !ok {
+		failJSON(path, "expected an integer")
+	}
+	n, err := strconv.Atoi(string(number))
+	if err != nil {
+		failJSON(path, "invalid integer %s", number)
+	}
+	return n
+}
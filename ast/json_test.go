@@ -0,0 +1,149 @@
+package ast_test
+
+import (
+	"Glox/ast"
+	"Glox/parser"
+	"Glox/scanner"
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parse parses source, failing the test on syntax errors. The ast package
+// can't import the parser, so these tests live in ast_test.
+func parse(t *testing.T, source string) []ast.Statement {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	if errors := append(s.Errors(), p.Errors()...); len(errors) > 0 {
+		t.Fatalf("parsing %q: %s", source, strings.Join(errors, "; "))
+	}
+	return statements
+}
+
+// corpus returns scripts that together use every kind of node.
+func corpus(t *testing.T) map[string]string {
+	t.Helper()
+	nodes, err := os.ReadFile("testdata/nodes.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"nodes.lox": string(nodes),
+		"literals":  `print [1, 1.0, 1e3, 0x1f, -0.5, "tab\t\"q\"", true, false, nil, "${1}${"a"}"];`,
+		"empty":     `fun f() {} class C {} print [];`,
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for name, source := range corpus(t) {
+		statements := parse(t, source)
+		data, err := ast.ToJSON(statements)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		decoded, err := ast.FromJSON(data)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		// The tree comes back exactly, positions and literals included.
+		if !reflect.DeepEqual(decoded, statements) {
+			t.Errorf("%s: decoded tree differs from the parsed one", name)
+		}
+		again, err := ast.ToJSON(decoded)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("%s: encoding the decoded tree gives different JSON", name)
+		}
+	}
+}
+
+func TestJSONCoversEveryNode(t *testing.T) {
+	seen := map[string]bool{}
+	for _, source := range corpus(t) {
+		data, err := ast.ToJSON(parse(t, source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatal(err)
+		}
+		collectNodes(document, seen)
+	}
+	var missing []string
+	for name := range ast.NodeTypes {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("the corpus uses no %s", strings.Join(missing, ", "))
+	}
+}
+
+// collectNodes records the names of the nodes in a decoded JSON document.
+func collectNodes(value interface{}, seen map[string]bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if name, ok := value["node"].(string); ok {
+			seen[name] = true
+		}
+		for _, field := range value {
+			collectNodes(field, seen)
+		}
+	case []interface{}:
+		for _, element := range value {
+			collectNodes(element, seen)
+		}
+	}
+}
+
+func TestJSONLiterals(t *testing.T) {
+	data, err := ast.ToJSON(parse(t, `print [1, 2.0];`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Floats keep a fraction so they don't read back as integers.
+	for _, want := range []string{`"literal": 1,`, `"literal": 2.0,`} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("got JSON without %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`{}`, "$: expected an array"},
+		{`[{"node": "Nope"}]`, `$[0]: unknown node "Nope"`},
+		{`[{"node": "Variable"}]`, "$[0]: Variable is not a ast.Statement"},
+		{`[{"node": "PrintStmt", "Expression": 1}]`, "$[0].Expression: expected a node"},
+		// Only the fields the source can leave out may be null.
+		{`[{"node": "PrintStmt"}]`, "$[0].Expression: expected a node"},
+		{`[null]`, "$[0]: expected a node"},
+		{`[{"node": "BlockStmt", "Brace": {"type": "LEFT_BRACE", "lexeme": "{", "line": 1, "col": 1}, "Statements": [null]}]`,
+			"$[0].Statements[0]: expected a node"},
+		{`[{"node": "BreakStmt", "Keyword": {"type": "NOPE", "lexeme": "break", "line": 1, "col": 5}}]`,
+			`$[0].Keyword: unknown token type "NOPE"`},
+		{`[{"node": "BreakStmt", "Keyword": {"type": "BREAK", "lexeme": "break", "line": "1", "col": 5}}]`,
+			"$[0].Keyword.line: expected an integer"},
+		{`[`, "unexpected EOF"},
+	}
+	for _, test := range tests {
+		if _, err := ast.FromJSON([]byte(test.json)); err == nil || err.Error() != test.want {
+			t.Errorf("%s: got error %v, want %q", test.json, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"Glox/token"
+)
+
+// Pattern is the left-hand side of a case in a match statement.
+type Pattern interface {
+	String() string
+	pattern()
+}
+
+// LiteralPattern matches values equal to a literal. Value is a *Literal, or
+// a *Unary for negative numbers.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (p *LiteralPattern) pattern() {}
+func (p *LiteralPattern) String() string {
+	return Beautify(p)
+}
+
+// BindingPattern matches anything and binds it to Name. The name _ matches
+// without binding.
+type BindingPattern struct {
+	Name token.Token
+}
+
+func (p *BindingPattern) pattern() {}
+func (p *BindingPattern) String() string {
+	return Beautify(p)
+}
+
+// ListPattern matches a list of exactly len(Elements) elements.
+type ListPattern struct {
+	Bracket  token.Token
+	Elements []Pattern
+}
+
+func (p *ListPattern) pattern() {}
+func (p *ListPattern) String() string {
+	return Beautify(p)
+}
+
+// InstancePattern matches an instance of Class, or of a subclass, whose
+// properties Fields match Patterns. A bare field name binds the property to
+// a variable of the same name.
+type InstancePattern struct {
+	Class    *Variable
+	Fields   []token.Token
+	Patterns []Pattern
+}
+
+func (p *InstancePattern) pattern() {}
+func (p *InstancePattern) String() string {
+	return Beautify(p)
+}
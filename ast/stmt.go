@@ -6,12 +6,19 @@ import (
 
 type StmtVisitor interface {
 	VisitBlockStmt(stmt *BlockStmt) interface{}
+	VisitBreakStmt(stmt *BreakStmt) interface{}
 	VisitClassStmt(stmt *ClassStmt) interface{}
+	VisitContinueStmt(stmt *ContinueStmt) interface{}
+	VisitExportStmt(stmt *ExportStmt) interface{}
 	VisitExpressionStmt(stmt *ExpressionStmt) interface{}
 	VisitFunctionStmt(stmt *FunStmt) interface{}
 	VisitIfStmt(stmt *IfStmt) interface{}
+	VisitImportStmt(stmt *ImportStmt) interface{}
+	VisitMatchStmt(stmt *MatchStmt) interface{}
 	VisitPrintStmt(stmt *PrintStmt) interface{}
 	VisitReturnStmt(stmt *ReturnStmt) interface{}
+	VisitThrowStmt(stmt *ThrowStmt) interface{}
+	VisitTryStmt(stmt *TryStmt) interface{}
 	VisitVarStmt(stmt *VarStmt) interface{}
 	VisitWhileStmt(stmt *WhileStmt) interface{}
 }
@@ -21,7 +28,10 @@ type Statement interface {
 	Accept(visitor StmtVisitor) interface{}
 }
 
+// BlockStmt is a block in braces, or the block a for loop with an
+// initializer desugars to, whose Brace is the zero token.
 type BlockStmt struct {
+	Brace      token.Token
 	Statements []Statement
 }
 
@@ -32,9 +42,32 @@ func (stmt *BlockStmt) String() string {
 	return Beautify(stmt)
 }
 
+type BreakStmt struct {
+	Keyword token.Token
+}
+
+func (stmt *BreakStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitBreakStmt(stmt)
+}
+func (stmt *BreakStmt) String() string {
+	return Beautify(stmt)
+}
+
+type ContinueStmt struct {
+	Keyword token.Token
+}
+
+func (stmt *ContinueStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitContinueStmt(stmt)
+}
+func (stmt *ContinueStmt) String() string {
+	return Beautify(stmt)
+}
+
 type ClassStmt struct {
 	Name       token.Token
 	Superclass *Variable
+	Fields     []*Field
 	Methods    []Statement
 }
 
@@ -45,6 +78,19 @@ func (stmt *ClassStmt) String() string {
 	return Beautify(stmt)
 }
 
+// ExportStmt marks a top-level declaration as visible to importers.
+type ExportStmt struct {
+	Keyword     token.Token
+	Declaration Statement
+}
+
+func (stmt *ExportStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitExportStmt(stmt)
+}
+func (stmt *ExportStmt) String() string {
+	return Beautify(stmt)
+}
+
 type ExpressionStmt struct {
 	Expression Expression
 }
@@ -56,10 +102,24 @@ func (stmt *ExpressionStmt) String() string {
 	return Beautify(stmt)
 }
 
+// FunctionKind tells class members apart. Declarations outside a class are
+// always Method.
+type FunctionKind int
+
+const (
+	Method FunctionKind = iota
+	StaticMethod
+	Getter
+	Setter
+)
+
 type FunStmt struct {
-	Name   token.Token
-	Params []token.Token
-	Body   []Statement
+	Name       token.Token
+	Params     []token.Token
+	ParamTypes []*TypeAnnotation // one per parameter, nil where unannotated.
+	ReturnType *TypeAnnotation
+	Body       []Statement
+	Kind       FunctionKind
 }
 
 func (stmt *FunStmt) Accept(visitor StmtVisitor) interface{} {
@@ -82,6 +142,47 @@ func (stmt *IfStmt) String() string {
 	return Beautify(stmt)
 }
 
+// ImportStmt is either import "Path" as Alias; or, when Names is not nil,
+// from "Path" import Names;.
+type ImportStmt struct {
+	Keyword token.Token
+	Path    token.Token
+	Alias   token.Token
+	Names   []token.Token
+}
+
+func (stmt *ImportStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitImportStmt(stmt)
+}
+func (stmt *ImportStmt) String() string {
+	return Beautify(stmt)
+}
+
+// MatchStmt runs the body of the first case with a matching pattern and a
+// true guard, or Default if no case matches.
+type MatchStmt struct {
+	Keyword token.Token
+	Subject Expression
+	Cases   []*MatchCase
+	Default Statement
+}
+
+// MatchCase is one `case pattern, pattern if guard => body` arm. Guard is
+// nil when the case has none.
+type MatchCase struct {
+	Keyword  token.Token
+	Patterns []Pattern
+	Guard    Expression
+	Body     Statement
+}
+
+func (stmt *MatchStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitMatchStmt(stmt)
+}
+func (stmt *MatchStmt) String() string {
+	return Beautify(stmt)
+}
+
 type PrintStmt struct {
 	Expression Expression
 }
@@ -105,9 +206,41 @@ func (stmt *ReturnStmt) String() string {
 	return Beautify(stmt)
 }
 
+type ThrowStmt struct {
+	Keyword token.Token
+	Value   Expression
+}
+
+func (stmt *ThrowStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitThrowStmt(stmt)
+}
+func (stmt *ThrowStmt) String() string {
+	return Beautify(stmt)
+}
+
+// TryStmt is try { Body } catch (Param) { Catch } finally { Finally }. At
+// least one of Catch and Finally is present, the other one may be nil.
+type TryStmt struct {
+	Keyword token.Token
+	Body    []Statement
+	Param   token.Token
+	Catch   *BlockStmt
+	Finally *BlockStmt
+}
+
+func (stmt *TryStmt) Accept(visitor StmtVisitor) interface{} {
+	return visitor.VisitTryStmt(stmt)
+}
+func (stmt *TryStmt) String() string {
+	return Beautify(stmt)
+}
+
+// VarStmt declares a variable, or a constant when Const is set.
 type VarStmt struct {
 	Name        token.Token
+	Type        *TypeAnnotation
 	Initializer Expression
+	Const       bool
 }
 
 func (stmt *VarStmt) Accept(visitor StmtVisitor) interface{} {
@@ -117,9 +250,13 @@ func (stmt *VarStmt) String() string {
 	return Beautify(stmt)
 }
 
+// WhileStmt is a while loop, or a desugared for loop when Increment is not
+// nil. The increment runs after every iteration, including the ones cut
+// short by continue.
 type WhileStmt struct {
 	Condition Expression
 	Body      Statement
+	Increment Expression
 }
 
 func (stmt *WhileStmt) Accept(visitor StmtVisitor) interface{} {
@@ -0,0 +1,31 @@
+package ast
+
+import (
+	"Glox/token"
+	"strings"
+)
+
+// TypeAnnotation is an optional type written after a name, such as number
+// or List<string>. The interpreter ignores annotations; only the type
+// checker reads them.
+type TypeAnnotation struct {
+	Name      token.Token
+	Arguments []*TypeAnnotation
+}
+
+func (t *TypeAnnotation) String() string {
+	if len(t.Arguments) == 0 {
+		return t.Name.Lexeme
+	}
+	var arguments []string
+	for _, argument := range t.Arguments {
+		arguments = append(arguments, argument.String())
+	}
+	return t.Name.Lexeme + "<" + strings.Join(arguments, ", ") + ">"
+}
+
+// Field is a field declared in a class body, name: Type;.
+type Field struct {
+	Name token.Token
+	Type *TypeAnnotation
+}
@@ -0,0 +1,612 @@
+// Package checker is an optional static type checker. It reads the type
+// annotations the interpreter ignores, infers the types of locals from their
+// initializers and reports mismatches, undefined members and calls with the
+// wrong number of arguments before anything runs.
+//
+// Unannotated parameters, and anything else it can't infer, have type any,
+// which is compatible with everything, so unannotated code checks cleanly.
+package checker
+
+import (
+	"Glox/ast"
+	"Glox/token"
+	"fmt"
+)
+
+// binding is a variable in scope. Unannotated variables take the type of
+// their initializer until something of another type is assigned to them.
+type binding struct {
+	typ       Type
+	annotated bool
+}
+
+type Checker struct {
+	// scopes[0] holds the natives; the last entry is the innermost scope.
+	scopes   []map[string]*binding
+	errors   []string
+	function *FunctionType    // the function whose body is being checked.
+	class    *ClassType       // the class whose methods are being checked.
+	kind     ast.FunctionKind // the kind of method being checked.
+
+	classes   map[*ast.ClassStmt]*ClassType
+	functions map[*ast.FunStmt]*FunctionType
+}
+
+func NewChecker() *Checker {
+	c := &Checker{
+		classes:   make(map[*ast.ClassStmt]*ClassType),
+		functions: make(map[*ast.FunStmt]*FunctionType),
+	}
+	c.scopes = []map[string]*binding{natives()}
+	return c
+}
+
+// natives mirrors the interpreter's built-in functions.
+func natives() map[string]*binding {
+	anyList := &ListType{Element: Any}
+	anyMap := &MapType{Key: Any, Value: Any}
+	signatures := []*FunctionType{
+		{Name: "clock", Params: []Type{}, Return: Number},
+		{Name: "Error", Params: []Type{Any}, Return: Any},
+		{Name: "len", Params: []Type{Any}, Return: Number},
+		{Name: "push", Params: []Type{anyList, Any}, Return: Any},
+		{Name: "pop", Params: []Type{anyList}, Return: Any},
+		{Name: "keys", Params: []Type{anyMap}, Return: anyList},
+		{Name: "values", Params: []Type{anyMap}, Return: anyList},
+		{Name: "has", Params: []Type{anyMap, Any}, Return: Bool},
+		{Name: "remove", Params: []Type{anyMap, Any}, Return: Any},
+	}
+	scope := make(map[string]*binding)
+	for _, signature := range signatures {
+		scope[signature.Name] = &binding{typ: signature, annotated: true}
+	}
+	return scope
+}
+
+// Check type checks a program.
+func (c *Checker) Check(statements []ast.Statement) {
+	c.beginScope()
+	c.checkStatements(statements)
+	c.endScope()
+}
+
+func (c *Checker) Errors() []string {
+	return c.errors
+}
+
+// checkStatements checks a list of statements. Functions and classes are
+// declared up front so that bodies and annotations can refer to later
+// declarations. Classes go first, since signatures may name them.
+func (c *Checker) checkStatements(statements []ast.Statement) {
+	var declarations []ast.Statement
+	for _, stmt := range statements {
+		if export, ok := stmt.(*ast.ExportStmt); ok {
+			stmt = export.Declaration
+		}
+		if class, ok := stmt.(*ast.ClassStmt); ok {
+			c.classes[class] = newClassType(class.Name.Lexeme)
+			c.define(class.Name.Lexeme, c.classes[class], true)
+		}
+		declarations = append(declarations, stmt)
+	}
+	for _, stmt := range declarations {
+		switch stmt := stmt.(type) {
+		case *ast.FunStmt:
+			c.define(stmt.Name.Lexeme, c.functionType(stmt), true)
+		case *ast.ClassStmt:
+			c.classType(stmt)
+		}
+	}
+	for _, stmt := range statements {
+		c.checkStmt(stmt)
+	}
+}
+
+func (c *Checker) checkStmt(stmt ast.Statement) {
+	stmt.Accept(c)
+}
+
+func (c *Checker) check(expr ast.Expression) Type {
+	return expr.Accept(c).(Type)
+}
+
+// checkAs checks an expression whose value is stored where type want is
+// expected. List and map literals take their element types from want, so
+// List<any> lets a list literal mix elements of unrelated types.
+func (c *Checker) checkAs(expr ast.Expression, want Type) Type {
+	switch expr := expr.(type) {
+	case *ast.List:
+		return c.list(expr, want)
+	case *ast.Map:
+		return c.mapLiteral(expr, want)
+	case *ast.Grouping:
+		return c.checkAs(expr.Expression, want)
+	}
+	return c.check(expr)
+}
+
+// list checks a list literal stored where type want is expected, nil when
+// nothing is. When want gives the element type, each element must be
+// assignable to it. Otherwise the list has the type its elements have in
+// common, or any if they have none.
+func (c *Checker) list(expr *ast.List, want Type) Type {
+	var expected Type
+	if list, ok := want.(*ListType); ok {
+		expected = list.Element
+	}
+	types := make([]Type, len(expr.Elements))
+	for n, element := range expr.Elements {
+		types[n] = c.checkAs(element, expected)
+	}
+	if expected != nil {
+		c.elements(expr.Elements, types, expected, expr.Bracket, "an element")
+		return &ListType{Element: expected}
+	}
+	return &ListType{Element: common(types)}
+}
+
+// mapLiteral checks a map literal like list does.
+func (c *Checker) mapLiteral(expr *ast.Map, want Type) Type {
+	var expectedKey, expectedValue Type
+	if m, ok := want.(*MapType); ok {
+		expectedKey, expectedValue = m.Key, m.Value
+	}
+	keys := make([]Type, len(expr.Keys))
+	values := make([]Type, len(expr.Values))
+	for n := range expr.Keys {
+		keys[n] = c.checkAs(expr.Keys[n], expectedKey)
+		values[n] = c.checkAs(expr.Values[n], expectedValue)
+	}
+	if expectedKey != nil {
+		c.elements(expr.Keys, keys, expectedKey, expr.Brace, "a key")
+		c.elements(expr.Values, values, expectedValue, expr.Brace, "a value")
+		return &MapType{Key: expectedKey, Value: expectedValue}
+	}
+	return &MapType{Key: common(keys), Value: common(values)}
+}
+
+// elements reports the elements of a literal that can't be stored where
+// the annotation expects type want.
+func (c *Checker) elements(exprs []ast.Expression, types []Type, want Type, literal token.Token, what string) {
+	for n, typ := range types {
+		c.assign(position(exprs[n], literal), typ, want, what)
+	}
+}
+
+// common returns the type the elements of an unannotated literal have in
+// common, which is any when there are no elements.
+func common(types []Type) Type {
+	if len(types) == 0 {
+		return Any
+	}
+	typ := types[0]
+	for _, other := range types[1:] {
+		typ = unify(typ, other)
+	}
+	return typ
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*binding))
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *Checker) define(name string, typ Type, annotated bool) {
+	c.scopes[len(c.scopes)-1][name] = &binding{typ: typ, annotated: annotated}
+}
+
+func (c *Checker) lookup(name string) *binding {
+	for n := len(c.scopes) - 1; n >= 0; n-- {
+		if b, ok := c.scopes[n][name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *Checker) error(tok token.Token, format string, args ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprintf("Ln %d, Col %d %s", tok.Line, tok.Col, fmt.Sprintf(format, args...)))
+}
+
+// resolveType turns an annotation into a type. A missing annotation is any.
+func (c *Checker) resolveType(annotation *ast.TypeAnnotation) Type {
+	if annotation == nil {
+		return Any
+	}
+	name := annotation.Name
+	arguments := func(want int) []Type {
+		if len(annotation.Arguments) != 0 && len(annotation.Arguments) != want {
+			c.error(name, "Type '%s' takes %d type arguments but got %d.", name.Lexeme, want, len(annotation.Arguments))
+		}
+		types := make([]Type, want)
+		for n := range types {
+			types[n] = Any
+			if n < len(annotation.Arguments) {
+				types[n] = c.resolveType(annotation.Arguments[n])
+			}
+		}
+		return types
+	}
+	switch name.Lexeme {
+	case "List":
+		return &ListType{Element: arguments(1)[0]}
+	case "Map":
+		types := arguments(2)
+		return &MapType{Key: types[0], Value: types[1]}
+	}
+	arguments(0)
+	switch name.Lexeme {
+	case "any":
+		return Any
+	case "nil":
+		return Nil
+	case "bool":
+		return Bool
+	case "number":
+		return Number
+	case "string":
+		return String
+	}
+	if b := c.lookup(name.Lexeme); b != nil {
+		if class, ok := b.typ.(*ClassType); ok {
+			return &InstanceType{Class: class}
+		}
+	}
+	c.error(name, "Unknown type '%s'.", name.Lexeme)
+	return Any
+}
+
+func (c *Checker) signature(name string, params []*ast.TypeAnnotation, count int, returnType *ast.TypeAnnotation) *FunctionType {
+	function := &FunctionType{Name: name, Params: make([]Type, count), Return: c.resolveType(returnType)}
+	for n := range function.Params {
+		function.Params[n] = Any
+		if n < len(params) {
+			function.Params[n] = c.resolveType(params[n])
+		}
+	}
+	return function
+}
+
+func (c *Checker) functionType(stmt *ast.FunStmt) *FunctionType {
+	if function, ok := c.functions[stmt]; ok {
+		return function
+	}
+	function := c.signature(stmt.Name.Lexeme, stmt.ParamTypes, len(stmt.Params), stmt.ReturnType)
+	c.functions[stmt] = function
+	return function
+}
+
+// classType fills in the members of a class declared by checkStatements.
+func (c *Checker) classType(stmt *ast.ClassStmt) *ClassType {
+	class := c.classes[stmt]
+	if stmt.Superclass != nil {
+		class.open = true
+		if b := c.lookup(stmt.Superclass.Name.Lexeme); b != nil {
+			if superclass, ok := b.typ.(*ClassType); ok {
+				class.Superclass, class.open = superclass, false
+			}
+		}
+	}
+	for _, field := range stmt.Fields {
+		class.Fields[field.Name.Lexeme] = c.resolveType(field.Type)
+	}
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		function := c.functionType(method)
+		switch method.Kind {
+		case ast.StaticMethod:
+			class.Statics[method.Name.Lexeme] = function
+		case ast.Getter:
+			class.Getters[method.Name.Lexeme] = function.Return
+		case ast.Setter:
+			if len(function.Params) == 1 {
+				class.Setters[method.Name.Lexeme] = function.Params[0]
+			}
+		default:
+			class.Methods[method.Name.Lexeme] = function
+		}
+		// Fields the class's own methods assign are part of its shape. In
+		// static methods this is the class, not an instance.
+		if method.Kind == ast.StaticMethod {
+			continue
+		}
+		for _, name := range thisAssignments(method.Body) {
+			if _, ok := class.Fields[name]; !ok {
+				class.Fields[name] = Any
+			}
+		}
+	}
+	return class
+}
+
+// checkFunction checks a function body against its signature.
+func (c *Checker) checkFunction(function *FunctionType, params []token.Token, body []ast.Statement) {
+	enclosing := c.function
+	c.function = function
+	c.beginScope()
+	for n, param := range params {
+		c.define(param.Lexeme, function.Params[n], true)
+	}
+	c.checkStatements(body)
+	c.endScope()
+	c.function = enclosing
+}
+
+// assign checks that a value of type from may be stored where to is
+// expected, reporting at tok.
+func (c *Checker) assign(tok token.Token, from, to Type, what string) {
+	if !assignable(from, to) {
+		c.error(tok, "Cannot assign %s to %s of type %s.", from, what, to)
+	}
+}
+
+// assignVariable records an assignment to a variable. Assigning a different
+// type to an unannotated variable widens it to any.
+func (c *Checker) assignVariable(name token.Token, value ast.Expression, typ Type) {
+	b := c.lookup(name.Lexeme)
+	if b == nil {
+		return
+	}
+	if b.annotated {
+		c.assign(position(value, name), typ, b.typ, fmt.Sprintf("'%s'", name.Lexeme))
+	} else if !assignable(typ, b.typ) || !assignable(b.typ, typ) {
+		b.typ = Any
+	}
+}
+
+// property returns the type of object.name, reporting undefined members.
+func (c *Checker) property(object Type, name token.Token) Type {
+	switch object := object.(type) {
+	case *InstanceType:
+		if member, ok := object.Class.member(name.Lexeme); ok {
+			return member
+		}
+		c.error(name, "Undefined property '%s' on %s.", name.Lexeme, object.Class.Name)
+		return Any
+	case *ClassType:
+		if method, ok := object.static(name.Lexeme); ok {
+			return method
+		}
+		c.error(name, "Undefined static method '%s' on class %s.", name.Lexeme, object.Name)
+		return Any
+	}
+	if object != Any {
+		c.error(name, "Only instances have properties, not %s.", object)
+	}
+	return Any
+}
+
+// setProperty checks an assignment to object.name.
+func (c *Checker) setProperty(object Type, name token.Token, value ast.Expression, typ Type) {
+	switch object := object.(type) {
+	case *InstanceType:
+		want, ok := object.Class.setter(name.Lexeme)
+		if !ok {
+			c.error(name, "Property '%s' has a getter but no setter.", name.Lexeme)
+			return
+		}
+		c.assign(position(value, name), typ, want, fmt.Sprintf("property '%s'", name.Lexeme))
+		return
+	case *ClassType:
+		c.error(name, "Only instances have fields, not %s.", object)
+		return
+	}
+	if object != Any {
+		c.error(name, "Only instances have fields, not %s.", object)
+	}
+}
+
+// index returns the type of object[index].
+func (c *Checker) index(bracket token.Token, object, index Type) Type {
+	switch object := object.(type) {
+	case *ListType:
+		if !assignable(index, Number) {
+			c.error(bracket, "List index must be a number, not %s.", index)
+		}
+		return object.Element
+	case *MapType:
+		c.assign(bracket, index, object.Key, "a key")
+		return object.Value
+	}
+	if object != Any {
+		c.error(bracket, "Only lists and maps can be indexed, not %s.", object)
+	}
+	return Any
+}
+
+// binary returns the type of an arithmetic, comparison or equality
+// operation, reporting operands the operator can't take.
+func (c *Checker) binary(operator token.Token, left, right Type) Type {
+	switch operator.Type {
+	case token.PLUS:
+		switch {
+		case left == Any || right == Any:
+			if left == String || right == String {
+				return String
+			}
+			if left == Number || right == Number {
+				return Number
+			}
+			return Any
+		case left == Number && right == Number:
+			return Number
+		case left == String && right == String:
+			return String
+		}
+		c.error(operator, "Operands of '+' must be two numbers or two strings, not %s and %s.", left, right)
+		return Any
+	case token.MINUS, token.STAR, token.SLASH, token.BACKSLASH, token.PERCENT:
+		if !assignable(left, Number) || !assignable(right, Number) {
+			c.error(operator, "Operands of '%s' must be numbers, not %s and %s.", operator.Lexeme, left, right)
+		}
+		return Number
+	case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+		if !assignable(left, Number) || !assignable(right, Number) {
+			c.error(operator, "Operands of '%s' must be numbers, not %s and %s.", operator.Lexeme, left, right)
+		}
+		return Bool
+	}
+	return Bool
+}
+
+// binaryOperator maps a compound assignment or increment operator to the
+// binary operator it applies.
+func binaryOperator(operator token.Token) token.Token {
+	switch operator.Type {
+	case token.PLUS_EQUAL, token.PLUS_PLUS:
+		operator.Type, operator.Lexeme = token.PLUS, "+"
+	case token.MINUS_EQUAL, token.MINUS_MINUS:
+		operator.Type, operator.Lexeme = token.MINUS, "-"
+	case token.STAR_EQUAL:
+		operator.Type, operator.Lexeme = token.STAR, "*"
+	case token.SLASH_EQUAL:
+		operator.Type, operator.Lexeme = token.SLASH, "/"
+	}
+	return operator
+}
+
+func (c *Checker) setIndex(bracket token.Token, object Type, value ast.Expression, typ Type) {
+	switch object := object.(type) {
+	case *ListType:
+		c.assign(position(value, bracket), typ, object.Element, "an element")
+	case *MapType:
+		c.assign(position(value, bracket), typ, object.Value, "a value")
+	default:
+		if object != Any {
+			c.error(bracket, "Only lists and maps can be indexed, not %s.", object)
+		}
+	}
+}
+
+// bindPattern defines the variables a match pattern binds, typed from the
+// subject where possible.
+func (c *Checker) bindPattern(pattern ast.Pattern, subject Type) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		c.check(pattern.Value)
+	case *ast.BindingPattern:
+		if pattern.Name.Lexeme != "_" {
+			c.define(pattern.Name.Lexeme, subject, false)
+		}
+	case *ast.ListPattern:
+		element := Type(Any)
+		if list, ok := subject.(*ListType); ok {
+			element = list.Element
+		}
+		for _, sub := range pattern.Elements {
+			c.bindPattern(sub, element)
+		}
+	case *ast.InstancePattern:
+		var class *ClassType
+		if b := c.lookup(pattern.Class.Name.Lexeme); b != nil {
+			class, _ = b.typ.(*ClassType)
+		}
+		for n, field := range pattern.Fields {
+			member := Type(Any)
+			if class != nil {
+				if typ, ok := class.member(field.Lexeme); ok {
+					member = typ
+				} else {
+					c.error(field, "Undefined property '%s' on %s.", field.Lexeme, class.Name)
+				}
+			}
+			c.bindPattern(pattern.Patterns[n], member)
+		}
+	}
+}
+
+// thisAssignments lists the fields a method body assigns through this.
+func thisAssignments(body []ast.Statement) []string {
+	var names []string
+	var visit func(node interface{})
+	visit = func(node interface{}) {
+		switch node := node.(type) {
+		case *ast.ExpressionStmt:
+			visit(node.Expression)
+		case *ast.BlockStmt:
+			for _, stmt := range node.Statements {
+				visit(stmt)
+			}
+		case *ast.IfStmt:
+			visit(node.ThenBranch)
+			if node.ElseBranch != nil {
+				visit(node.ElseBranch)
+			}
+		case *ast.WhileStmt:
+			visit(node.Body)
+		case *ast.TryStmt:
+			for _, stmt := range node.Body {
+				visit(stmt)
+			}
+			if node.Catch != nil {
+				visit(node.Catch)
+			}
+			if node.Finally != nil {
+				visit(node.Finally)
+			}
+		case *ast.Set:
+			if _, ok := node.Object.(*ast.This); ok {
+				names = append(names, node.Name.Lexeme)
+			}
+			visit(node.Value)
+		case *ast.Comma:
+			visit(node.Left)
+			visit(node.Right)
+		}
+	}
+	for _, stmt := range body {
+		visit(stmt)
+	}
+	return names
+}
+
+// position finds a token to report an error about expr at, falling back to
+// fallback for expressions that carry none.
+func position(expr ast.Expression, fallback token.Token) token.Token {
+	switch expr := expr.(type) {
+	case *ast.Literal:
+		if expr.Token.Line > 0 {
+			return expr.Token
+		}
+	case *ast.Variable:
+		return expr.Name
+	case *ast.Assign:
+		return expr.Name
+	case *ast.Binary:
+		return expr.Operator
+	case *ast.Logical:
+		return expr.Operator
+	case *ast.Unary:
+		return expr.Operator
+	case *ast.Call:
+		return expr.Paren
+	case *ast.Get:
+		return expr.Name
+	case *ast.Set:
+		return expr.Name
+	case *ast.Grouping:
+		return position(expr.Expression, fallback)
+	case *ast.List:
+		return expr.Bracket
+	case *ast.Map:
+		return expr.Brace
+	case *ast.Index:
+		return expr.Bracket
+	case *ast.Lambda:
+		return expr.Keyword
+	case *ast.This:
+		return expr.Keyword
+	case *ast.Super:
+		return expr.Method
+	case *ast.Conditional:
+		return expr.Question
+	case *ast.Interpolation:
+		if len(expr.Parts) > 0 {
+			return position(expr.Parts[0], fallback)
+		}
+	}
+	return fallback
+}
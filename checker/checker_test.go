@@ -0,0 +1,189 @@
+package checker
+
+import (
+	"Glox/parser"
+	"Glox/scanner"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// check type checks source and returns the type errors.
+func check(t *testing.T, source string) []string {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	if errors := append(s.Errors(), p.Errors()...); len(errors) > 0 {
+		t.Fatalf("parsing %q: %s", source, strings.Join(errors, "; "))
+	}
+	c := NewChecker()
+	c.Check(statements)
+	return c.Errors()
+}
+
+func TestChecker(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"unannotated code", `
+fun add(a, b) { return a + b; }
+var x = add(1, "two");
+x = nil;`, nil},
+		{"annotated variable", `var n: number = "one";`,
+			[]string{"Ln 1, Col 21 Cannot assign string to 'n' of type number."}},
+		{"inferred local", `
+var s = "text";
+print s - 1;`,
+			[]string{"Ln 3, Col 9 Operands of '-' must be numbers, not string and number."}},
+		{"argument count", `
+fun f(a: number): number { return a; }
+f(1, 2);`,
+			[]string{"Ln 3, Col 7 Expected 1 arguments but got 2."}},
+		{"argument type", `
+fun f(a: number): number { return a; }
+f("one");`,
+			[]string{"Ln 3, Col 7 Argument 1 of 'f' must be number, not string."}},
+		{"return type", `fun f(): string { return 1; }`,
+			[]string{"Ln 1, Col 26 Cannot return number from 'f', which returns string."}},
+		{"undefined property", `
+class Point { init(x) { this.x = x; } }
+print Point(1).y;`,
+			[]string{"Ln 3, Col 16 Undefined property 'y' on Point."}},
+		{"subclass instance", `
+class A {}
+class B < A {}
+var a: A = B();`, nil},
+		{"unknown type", `var x: Thing = nil;`,
+			[]string{"Ln 1, Col 12 Unknown type 'Thing'."}},
+	}
+	for _, test := range tests {
+		if got := check(t, test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMixedLiterals(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		// Without an annotation, elements with nothing in common make any.
+		{"mixed list", `var a = [1, "two"]; a[0] = nil;`, nil},
+		{"nested lists", `var a = [1, [5, 6]]; var b = [[1], ["x"]]; b[0] = [nil];`, nil},
+		{"nil element", `var a = [nil, 1];`, nil},
+		{"mixed map", `var m = {1: true, "b": false}; m[nil] = true;`, nil},
+		{"mixed values keep the key type", `var m = {"a": 1, "b": "x"}; m[1] = 2;`,
+			[]string{"Ln 1, Col 30 Cannot assign number to a key of type string."}},
+		{"inferred any", `var a = [1, "two"]; var n: number = a[0];`, nil},
+		{"annotated variable", `var a: List<any> = [1, "two"];`, nil},
+		{"annotated nested list", `var a: List<List<any>> = [[1], ["x"]];`, nil},
+		{"annotated map", `var m: Map<string, any> = {"a": 1, "b": "x"};`, nil},
+		{"annotated assignment", `
+var a: List<any>;
+a = [true, 1];`, nil},
+		{"annotated parameter", `
+fun f(xs: List<any>) { return xs; }
+f([1, "a"]);`, nil},
+		{"annotated return", `fun f(): List<any> { return [nil, 1]; }`, nil},
+		// Elements are checked against a declared element type.
+		{"declared element type", `var a: List<number> = [1, "two"];`,
+			[]string{"Ln 1, Col 31 Cannot assign string to an element of type number."}},
+		{"declared nested type", `var a: List<List<number>> = [[1], ["x"]];`,
+			[]string{"Ln 1, Col 38 Cannot assign string to an element of type number."}},
+		{"declared map types", `var m: Map<string, bool> = {"a": true, 2: 3};`,
+			[]string{"Ln 1, Col 40 Cannot assign number to a key of type string.", "Ln 1, Col 43 Cannot assign number to a value of type bool."}},
+		{"declared parameter", `
+fun f(xs: List<string>) { return xs; }
+f(["a", 1]);`,
+			[]string{"Ln 3, Col 9 Cannot assign number to an element of type string."}},
+		{"same types", `var a = [1, 2.5, 3]; var b = ["x", "${a}"]; var c = [];`, nil},
+		{"functions", `var f = [(x) => x, (y) => y + 1]; print f[0](1) + 1;`, nil},
+		{"instances of a common superclass", `
+class Animal {}
+class Dog < Animal {}
+class Cat < Animal {}
+var pets = [Dog(), Cat()];
+var pet: Animal = pets[0];`, nil},
+		{"unrelated instances", `
+class Dog {}
+class Cat {}
+var pets = [Dog(), Cat()];
+var pet: Dog = pets[0];`, nil},
+		{"unknown elements", `fun f(x) { return [x, 1]; }`, nil},
+	}
+	for _, test := range tests {
+		if got := check(t, test.source); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckerCollections(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{`var xs: List<number> = [1, 2]; xs[0] = 3;`, ""},
+		{`var xs: List<number> = [1, 2]; xs[0] = "one";`, "Ln 1, Col 44 Cannot assign string to an element of type number."},
+		{`var m: Map<string, number> = {"a": 1}; m["b"] = true;`, "Ln 1, Col 52 Cannot assign bool to a value of type number."},
+		{`var xs: List<number, string> = [];`, "Ln 1, Col 12 Type 'List' takes 1 type arguments but got 2."},
+		{`var xs = [1]; print xs["i"];`, "Ln 1, Col 23 List index must be a number, not string."},
+		{`print 3[0];`, "Ln 1, Col 8 Only lists and maps can be indexed, not number."},
+		{`var xs = [1]; print xs["a":];`, "Ln 1, Col 23 Slice bounds must be numbers."},
+		{`print "s"[1:];`, "Ln 1, Col 10 Only lists can be sliced, not string."},
+		{`var xs: List<any> = [1, "a"];`, ""},
+	}
+	for _, test := range tests {
+		got := check(t, test.source)
+		if test.want == "" && len(got) > 0 || test.want != "" && (len(got) != 1 || got[0] != test.want) {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestCheckerClasses(t *testing.T) {
+	const class = "class P { init(x: number) { this.x = x; } size { return 1; } static make(): P { return P(1); } }\n"
+	tests := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{`var p: P = P.make(); print p.size + 1;`, ""},
+		{`P(1).size = 3;`, "Ln 2, Col 9 Property 'size' has a getter but no setter."},
+		{`print P.nope();`, "Ln 2, Col 12 Undefined static method 'nope' on class P."},
+		{`print (1).x;`, "Ln 2, Col 11 Only instances have properties, not number."},
+		{`print P("one");`, "Ln 2, Col 13 Argument 1 of 'init' must be number, not string."},
+		{`var p: P = "p";`, "Ln 2, Col 14 Cannot assign string to 'p' of type P."},
+		{`print -"s";`, "Ln 2, Col 7 Operand of '-' must be a number, not string."},
+		{`print 5();`, "Ln 2, Col 9 Can only call functions and classes, not number."},
+	}
+	for _, test := range tests {
+		got := check(t, class+test.source)
+		if test.want == "" && len(got) > 0 || test.want != "" && (len(got) != 1 || got[0] != test.want) {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestStaticThis(t *testing.T) {
+	// In a static method, this is the class rather than an instance.
+	const class = "class M {\n  sq(n) { return n * n; }\n  static cube(n) { return n * this.cb(n); }\n  static cb(n) { return n * n; }\n  static make() { return this(); }\n"
+	tests := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{"}\nvar m: M = M.make(); print m.sq(M.cube(2));", ""},
+		{"  static bad() { return this.sq(1); }\n}", "Ln 6, Col 31 Undefined static method 'sq' on class M."},
+	}
+	for _, test := range tests {
+		got := check(t, class+test.source)
+		if test.want == "" && len(got) > 0 || test.want != "" && (len(got) != 1 || got[0] != test.want) {
+			t.Errorf("%s: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the static type of a value as far as the checker can tell.
+type Type interface {
+	String() string
+}
+
+// Basic is one of the built-in scalar types, or any for values the checker
+// knows nothing about.
+type Basic struct {
+	name string
+}
+
+func (t *Basic) String() string {
+	return t.name
+}
+
+var (
+	Any    = &Basic{name: "any"}
+	Nil    = &Basic{name: "nil"}
+	Bool   = &Basic{name: "bool"}
+	Number = &Basic{name: "number"}
+	String = &Basic{name: "string"}
+)
+
+type ListType struct {
+	Element Type
+}
+
+func (t *ListType) String() string {
+	return fmt.Sprintf("List<%s>", t.Element)
+}
+
+type MapType struct {
+	Key   Type
+	Value Type
+}
+
+func (t *MapType) String() string {
+	return fmt.Sprintf("Map<%s, %s>", t.Key, t.Value)
+}
+
+// FunctionType is the signature of a function, method or lambda.
+type FunctionType struct {
+	Name   string
+	Params []Type
+	Return Type
+}
+
+func (t *FunctionType) String() string {
+	var params []string
+	for _, param := range t.Params {
+		params = append(params, param.String())
+	}
+	return fmt.Sprintf("fun(%s): %s", strings.Join(params, ", "), t.Return)
+}
+
+// ClassType is a class value. Fields holds the declared fields and the ones
+// the class's own methods assign through this.
+type ClassType struct {
+	Name       string
+	Superclass *ClassType
+	// open is set when the superclass isn't statically known, so members
+	// the class doesn't declare may still exist.
+	open    bool
+	Fields  map[string]Type
+	Methods map[string]*FunctionType
+	Getters map[string]Type
+	Setters map[string]Type
+	Statics map[string]*FunctionType
+}
+
+func newClassType(name string) *ClassType {
+	return &ClassType{
+		Name:    name,
+		Fields:  make(map[string]Type),
+		Methods: make(map[string]*FunctionType),
+		Getters: make(map[string]Type),
+		Setters: make(map[string]Type),
+		Statics: make(map[string]*FunctionType),
+	}
+}
+
+func (t *ClassType) String() string {
+	return "class " + t.Name
+}
+
+// member finds a field, getter or method, looking in superclasses. The
+// second result is false only when the member surely doesn't exist.
+func (t *ClassType) member(name string) (Type, bool) {
+	for class := t; class != nil; class = class.Superclass {
+		if field, ok := class.Fields[name]; ok {
+			return field, true
+		}
+		if getter, ok := class.Getters[name]; ok {
+			return getter, true
+		}
+		if method, ok := class.Methods[name]; ok {
+			return method, true
+		}
+		if class.open {
+			return Any, true
+		}
+	}
+	return nil, false
+}
+
+func (t *ClassType) static(name string) (Type, bool) {
+	for class := t; class != nil; class = class.Superclass {
+		if method, ok := class.Statics[name]; ok {
+			return method, true
+		}
+		if class.open {
+			return Any, true
+		}
+	}
+	return nil, false
+}
+
+// setter returns the type a property accepts on assignment. The second
+// result is false when the property has a getter but no setter.
+func (t *ClassType) setter(name string) (Type, bool) {
+	for class := t; class != nil; class = class.Superclass {
+		if setter, ok := class.Setters[name]; ok {
+			return setter, true
+		}
+		if field, ok := class.Fields[name]; ok {
+			return field, true
+		}
+		if _, ok := class.Getters[name]; ok {
+			return nil, false
+		}
+	}
+	return Any, true
+}
+
+func (t *ClassType) initializer() *FunctionType {
+	for class := t; class != nil; class = class.Superclass {
+		if init, ok := class.Methods["init"]; ok {
+			return init
+		}
+		if class.open {
+			return nil
+		}
+	}
+	return &FunctionType{Name: "init", Params: []Type{}, Return: Nil}
+}
+
+func (t *ClassType) isSubclassOf(other *ClassType) bool {
+	for class := t; class != nil; class = class.Superclass {
+		if class == other {
+			return true
+		}
+	}
+	return false
+}
+
+// InstanceType is an instance of a class.
+type InstanceType struct {
+	Class *ClassType
+}
+
+func (t *InstanceType) String() string {
+	return t.Class.Name
+}
+
+// assignable reports whether a value of type from may be stored where type
+// to is expected.
+func assignable(from, to Type) bool {
+	if from == Any || to == Any {
+		return true
+	}
+	switch to := to.(type) {
+	case *ListType:
+		from, ok := from.(*ListType)
+		return ok && assignable(from.Element, to.Element)
+	case *MapType:
+		from, ok := from.(*MapType)
+		return ok && assignable(from.Key, to.Key) && assignable(from.Value, to.Value)
+	case *FunctionType:
+		_, ok := from.(*FunctionType)
+		return ok
+	case *InstanceType:
+		from, ok := from.(*InstanceType)
+		return ok && from.Class.isSubclassOf(to.Class)
+	}
+	return from == to
+}
+
+// join is the type of a value that may come from either a or b.
+func join(a, b Type) Type {
+	if a == b {
+		return a
+	}
+	if list, ok := a.(*ListType); ok {
+		if other, ok := b.(*ListType); ok {
+			return &ListType{Element: join(list.Element, other.Element)}
+		}
+	}
+	if m, ok := a.(*MapType); ok {
+		if other, ok := b.(*MapType); ok {
+			return &MapType{Key: join(m.Key, other.Key), Value: join(m.Value, other.Value)}
+		}
+	}
+	return Any
+}
+
+// unify is join for the elements of a literal. It also finds what
+// functions of the same arity and instances of a common superclass share,
+// and falls back to any where a and b have nothing in common.
+func unify(a, b Type) Type {
+	if a == b || a == Any || b == Any {
+		return join(a, b)
+	}
+	switch a := a.(type) {
+	case *ListType:
+		if other, ok := b.(*ListType); ok {
+			return &ListType{Element: unify(a.Element, other.Element)}
+		}
+	case *MapType:
+		if other, ok := b.(*MapType); ok {
+			return &MapType{Key: unify(a.Key, other.Key), Value: unify(a.Value, other.Value)}
+		}
+	case *FunctionType:
+		// Calls only check the number of arguments.
+		if other, ok := b.(*FunctionType); ok && len(a.Params) == len(other.Params) {
+			params := make([]Type, len(a.Params))
+			for n := range params {
+				params[n] = Any
+			}
+			return &FunctionType{Name: "function", Params: params, Return: join(a.Return, other.Return)}
+		}
+	case *InstanceType:
+		if other, ok := b.(*InstanceType); ok {
+			for class := a.Class; class != nil; class = class.Superclass {
+				if other.Class.isSubclassOf(class) {
+					return &InstanceType{Class: class}
+				}
+			}
+		}
+	}
+	return Any
+}
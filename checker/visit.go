@@ -0,0 +1,402 @@
+package checker
+
+import (
+	"Glox/ast"
+	"Glox/token"
+	"fmt"
+)
+
+func (c *Checker) VisitBlockStmt(stmt *ast.BlockStmt) interface{} {
+	c.beginScope()
+	c.checkStatements(stmt.Statements)
+	c.endScope()
+	return nil
+}
+
+func (c *Checker) VisitBreakStmt(stmt *ast.BreakStmt) interface{} {
+	return nil
+}
+
+func (c *Checker) VisitClassStmt(stmt *ast.ClassStmt) interface{} {
+	class := c.classes[stmt]
+	if stmt.Superclass != nil {
+		c.check(stmt.Superclass)
+	}
+	enclosing, enclosingKind := c.class, c.kind
+	c.class = class
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		c.kind = method.Kind
+		c.checkFunction(c.functionType(method), method.Params, method.Body)
+	}
+	c.class, c.kind = enclosing, enclosingKind
+	return nil
+}
+
+func (c *Checker) VisitContinueStmt(stmt *ast.ContinueStmt) interface{} {
+	return nil
+}
+
+func (c *Checker) VisitExportStmt(stmt *ast.ExportStmt) interface{} {
+	c.checkStmt(stmt.Declaration)
+	return nil
+}
+
+func (c *Checker) VisitExpressionStmt(stmt *ast.ExpressionStmt) interface{} {
+	c.check(stmt.Expression)
+	return nil
+}
+
+func (c *Checker) VisitFunctionStmt(stmt *ast.FunStmt) interface{} {
+	c.checkFunction(c.functionType(stmt), stmt.Params, stmt.Body)
+	return nil
+}
+
+func (c *Checker) VisitIfStmt(stmt *ast.IfStmt) interface{} {
+	c.check(stmt.Condition)
+	c.checkStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		c.checkStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (c *Checker) VisitImportStmt(stmt *ast.ImportStmt) interface{} {
+	// Modules are checked on their own, so imported names are untyped.
+	if stmt.Names == nil {
+		c.define(stmt.Alias.Lexeme, Any, false)
+		return nil
+	}
+	for _, name := range stmt.Names {
+		c.define(name.Lexeme, Any, false)
+	}
+	return nil
+}
+
+func (c *Checker) VisitMatchStmt(stmt *ast.MatchStmt) interface{} {
+	subject := c.check(stmt.Subject)
+	for _, arm := range stmt.Cases {
+		for _, pattern := range arm.Patterns {
+			c.beginScope()
+			c.bindPattern(pattern, subject)
+			if arm.Guard != nil {
+				c.check(arm.Guard)
+			}
+			c.checkStmt(arm.Body)
+			c.endScope()
+		}
+	}
+	if stmt.Default != nil {
+		c.checkStmt(stmt.Default)
+	}
+	return nil
+}
+
+func (c *Checker) VisitPrintStmt(stmt *ast.PrintStmt) interface{} {
+	c.check(stmt.Expression)
+	return nil
+}
+
+func (c *Checker) VisitReturnStmt(stmt *ast.ReturnStmt) interface{} {
+	typ := Type(Nil)
+	if stmt.Value != nil {
+		var want Type
+		if c.function != nil {
+			want = c.function.Return
+		}
+		typ = c.checkAs(stmt.Value, want)
+	}
+	if c.function != nil && !assignable(typ, c.function.Return) {
+		c.error(position(stmt.Value, stmt.Keyword), "Cannot return %s from '%s', which returns %s.", typ, c.function.Name, c.function.Return)
+	}
+	return nil
+}
+
+func (c *Checker) VisitThrowStmt(stmt *ast.ThrowStmt) interface{} {
+	c.check(stmt.Value)
+	return nil
+}
+
+func (c *Checker) VisitTryStmt(stmt *ast.TryStmt) interface{} {
+	c.beginScope()
+	c.checkStatements(stmt.Body)
+	c.endScope()
+	if stmt.Catch != nil {
+		c.beginScope()
+		c.define(stmt.Param.Lexeme, Any, false)
+		c.checkStatements(stmt.Catch.Statements)
+		c.endScope()
+	}
+	if stmt.Finally != nil {
+		c.checkStmt(stmt.Finally)
+	}
+	return nil
+}
+
+func (c *Checker) VisitVarStmt(stmt *ast.VarStmt) interface{} {
+	if stmt.Type == nil {
+		typ := Type(Nil)
+		if stmt.Initializer != nil {
+			typ = c.check(stmt.Initializer)
+		}
+		c.define(stmt.Name.Lexeme, typ, false)
+		return nil
+	}
+	declared := c.resolveType(stmt.Type)
+	if stmt.Initializer != nil {
+		typ := c.checkAs(stmt.Initializer, declared)
+		c.assign(position(stmt.Initializer, stmt.Name), typ, declared, fmt.Sprintf("'%s'", stmt.Name.Lexeme))
+	}
+	c.define(stmt.Name.Lexeme, declared, true)
+	return nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt *ast.WhileStmt) interface{} {
+	c.check(stmt.Condition)
+	c.checkStmt(stmt.Body)
+	if stmt.Increment != nil {
+		c.check(stmt.Increment)
+	}
+	return nil
+}
+
+func (c *Checker) VisitAssignExpr(expr *ast.Assign) interface{} {
+	var want Type
+	if b := c.lookup(expr.Name.Lexeme); b != nil && b.annotated {
+		want = b.typ
+	}
+	typ := c.checkAs(expr.Value, want)
+	c.assignVariable(expr.Name, expr.Value, typ)
+	return typ
+}
+
+func (c *Checker) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	return c.binary(expr.Operator, c.check(expr.Left), c.check(expr.Right))
+}
+
+func (c *Checker) VisitCallExpr(expr *ast.Call) interface{} {
+	callee := c.check(expr.Callee)
+	var function *FunctionType
+	var result Type
+	switch callee := callee.(type) {
+	case *FunctionType:
+		function, result = callee, callee.Return
+	case *ClassType:
+		function, result = callee.initializer(), &InstanceType{Class: callee}
+	default:
+		if callee != Any {
+			c.error(expr.Paren, "Can only call functions and classes, not %s.", callee)
+		}
+	}
+	var arguments []Type
+	for n, argument := range expr.Arguments {
+		var want Type
+		if function != nil && n < len(function.Params) {
+			want = function.Params[n]
+		}
+		arguments = append(arguments, c.checkAs(argument, want))
+	}
+	if result == nil {
+		return Any
+	}
+	if function == nil {
+		return result
+	}
+	if len(arguments) != len(function.Params) {
+		c.error(expr.Paren, "Expected %d arguments but got %d.", len(function.Params), len(arguments))
+		return result
+	}
+	for n, argument := range arguments {
+		if !assignable(argument, function.Params[n]) {
+			c.error(position(expr.Arguments[n], expr.Paren), "Argument %d of '%s' must be %s, not %s.", n+1, function.Name, function.Params[n], argument)
+		}
+	}
+	return result
+}
+
+func (c *Checker) VisitCommaExpr(expr *ast.Comma) interface{} {
+	c.check(expr.Left)
+	return c.check(expr.Right)
+}
+
+func (c *Checker) VisitCompoundAssignExpr(expr *ast.CompoundAssign) interface{} {
+	current, store := c.reference(expr.Target)
+	typ := c.binary(binaryOperator(expr.Operator), current, c.check(expr.Value))
+	store(expr.Value, typ)
+	return typ
+}
+
+func (c *Checker) VisitConditionalExpr(expr *ast.Conditional) interface{} {
+	c.check(expr.Condition)
+	return join(c.check(expr.Then), c.check(expr.Else))
+}
+
+func (c *Checker) VisitGetExpr(expr *ast.Get) interface{} {
+	return c.property(c.check(expr.Object), expr.Name)
+}
+
+func (c *Checker) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	return c.check(expr.Expression)
+}
+
+func (c *Checker) VisitIncrementExpr(expr *ast.Increment) interface{} {
+	current, store := c.reference(expr.Target)
+	typ := c.binary(binaryOperator(expr.Operator), current, Number)
+	store(nil, typ)
+	return typ
+}
+
+func (c *Checker) VisitIndexExpr(expr *ast.Index) interface{} {
+	object := c.check(expr.Object)
+	return c.index(expr.Bracket, object, c.check(expr.Index))
+}
+
+func (c *Checker) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	for _, part := range expr.Parts {
+		c.check(part)
+	}
+	return String
+}
+
+func (c *Checker) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	name := expr.Name.Lexeme
+	if name == "" {
+		name = "<lambda>"
+	}
+	function := c.signature(name, expr.ParamTypes, len(expr.Params), expr.ReturnType)
+	if expr.Arrow && expr.ReturnType == nil {
+		// Infer the result of an arrow function from its expression.
+		enclosing := c.function
+		c.function = function
+		c.beginScope()
+		for n, param := range expr.Params {
+			c.define(param.Lexeme, function.Params[n], true)
+		}
+		function.Return = c.check(expr.Body[0].(*ast.ReturnStmt).Value)
+		c.endScope()
+		c.function = enclosing
+		return function
+	}
+	c.checkFunction(function, expr.Params, expr.Body)
+	return function
+}
+
+func (c *Checker) VisitListExpr(expr *ast.List) interface{} {
+	return c.list(expr, nil)
+}
+
+func (c *Checker) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	switch expr.Value.(type) {
+	case nil:
+		return Nil
+	case bool:
+		return Bool
+	case string:
+		return String
+	case int64, float64:
+		return Number
+	}
+	return Any
+}
+
+func (c *Checker) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	return join(c.check(expr.Left), c.check(expr.Right))
+}
+
+func (c *Checker) VisitMapExpr(expr *ast.Map) interface{} {
+	return c.mapLiteral(expr, nil)
+}
+
+func (c *Checker) VisitSetExpr(expr *ast.Set) interface{} {
+	object := c.check(expr.Object)
+	typ := c.check(expr.Value)
+	c.setProperty(object, expr.Name, expr.Value, typ)
+	return typ
+}
+
+func (c *Checker) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	object := c.check(expr.Object)
+	index := c.check(expr.Index)
+	typ := c.check(expr.Value)
+	c.index(expr.Bracket, object, index)
+	c.setIndex(expr.Bracket, object, expr.Value, typ)
+	return typ
+}
+
+func (c *Checker) VisitSliceExpr(expr *ast.Slice) interface{} {
+	object := c.check(expr.Object)
+	for _, bound := range []ast.Expression{expr.Start, expr.End} {
+		if bound != nil && !assignable(c.check(bound), Number) {
+			c.error(expr.Bracket, "Slice bounds must be numbers.")
+		}
+	}
+	if _, ok := object.(*ListType); !ok && object != Any {
+		c.error(expr.Bracket, "Only lists can be sliced, not %s.", object)
+		return Any
+	}
+	return object
+}
+
+func (c *Checker) VisitSuperExpr(expr *ast.Super) interface{} {
+	if c.class == nil || c.class.Superclass == nil {
+		return Any
+	}
+	if method, ok := c.class.Superclass.member(expr.Method.Lexeme); ok {
+		return method
+	}
+	if method, ok := c.class.Superclass.static(expr.Method.Lexeme); ok {
+		return method
+	}
+	c.error(expr.Method, "Undefined property '%s' on %s.", expr.Method.Lexeme, c.class.Superclass.Name)
+	return Any
+}
+
+func (c *Checker) VisitThisExpr(expr *ast.This) interface{} {
+	if c.class == nil {
+		return Any
+	}
+	if c.kind == ast.StaticMethod {
+		// Static methods are called on the class itself.
+		return c.class
+	}
+	return &InstanceType{Class: c.class}
+}
+
+func (c *Checker) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	right := c.check(expr.Right)
+	if expr.Operator.Type == token.BANG {
+		return Bool
+	}
+	if !assignable(right, Number) {
+		c.error(expr.Operator, "Operand of '-' must be a number, not %s.", right)
+	}
+	return Number
+}
+
+func (c *Checker) VisitVariableExpr(expr *ast.Variable) interface{} {
+	if b := c.lookup(expr.Name.Lexeme); b != nil {
+		return b.typ
+	}
+	return Any
+}
+
+// reference checks the target of a compound assignment or an increment
+// once, returning its current type and a function that checks storing a new
+// value of type typ into it.
+func (c *Checker) reference(target ast.Expression) (Type, func(value ast.Expression, typ Type)) {
+	switch target := target.(type) {
+	case *ast.Variable:
+		store := func(value ast.Expression, typ Type) { c.assignVariable(target.Name, value, typ) }
+		return c.VisitVariableExpr(target).(Type), store
+	case *ast.Get:
+		object := c.check(target.Object)
+		store := func(value ast.Expression, typ Type) { c.setProperty(object, target.Name, value, typ) }
+		return c.property(object, target.Name), store
+	case *ast.Index:
+		object := c.check(target.Object)
+		index := c.check(target.Index)
+		store := func(value ast.Expression, typ Type) { c.setIndex(target.Bracket, object, value, typ) }
+		return c.index(target.Bracket, object, index), store
+	}
+	return Any, func(ast.Expression, Type) {}
+}
@@ -0,0 +1,288 @@
+package debugger
+
+import (
+	"Glox/ast"
+	"Glox/interpreter"
+	"Glox/parser"
+	"Glox/scanner"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const help = `Commands, with their abbreviations:
+  break LINE [if CONDITION]  b   stop at a line of the script, only when CONDITION is true if given
+  delete [LINE]              d   remove the breakpoint on LINE, or every breakpoint
+  breakpoints                    list the breakpoints
+  continue                   c   run until a breakpoint
+  step                       s   run to the next line, entering calls
+  next                       n   run to the next line, stepping over calls
+  out                        o   run until the current function returns
+  backtrace                  bt  show the call stack
+  frame N                    f   select frame N of the call stack
+  locals                         show the variables of the selected frame, innermost scope first
+  globals                        show the global variables of the file the selected frame runs in
+  print EXPRESSION           p   evaluate an expression in the selected frame
+  list [LINE]                l   show the source around the current line, or LINE
+  quit                       q   stop the script
+An empty line repeats the previous command.`
+
+// run runs a command and reports whether it resumes the script.
+func (d *Debugger) run(command string) bool {
+	name, args := split(command)
+	switch name {
+	case "":
+	case "b", "break":
+		d.setBreakpoint(args)
+	case "d", "delete":
+		d.deleteBreakpoint(args)
+	case "breakpoints":
+		d.listBreakpoints()
+	case "c", "continue":
+		return d.resume(running)
+	case "s", "step":
+		return d.resume(stepIn)
+	case "n", "next":
+		return d.resume(stepOver)
+	case "o", "out":
+		return d.resume(stepOut)
+	case "bt", "backtrace":
+		d.backtrace()
+	case "f", "frame":
+		d.selectFrame(args)
+	case "locals":
+		d.locals()
+	case "globals":
+		d.globals()
+	case "p", "print":
+		d.print(args)
+	case "l", "list":
+		d.list(args)
+	case "q", "quit":
+		d.mode = quitting
+	case "h", "help":
+		fmt.Fprintln(d.out, help)
+	default:
+		fmt.Fprintf(d.out, "Unknown command %q, type help for the list of commands.\n", name)
+	}
+	return false
+}
+
+// split separates the first word of text from the rest.
+func split(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if n := strings.IndexAny(text, " \t"); n >= 0 {
+		return text[:n], strings.TrimSpace(text[n:])
+	}
+	return text, ""
+}
+
+func (d *Debugger) resume(mode mode) bool {
+	d.mode, d.depth = mode, len(d.frames)
+	return true
+}
+
+func (d *Debugger) setBreakpoint(args string) {
+	word, rest := split(args)
+	line, err := strconv.Atoi(word)
+	if err != nil || line < 1 {
+		fmt.Fprintln(d.out, "Usage: break LINE [if CONDITION]")
+		return
+	}
+	// Like the lines of a multi-line statement, lines without a statement
+	// can't stop the script, so the breakpoint goes to the next line
+	// that can.
+	requested, last := line, len(d.source(d.file))
+	for line <= last && !d.statements[line] {
+		line++
+	}
+	if line > last {
+		fmt.Fprintf(d.out, "No statement starts on line %d or after it.\n", requested)
+		return
+	}
+	b := &breakpoint{line: line}
+	if rest != "" {
+		keyword, condition := split(rest)
+		if keyword != "if" || condition == "" {
+			fmt.Fprintln(d.out, "Usage: break LINE [if CONDITION]")
+			return
+		}
+		expr, ok := d.parse(condition)
+		if !ok {
+			return
+		}
+		b.condition, b.text = expr, condition
+	}
+	d.breakpoints[line] = b
+	if line != requested {
+		fmt.Fprintf(d.out, "No statement starts on line %d.\n", requested)
+	}
+	fmt.Fprintf(d.out, "Breakpoint on %s.\n", b)
+}
+
+func (d *Debugger) deleteBreakpoint(args string) {
+	if args == "" {
+		d.breakpoints = make(map[int]*breakpoint)
+		fmt.Fprintln(d.out, "Deleted every breakpoint.")
+		return
+	}
+	line, err := strconv.Atoi(args)
+	if err != nil {
+		fmt.Fprintln(d.out, "Usage: delete [LINE]")
+		return
+	}
+	if d.breakpoints[line] == nil {
+		fmt.Fprintf(d.out, "No breakpoint on line %d.\n", line)
+		return
+	}
+	delete(d.breakpoints, line)
+	fmt.Fprintf(d.out, "Deleted the breakpoint on line %d.\n", line)
+}
+
+func (d *Debugger) listBreakpoints() {
+	if len(d.breakpoints) == 0 {
+		fmt.Fprintln(d.out, "No breakpoints.")
+		return
+	}
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	for _, line := range lines {
+		fmt.Fprintf(d.out, "  %s\n", d.breakpoints[line])
+	}
+}
+
+func (d *Debugger) backtrace() {
+	for n, frame := range d.frames {
+		mark := " "
+		if n == d.selected {
+			mark = ">"
+		}
+		fmt.Fprintf(d.out, "%s #%d  %s\n", mark, n, describeFrame(frame))
+	}
+}
+
+func describeFrame(frame interpreter.Frame) string {
+	return fmt.Sprintf("%s, line %d of %s", frame.Name, frame.Line, filepath.Base(frame.File))
+}
+
+func (d *Debugger) selectFrame(args string) {
+	n, err := strconv.Atoi(args)
+	if err != nil || n < 0 || n >= len(d.frames) {
+		fmt.Fprintf(d.out, "Usage: frame N, where N is between 0 and %d\n", len(d.frames)-1)
+		return
+	}
+	d.selected = n
+	fmt.Fprintf(d.out, "#%d  %s\n", n, describeFrame(d.frames[n]))
+	d.showLine(d.frames[n].File, d.frames[n].Line)
+}
+
+// locals prints the variables of each scope of the selected frame, up to
+// the globals.
+func (d *Debugger) locals() {
+	scope := 0
+	for e := d.frames[d.selected].Environment; !topLevel(e); e = e.Enclosing() {
+		fmt.Fprintf(d.out, "scope %d:\n", scope)
+		d.variables(e)
+		scope++
+	}
+	if scope == 0 {
+		fmt.Fprintln(d.out, "The frame runs at the top level, see globals.")
+	}
+}
+
+func (d *Debugger) globals() {
+	e := d.frames[d.selected].Environment
+	for !topLevel(e) {
+		e = e.Enclosing()
+	}
+	d.variables(e)
+}
+
+// topLevel reports whether e is the top-level scope of the main script or
+// of a module. Those are only enclosed in the scope of the natives.
+func topLevel(e *interpreter.Environment) bool {
+	return e.Enclosing().Enclosing() == nil
+}
+
+func (d *Debugger) variables(e *interpreter.Environment) {
+	names := e.Names()
+	for _, name := range names {
+		fmt.Fprintf(d.out, "  %s = %s\n", name, interpreter.Inspect(e.Value(name)))
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(d.out, "  (empty)")
+	}
+}
+
+func (d *Debugger) print(args string) {
+	if args == "" {
+		fmt.Fprintln(d.out, "Usage: print EXPRESSION")
+		return
+	}
+	expr, ok := d.parse(args)
+	if !ok {
+		return
+	}
+	value, err := d.interpreter.Evaluate(expr, d.frames[d.selected].Environment)
+	if err != nil {
+		fmt.Fprintln(d.out, describe(err))
+		return
+	}
+	fmt.Fprintln(d.out, interpreter.Inspect(value))
+}
+
+// list prints the lines around line, or around the line the selected
+// frame is at.
+func (d *Debugger) list(args string) {
+	file, center := d.frames[d.selected].File, d.frames[d.selected].Line
+	if args != "" {
+		line, err := strconv.Atoi(args)
+		if err != nil {
+			fmt.Fprintln(d.out, "Usage: list [LINE]")
+			return
+		}
+		center = line
+	}
+	lines := d.source(file)
+	if center < 1 || center > len(lines) {
+		fmt.Fprintf(d.out, "%s has %d lines.\n", filepath.Base(file), len(lines))
+		return
+	}
+	for line := center - 5; line <= center+5; line++ {
+		d.showLine(file, line)
+	}
+}
+
+// describe formats an error raised by an expression the user typed. Its
+// position would only point into the typed text.
+func describe(err error) string {
+	switch err := err.(type) {
+	case *interpreter.RuntimeError:
+		return err.Message
+	case *interpreter.Throw:
+		return "Uncaught exception: " + interpreter.Stringify(err.Value)
+	}
+	return err.Error()
+}
+
+// parse parses an expression typed by the user, printing its errors.
+func (d *Debugger) parse(text string) (ast.Expression, bool) {
+	s := scanner.NewScanner(text)
+	tokens := s.ScanTokens()
+	if len(s.Errors()) > 0 {
+		fmt.Fprintln(d.out, strings.Join(s.Errors(), "\n"))
+		return nil, false
+	}
+	p := parser.NewParser(tokens)
+	expr := p.ParseExpression()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintln(d.out, strings.Join(p.Errors(), "\n"))
+		return nil, false
+	}
+	return expr, true
+}
@@ -0,0 +1,286 @@
+// Package debugger runs scripts on the tree-walking interpreter under an
+// interactive debugger. It stops at breakpoints, which may have a
+// condition, steps into, over and out of calls, and shows the call stack
+// and the variables in each scope of a frame.
+//
+// The debugger reads commands from an io.Reader and writes to an
+// io.Writer, so it can run on a terminal or be driven by a script.
+package debugger
+
+import (
+	"Glox/ast"
+	"Glox/interpreter"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Debugger is an engine that runs scripts on an interpreter it attaches
+// itself to. It stops before the first statement so that breakpoints can
+// be set.
+type Debugger struct {
+	interpreter *interpreter.Interpreter
+	in          *bufio.Scanner
+	out         io.Writer
+
+	file        string              // canonical path of the script.
+	sources     map[string][]string // lines of the files seen, by canonical path.
+	breakpoints map[int]*breakpoint // by line of the script.
+	statements  map[int]bool        // lines of the script where statements start.
+	// lines caches the line of each statement that has run, or 0 for
+	// those the debugger doesn't stop at, since finding it is slow.
+	lines map[ast.Statement]int
+
+	mode  mode
+	depth int      // call depth where the last step began.
+	last  location // where the last statement ran.
+	// ran holds the statements run at last. One that runs there again
+	// starts a new visit of the line, as when a loop iterates.
+	ran map[ast.Statement]bool
+
+	frames   []interpreter.Frame // call stack while stopped, innermost first.
+	selected int                 // index of the frame commands look at.
+	previous string              // last command, repeated by an empty line.
+}
+
+type mode int
+
+const (
+	running  mode = iota // stop at breakpoints only.
+	stepIn               // stop at the next line.
+	stepOver             // stop at the next line outside of the calls it makes.
+	stepOut              // stop once the current function returns.
+	quitting
+)
+
+// location is a line at some depth of the call stack. The debugger stops
+// when execution moves to a new one, or comes back to the same one as a
+// loop does, rather than at every statement of a line.
+type location struct {
+	file  string
+	line  int
+	depth int
+}
+
+type breakpoint struct {
+	line      int
+	condition ast.Expression // nil for breakpoints that always stop.
+	text      string         // source of the condition.
+}
+
+func (b *breakpoint) String() string {
+	if b.condition == nil {
+		return fmt.Sprintf("line %d", b.line)
+	}
+	return fmt.Sprintf("line %d if %s", b.line, b.text)
+}
+
+// quit unwinds the script when the user quits.
+type quit struct{}
+
+func NewDebugger(in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{
+		interpreter: interpreter.NewInterpreter(),
+		in:          bufio.NewScanner(in),
+		out:         out,
+		sources:     make(map[string][]string),
+		breakpoints: make(map[int]*breakpoint),
+		statements:  make(map[int]bool),
+		lines:       make(map[ast.Statement]int),
+		ran:         make(map[ast.Statement]bool),
+		mode:        stepIn,
+	}
+}
+
+func (d *Debugger) SetFile(path string) {
+	d.interpreter.SetFile(path)
+	d.file = d.interpreter.File()
+}
+
+// Interpret runs statements under the debugger. Quitting stops them
+// without an error.
+func (d *Debugger) Interpret(statements []ast.Statement) (err error) {
+	statementLines(reflect.ValueOf(statements), d.statements)
+	d.interpreter.SetDebugger(d)
+	defer func() {
+		d.interpreter.SetDebugger(nil)
+		if r := recover(); r != nil {
+			if _, ok := r.(quit); !ok {
+				panic(r)
+			}
+			err = nil
+		}
+	}()
+	if err := d.interpreter.Interpret(statements); err != nil {
+		return err
+	}
+	fmt.Fprintln(d.out, "The script finished.")
+	return nil
+}
+
+// Before stops before a statement on a new line when a breakpoint or the
+// current step says so, and runs commands until one resumes the script.
+func (d *Debugger) Before(i *interpreter.Interpreter, stmt ast.Statement) {
+	if d.mode == quitting {
+		// Finally clauses still run while the script unwinds.
+		panic(quit{})
+	}
+	n, ok := d.lines[stmt]
+	if !ok {
+		n, _ = statementLine(stmt)
+		d.lines[stmt] = n
+	}
+	if n == 0 {
+		return
+	}
+	here := location{file: i.File(), line: n, depth: i.Depth()}
+	if here == d.last && !d.ran[stmt] {
+		d.ran[stmt] = true
+		return
+	}
+	d.last = here
+	d.ran = map[ast.Statement]bool{stmt: true}
+	hit := d.hit(i, here)
+	if !hit && !d.stepped(here) {
+		return
+	}
+
+	d.frames, d.selected = i.Frames(here.line), 0
+	what := "Stopped"
+	if hit {
+		what = "Breakpoint"
+	}
+	fmt.Fprintf(d.out, "%s in %s\n", what, describeFrame(d.frames[0]))
+	d.showLine(here.file, here.line)
+	for d.mode != quitting {
+		fmt.Fprint(d.out, "(glox) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.mode = quitting
+			break
+		}
+		command := strings.TrimSpace(d.in.Text())
+		if command == "" {
+			command = d.previous
+		}
+		d.previous = command
+		if d.run(command) {
+			d.frames = nil
+			return
+		}
+	}
+	panic(quit{})
+}
+
+// statementLine returns the line where the debugger sees stmt start, or
+// 0 and false for statements it doesn't stop at.
+func statementLine(stmt ast.Statement) (int, bool) {
+	if _, ok := stmt.(*ast.BlockStmt); ok {
+		// The debugger stops at the statements of the block instead.
+		return 0, false
+	}
+	tok, ok := ast.FirstToken(stmt)
+	return tok.Line, ok
+}
+
+// statementLines records in lines where the statements in v start,
+// including those in function and lambda bodies.
+func statementLines(v reflect.Value, lines map[int]bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		switch node := v.Interface().(type) {
+		case *ast.ClassStmt:
+			// Methods are declared with the class, only their bodies run.
+			if n, ok := statementLine(node); ok {
+				lines[n] = true
+			}
+			for _, method := range node.Methods {
+				statementLines(reflect.ValueOf(method.(*ast.FunStmt).Body), lines)
+			}
+			return
+		case ast.Statement:
+			if n, ok := statementLine(node); ok {
+				lines[n] = true
+			}
+		}
+		statementLines(v.Elem(), lines)
+	case reflect.Slice:
+		for n := 0; n < v.Len(); n++ {
+			statementLines(v.Index(n), lines)
+		}
+	case reflect.Struct:
+		for n := 0; n < v.NumField(); n++ {
+			statementLines(v.Field(n), lines)
+		}
+	}
+}
+
+// hit reports whether a breakpoint stops the script at here. A condition
+// that fails to evaluate stops it too, so the error can be looked into.
+func (d *Debugger) hit(i *interpreter.Interpreter, here location) bool {
+	b := d.breakpoints[here.line]
+	if b == nil || here.file != d.file {
+		return false
+	}
+	if b.condition == nil {
+		return true
+	}
+	value, err := i.Evaluate(b.condition, i.Frames(here.line)[0].Environment)
+	if err != nil {
+		fmt.Fprintf(d.out, "Cannot evaluate the condition of the breakpoint on line %d: %s\n", b.line, describe(err))
+		return true
+	}
+	return interpreter.IsTruthy(value)
+}
+
+// stepped reports whether the current step ends at here.
+func (d *Debugger) stepped(here location) bool {
+	switch d.mode {
+	case stepIn:
+		return true
+	case stepOver:
+		return here.depth <= d.depth
+	case stepOut:
+		return here.depth < d.depth
+	}
+	return false
+}
+
+// source returns the lines of a file, or nil if it can't be read.
+func (d *Debugger) source(file string) []string {
+	if lines, ok := d.sources[file]; ok {
+		return lines
+	}
+	var lines []string
+	if text, err := os.ReadFile(file); err == nil {
+		lines = strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+		for n, line := range lines {
+			lines[n] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	d.sources[file] = lines
+	return lines
+}
+
+// showLine prints a line of a file, marking breakpoints and the line where
+// the script stopped.
+func (d *Debugger) showLine(file string, line int) {
+	lines := d.source(file)
+	if line < 1 || line > len(lines) {
+		return
+	}
+	mark := []byte("  ")
+	if file == d.file && d.breakpoints[line] != nil {
+		mark[0] = '*'
+	}
+	if file == d.last.file && line == d.last.line {
+		mark[1] = '>'
+	}
+	fmt.Fprintf(d.out, "%s%4d  %s\n", mark, line, lines[line-1])
+}
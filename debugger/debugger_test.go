@@ -0,0 +1,288 @@
+package debugger
+
+import (
+	"Glox/ast"
+	"Glox/interpreter"
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const script = `fun inner(x) {
+  var y = x * 2;
+  return y;
+}
+fun outer(x) {
+  var z = inner(x);
+  return z + 1;
+}
+var a = outer(1);
+var b = outer(2);
+print a + b;
+`
+
+// parse parses and resolves source, failing the test on any error.
+func parse(t testing.TB, source string) []ast.Statement {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	r := resolver.NewResolver()
+	if len(s.Errors()) == 0 && len(p.Errors()) == 0 {
+		r.Resolve(statements)
+	}
+	if errors := append(append(s.Errors(), p.Errors()...), r.Errors()...); len(errors) > 0 {
+		t.Fatalf("compiling %q: %s", source, strings.Join(errors, "; "))
+	}
+	return statements
+}
+
+// debug runs script under the debugger, reading commands from a line each
+// of commands. It returns what the debugger wrote and what the script
+// printed.
+func debug(t *testing.T, commands ...string) (string, string) {
+	t.Helper()
+	return debugSource(t, script, commands...)
+}
+
+// debugSource is debug for another script.
+func debugSource(t *testing.T, source string, commands ...string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	statements := parse(t, source)
+	var transcript bytes.Buffer
+	d := NewDebugger(strings.NewReader(strings.Join(commands, "\n")+"\n"), &transcript)
+	d.SetFile(path)
+	var err error
+	output := captureStdout(t, func() {
+		err = d.Interpret(statements)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transcript.String(), output
+}
+
+// stops returns the places the debugger stopped at in a transcript.
+func stops(transcript string) []string {
+	var stops []string
+	for _, line := range strings.Split(transcript, "\n") {
+		line = strings.TrimPrefix(line, "(glox) ")
+		if strings.HasPrefix(line, "Stopped in ") || strings.HasPrefix(line, "Breakpoint in ") {
+			stops = append(stops, strings.TrimSuffix(line, " of script.lox"))
+		}
+	}
+	return stops
+}
+
+func TestBreakpoints(t *testing.T) {
+	transcript, output := debug(t, "break 2", "continue", "print x", "c", "print x", "c")
+	want := []string{
+		"Stopped in <script>, line 1",
+		"Breakpoint in inner, line 2",
+		"Breakpoint in inner, line 2",
+	}
+	if got := stops(transcript); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stopped at\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(transcript, "(glox) 1\n") || !strings.Contains(transcript, "(glox) 2\n") {
+		t.Errorf("the arguments of the two calls are missing from\n%s", transcript)
+	}
+	if output != "8\n" || !strings.HasSuffix(transcript, "The script finished.\n") {
+		t.Errorf("the script printed %q and the debugger ended with\n%s", output, transcript)
+	}
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	transcript, _ := debug(t, "b 2 if x == 2", "c", "p x", "c")
+	want := []string{"Stopped in <script>, line 1", "Breakpoint in inner, line 2"}
+	if got := stops(transcript); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stopped at %q, want %q", got, want)
+	}
+	if !strings.Contains(transcript, "Breakpoint on line 2 if x == 2.\n") || !strings.Contains(transcript, "(glox) 2\n") {
+		t.Errorf("got\n%s", transcript)
+	}
+}
+
+func TestBreakpointsInLoops(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		commands []string
+		want     []string
+		output   string
+	}{
+		{
+			"conditional",
+			"var s = 0;\nfor (var i = 0; i < 5; i++) {\n  s += i;\n}\nprint s;\n",
+			[]string{"break 3 if i == 3", "c", "p i", "c"},
+			[]string{"<script>, line 1", "<script>, line 3"},
+			"10\n",
+		},
+		{
+			"every iteration",
+			"var s = 0;\nvar i = 0;\nwhile (i < 3) {\n  s += i; i++;\n}\nprint s;\n",
+			[]string{"b 4", "c", "c", "c", "c"},
+			[]string{"<script>, line 1", "<script>, line 4", "<script>, line 4", "<script>, line 4"},
+			"3\n",
+		},
+		{
+			"step",
+			"var s = 0;\nfor (var i = 0; i < 3; i++) s += i;\nprint s;\n",
+			[]string{"s", "s", "s", "s", "s", "c"},
+			[]string{"<script>, line 1", "<script>, line 2", "<script>, line 2", "<script>, line 2", "<script>, line 3"},
+			"3\n",
+		},
+	}
+	for _, test := range tests {
+		transcript, output := debugSource(t, test.source, test.commands...)
+		var got []string
+		for _, stop := range stops(transcript) {
+			got = append(got, stop[strings.Index(stop, " in ")+4:])
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: stopped at %q, want %q", test.name, got, test.want)
+		}
+		if output != test.output {
+			t.Errorf("%s: the script printed %q, want %q", test.name, output, test.output)
+		}
+		if test.name == "conditional" && !strings.Contains(transcript, "(glox) 3\n") {
+			t.Errorf("i is not 3 at the breakpoint in\n%s", transcript)
+		}
+	}
+}
+
+func TestBreakpointOnEmptyLine(t *testing.T) {
+	// Line 4 only closes a function, so the breakpoint moves to line 5.
+	transcript, _ := debug(t, "b 4", "breakpoints", "delete 5", "breakpoints", "c")
+	for _, want := range []string{
+		"No statement starts on line 4.\nBreakpoint on line 5.\n",
+		"(glox)   line 5\n",
+		"Deleted the breakpoint on line 5.\n",
+		"(glox) No breakpoints.\n",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("%q is missing from\n%s", want, transcript)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		want     []string
+	}{
+		{
+			"in",
+			[]string{"step", "s", "s", "s", "", "", "c"},
+			[]string{"<script>, line 1", "<script>, line 5", "<script>, line 9", "outer, line 6", "inner, line 2", "inner, line 3", "outer, line 7"},
+		},
+		{
+			"over",
+			[]string{"b 6", "c", "next", "n", "n", "c"},
+			[]string{"<script>, line 1", "outer, line 6", "outer, line 7", "<script>, line 10", "outer, line 6"},
+		},
+		{
+			"out",
+			[]string{"b 2", "c", "out", "o", "c", "c"},
+			[]string{"<script>, line 1", "inner, line 2", "outer, line 7", "<script>, line 10", "inner, line 2"},
+		},
+	}
+	for _, test := range tests {
+		transcript, output := debug(t, test.commands...)
+		var got []string
+		for _, stop := range stops(transcript) {
+			got = append(got, stop[strings.Index(stop, " in ")+4:])
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("step %s: stopped at %q, want %q", test.name, got, test.want)
+		}
+		if output != "8\n" {
+			t.Errorf("step %s: the script printed %q", test.name, output)
+		}
+	}
+}
+
+func TestBacktrace(t *testing.T) {
+	transcript, _ := debug(t, "b 3", "c", "bt", "frame 1", "locals", "q")
+	want := "" +
+		"(glox) > #0  inner, line 3 of script.lox\n" +
+		"  #1  outer, line 6 of script.lox\n" +
+		"  #2  <script>, line 9 of script.lox\n" +
+		"(glox) #1  outer, line 6 of script.lox\n" +
+		"     6    var z = inner(x);\n" +
+		"(glox) scope 0:\n" +
+		"  x = 1\n" +
+		"(glox) "
+	if !strings.HasSuffix(transcript, want) {
+		t.Errorf("got\n%s\nwant it to end with\n%s", transcript, want)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	for _, commands := range [][]string{{"quit"}, {}} {
+		transcript, output := debug(t, commands...)
+		if output != "" || strings.Contains(transcript, "The script finished.") {
+			t.Errorf("%q: the script ran on, printing %q", commands, output)
+		}
+	}
+}
+
+// BenchmarkHook measures what the debugger costs a script that runs
+// without stopping, compared with running it on the interpreter alone.
+func BenchmarkHook(b *testing.B) {
+	statements := parse(b, `
+var sum = 0;
+for (var i = 0; i < 10000; i++) {
+  if (i % 3 == 0) sum = sum + i;
+}`)
+	b.Run("without", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if err := interpreter.NewInterpreter().Interpret(statements); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("with", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			d := NewDebugger(strings.NewReader("continue\n"), io.Discard)
+			if err := d.Interpret(statements); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// captureStdout returns what f prints, print statements write to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		io.Copy(&out, reader)
+		done <- out.String()
+	}()
+	f()
+	writer.Close()
+	return <-done
+}
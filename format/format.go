@@ -0,0 +1,63 @@
+// Package format prints Glox source in its canonical layout, keeping the
+// comments.
+package format
+
+import (
+	"Glox/ast"
+	"Glox/parser"
+	"Glox/scanner"
+	"Glox/token"
+	"errors"
+	"strings"
+)
+
+// SyntaxError lists the scanner or parser errors that kept a script from
+// being formatted.
+type SyntaxError struct {
+	Errors []string
+}
+
+func (e *SyntaxError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+// Source formats a script. The result parses to the same tree as the
+// script and keeps all of its comments; Source checks both before
+// returning it.
+func Source(source string) (string, error) {
+	statements, tokens, err := parse(source)
+	if err != nil {
+		return "", err
+	}
+	formatted := ast.Format(statements, tokens)
+	again, againTokens, err := parse(formatted)
+	if err != nil || !ast.Equal(statements, again) || countComments(againTokens) != countComments(tokens) {
+		return "", errors.New("internal error: the formatted script doesn't parse back to the same program")
+	}
+	return formatted, nil
+}
+
+// parse returns the tree of a script and its tokens with trivia.
+func parse(source string) ([]ast.Statement, []token.Token, error) {
+	s := scanner.NewTriviaScanner(source)
+	tokens := s.ScanTokens()
+	if len(s.Errors()) > 0 {
+		return nil, nil, &SyntaxError{s.Errors()}
+	}
+	p := parser.NewParser(scanner.WithoutTrivia(tokens))
+	statements := p.Parse()
+	if len(p.Errors()) > 0 {
+		return nil, nil, &SyntaxError{p.Errors()}
+	}
+	return statements, tokens, nil
+}
+
+func countComments(tokens []token.Token) int {
+	count := 0
+	for _, tok := range tokens {
+		if tok.Type == token.COMMENT {
+			count++
+		}
+	}
+	return count
+}
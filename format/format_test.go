@@ -0,0 +1,96 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSource formats each script in testdata and compares the result with
+// the .golden file next to it.
+func TestSource(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range scripts {
+		source, err := os.ReadFile(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(strings.TrimSuffix(script, ".lox") + ".golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Source(string(source))
+		if err != nil {
+			t.Errorf("%s: %s", script, err)
+			continue
+		}
+		if got != string(want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", script, got, want)
+		}
+	}
+}
+
+// TestIdempotent formats formatted scripts, which must not change, among
+// them those of the tests of other packages.
+func TestIdempotent(t *testing.T) {
+	var scripts []string
+	for _, pattern := range []string{"testdata/*.golden", "../*/testdata/*.lox"} {
+		matches, err := filepath.Glob(filepath.FromSlash(pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts = append(scripts, matches...)
+	}
+	for _, script := range scripts {
+		source, err := os.ReadFile(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once, err := Source(string(source))
+		if err != nil {
+			t.Errorf("%s: %s", script, err)
+			continue
+		}
+		if twice, err := Source(once); err != nil || twice != once {
+			t.Errorf("%s: formatting again gives %v\n%s\nafter\n%s", script, err, twice, once)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var a = 1;   // one\n", "var a = 1; // one\n"},
+		{"var a = /* one */ 1;\n", "var a = /* one */ 1;\n"},
+		{"f(a /* one */, b);\n", "f(a /* one */, b);\n"},
+		{"f(/* none */);\n", "f(/* none */);\n"},
+		{"{ // open\n}\n", "{} // open\n"},
+		{"print 1; /* a\nb */ print 2;\n", "print 1;\n/* a\nb */\nprint 2;\n"},
+		{"var a = 1;\n\n\n// after blank lines\nvar b = 2;\n", "var a = 1;\n\n// after blank lines\nvar b = 2;\n"},
+		{"fun f() {\n\n  // first\n  return 1;\n}\n", "fun f() {\n  // first\n  return 1;\n}\n"},
+		{"var l = [\n  1, // one\n  2,\n];\n", "var l = [\n  1, // one\n  2,\n];\n"},
+		{"print 1;\n// the end\n", "print 1;\n// the end\n"},
+		{"// only a comment\n", "// only a comment\n"},
+	}
+	for _, test := range tests {
+		got, err := Source(test.source)
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %q, %v, want %q", test.source, got, err, test.want)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	for _, source := range []string{`var = 1;`, `print "open;`, `/* open`} {
+		_, err := Source(source)
+		if syntaxErr, ok := err.(*SyntaxError); !ok || len(syntaxErr.Errors) == 0 {
+			t.Errorf("%q: got %v, want a syntax error", source, err)
+		}
+	}
+}
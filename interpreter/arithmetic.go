@@ -0,0 +1,158 @@
+package interpreter
+
+import (
+	"Glox/token"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Numbers are either integers (int64) or floats (float64). Arithmetic on two
+// integers is exact and overflow is a runtime error. As soon as one operand is
+// a float the other one is promoted and the result is a float.
+//
+// '/' always divides as floats, '\' is integer division and '%' the
+// remainder. Both truncate toward zero, so the remainder takes the sign of
+// the dividend.
+
+// arithmetic applies a numeric binary operator. Both operands must already
+// be known to be numbers.
+func arithmetic(operator token.Token, left interface{}, right interface{}) interface{} {
+	a, aInt := left.(int64)
+	b, bInt := right.(int64)
+	if aInt && bInt && operator.Type != token.SLASH {
+		return intArithmetic(operator, a, b)
+	}
+
+	x, y := toFloat(left), toFloat(right)
+	switch operator.Type {
+	case token.PLUS:
+		return x + y
+	case token.MINUS:
+		return x - y
+	case token.STAR:
+		return x * y
+	case token.SLASH:
+		checkDivisor(operator, y == 0)
+		return x / y
+	case token.BACKSLASH:
+		checkDivisor(operator, y == 0)
+		return math.Trunc(x / y)
+	case token.PERCENT:
+		checkDivisor(operator, y == 0)
+		return math.Mod(x, y)
+	}
+	return nil
+}
+
+func intArithmetic(operator token.Token, a int64, b int64) int64 {
+	switch operator.Type {
+	case token.PLUS:
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			overflow(operator)
+		}
+		return a + b
+	case token.MINUS:
+		if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+			overflow(operator)
+		}
+		return a - b
+	case token.STAR:
+		if a != 0 && b != 0 {
+			c := a * b
+			if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+				overflow(operator)
+			}
+		}
+		return a * b
+	case token.BACKSLASH:
+		checkDivisor(operator, b == 0)
+		if a == math.MinInt64 && b == -1 {
+			overflow(operator)
+		}
+		return a / b
+	case token.PERCENT:
+		checkDivisor(operator, b == 0)
+		if b == -1 {
+			// a % -1 is always 0, and MinInt64 % -1 traps on some platforms.
+			return 0
+		}
+		return a % b
+	}
+	return 0
+}
+
+// compare applies an ordering operator to two numbers.
+func compare(operator token.Token, left interface{}, right interface{}) bool {
+	a, aInt := left.(int64)
+	b, bInt := right.(int64)
+	if !aInt || !bInt {
+		x, y := toFloat(left), toFloat(right)
+		switch operator.Type {
+		case token.GREATER:
+			return x > y
+		case token.GREATER_EQUAL:
+			return x >= y
+		case token.LESS:
+			return x < y
+		default:
+			return x <= y
+		}
+	}
+	switch operator.Type {
+	case token.GREATER:
+		return a > b
+	case token.GREATER_EQUAL:
+		return a >= b
+	case token.LESS:
+		return a < b
+	default:
+		return a <= b
+	}
+}
+
+func negate(operator token.Token, operand interface{}) interface{} {
+	if n, ok := operand.(int64); ok {
+		if n == math.MinInt64 {
+			overflow(operator)
+		}
+		return -n
+	}
+	return -operand.(float64)
+}
+
+func toFloat(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+func checkDivisor(operator token.Token, isZero bool) {
+	if isZero {
+		runtimeError(operator, "Division by zero.")
+	}
+}
+
+func overflow(operator token.Token) {
+	runtimeError(operator, "Integer overflow.")
+}
+
+// formatFloat prints floats in plain decimal notation unless they are very
+// large or very small, and always with a fractional part or an exponent so
+// they can't be mistaken for integers.
+func formatFloat(f float64) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	var text string
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		text = strconv.FormatFloat(f, 'g', -1, 64)
+	} else {
+		text = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if !strings.ContainsAny(text, ".e") {
+		text += ".0"
+	}
+	return text
+}
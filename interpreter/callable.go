@@ -0,0 +1,32 @@
+package interpreter
+
+import (
+	"Glox/token"
+)
+
+// Callable is implemented by every value that can be invoked with a call
+// expression. Arity reports the number of expected arguments, or -1 when the
+// callable accepts any number of them.
+type Callable interface {
+	Arity() int
+	Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{}
+}
+
+// NativeFunction is a function implemented in Go and exposed to Lox code.
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(i *Interpreter, paren token.Token, arguments []interface{}) interface{}
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return n.fn(i, paren, arguments)
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn " + n.name + ">"
+}
@@ -0,0 +1,143 @@
+package interpreter
+
+import (
+	"Glox/token"
+)
+
+// LoxClass is a class declared in Lox code. Calling it creates an instance
+// and runs its init method, if any.
+type LoxClass struct {
+	name       string
+	superclass *LoxClass
+	methods    map[string]*LoxFunction
+	getters    map[string]*LoxFunction
+	setters    map[string]*LoxFunction
+	statics    map[string]*LoxFunction
+}
+
+func NewLoxClass(name string, superclass *LoxClass) *LoxClass {
+	return &LoxClass{
+		name:       name,
+		superclass: superclass,
+		methods:    make(map[string]*LoxFunction),
+		getters:    make(map[string]*LoxFunction),
+		setters:    make(map[string]*LoxFunction),
+		statics:    make(map[string]*LoxFunction),
+	}
+}
+
+// FindMethod looks a method up in the class and then in its superclasses.
+func (c *LoxClass) FindMethod(name string) *LoxFunction {
+	return c.find(name, func(c *LoxClass) map[string]*LoxFunction { return c.methods })
+}
+
+func (c *LoxClass) FindGetter(name string) *LoxFunction {
+	return c.find(name, func(c *LoxClass) map[string]*LoxFunction { return c.getters })
+}
+
+func (c *LoxClass) FindSetter(name string) *LoxFunction {
+	return c.find(name, func(c *LoxClass) map[string]*LoxFunction { return c.setters })
+}
+
+func (c *LoxClass) FindStatic(name string) *LoxFunction {
+	return c.find(name, func(c *LoxClass) map[string]*LoxFunction { return c.statics })
+}
+
+func (c *LoxClass) find(name string, members func(c *LoxClass) map[string]*LoxFunction) *LoxFunction {
+	for class := c; class != nil; class = class.superclass {
+		if member, ok := members(class)[name]; ok {
+			return member
+		}
+	}
+	return nil
+}
+
+// Get returns a static method bound to the class itself.
+func (c *LoxClass) Get(name token.Token) interface{} {
+	if method := c.FindStatic(name.Lexeme); method != nil {
+		return method.Bind(c)
+	}
+	runtimeError(name, "Undefined static method '%s' on class %s.", name.Lexeme, c.name)
+	return nil
+}
+
+func (c *LoxClass) Arity() int {
+	if initializer := c.FindMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+func (c *LoxClass) Call(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	instance := NewLoxInstance(c)
+	if initializer := c.FindMethod("init"); initializer != nil {
+		initializer.Bind(instance).Call(i, paren, arguments)
+	}
+	return instance
+}
+
+func (c *LoxClass) String() string {
+	return c.name
+}
+
+// LoxInstance is an object created by calling a class.
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]interface{}
+}
+
+func NewLoxInstance(class *LoxClass) *LoxInstance {
+	return &LoxInstance{class: class, fields: make(map[string]interface{})}
+}
+
+// Get returns a field, the result of a getter or a method bound to the
+// instance, in that order.
+func (o *LoxInstance) Get(i *Interpreter, name token.Token) interface{} {
+	if value, ok := o.lookup(i, name); ok {
+		return value
+	}
+	if method := o.class.FindMethod(name.Lexeme); method != nil {
+		return method.Bind(o)
+	}
+	runtimeError(name, "Undefined property '%s'.", name.Lexeme)
+	return nil
+}
+
+// lookup returns a field or the result of a getter, reporting whether the
+// instance has either.
+func (o *LoxInstance) lookup(i *Interpreter, name token.Token) (interface{}, bool) {
+	if value, ok := o.fields[name.Lexeme]; ok {
+		return value, true
+	}
+	if getter := o.class.FindGetter(name.Lexeme); getter != nil {
+		return getter.Bind(o).Call(i, name, nil), true
+	}
+	return nil, false
+}
+
+// IsA reports whether the instance's class is class or inherits from it.
+func (o *LoxInstance) IsA(class *LoxClass) bool {
+	for c := o.class; c != nil; c = c.superclass {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Set runs the property's setter if the class has one. A property with only
+// a getter is read-only.
+func (o *LoxInstance) Set(i *Interpreter, name token.Token, value interface{}) {
+	if setter := o.class.FindSetter(name.Lexeme); setter != nil {
+		setter.Bind(o).Call(i, name, []interface{}{value})
+		return
+	}
+	if o.class.FindGetter(name.Lexeme) != nil {
+		runtimeError(name, "Property '%s' has a getter but no setter.", name.Lexeme)
+	}
+	o.fields[name.Lexeme] = value
+}
+
+func (o *LoxInstance) String() string {
+	return o.class.name + " instance"
+}
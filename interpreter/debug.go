@@ -0,0 +1,97 @@
+package interpreter
+
+import (
+	"Glox/ast"
+	"sort"
+)
+
+// Debugger is told about every statement before the interpreter runs it.
+// Before may block, for example to wait for a command, and inspect the
+// interpreter meanwhile.
+type Debugger interface {
+	Before(i *Interpreter, stmt ast.Statement)
+}
+
+// SetDebugger attaches d to the interpreter, or detaches the debugger when
+// d is nil. Without one, running a statement costs a single nil check.
+func (i *Interpreter) SetDebugger(d Debugger) {
+	i.debugger = d
+}
+
+// File returns the canonical path of the file the running code comes from,
+// or "" in the REPL.
+func (i *Interpreter) File() string {
+	return i.frames[len(i.frames)-1].file
+}
+
+// Depth returns the number of frames on the call stack, 1 in the script.
+func (i *Interpreter) Depth() int {
+	return len(i.frames)
+}
+
+// Frame is a call on the stack as a debugger sees it.
+type Frame struct {
+	Name string
+	File string
+	// Line is the line running in the frame, or for outer frames the line
+	// of the call the frame is waiting for.
+	Line int
+	// Environment is the innermost scope of the frame.
+	Environment *Environment
+}
+
+// Frames describes the call stack, innermost frame first. line is the line
+// being executed in the innermost frame.
+func (i *Interpreter) Frames(line int) []Frame {
+	frames := make([]Frame, 0, len(i.frames))
+	environment := i.environment
+	for n := len(i.frames) - 1; n >= 0; n-- {
+		frames = append(frames, Frame{Name: i.frames[n].name, File: i.frames[n].file, Line: line, Environment: environment})
+		line = i.frames[n].call.Line
+		environment = i.frames[n].caller
+	}
+	return frames
+}
+
+// Evaluate evaluates expr in environment and returns its value, or the
+// runtime error or throw that stopped it, without a stack trace. The
+// debugger is detached meanwhile, so the functions expr calls run without
+// stopping.
+func (i *Interpreter) Evaluate(expr ast.Expression, environment *Environment) (value interface{}, err error) {
+	debugger, current, depth := i.debugger, i.environment, len(i.frames)
+	defer func() {
+		i.debugger, i.environment, i.frames = debugger, current, i.frames[:depth]
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *RuntimeError:
+				err = r
+			case *Throw:
+				err = r
+			default:
+				panic(r)
+			}
+		}
+	}()
+	i.debugger, i.environment = nil, environment
+	return i.evaluate(expr), nil
+}
+
+// Enclosing returns the scope e is nested in, or nil for the globals.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// Names returns the names defined in e itself, sorted.
+func (e *Environment) Names() []string {
+	names := append([]string{}, e.names...)
+	sort.Strings(names)
+	return names
+}
+
+// Value returns the value of a name defined in e itself.
+func (e *Environment) Value(name string) interface{} {
+	if slot := e.slot(name); slot >= 0 {
+		return e.values[slot]
+	}
+	return nil
+}
@@ -2,47 +2,128 @@ package interpreter
 
 import (
 	"Glox/token"
-	"fmt"
 )
 
+// Environment is a scope of the running program. Its values are kept in
+// declaration order, so that the slots the resolver assigns find them.
 type Environment struct {
 	enclosing *Environment
-	values    map[string]interface{}
+	names     []string
+	values    []interface{}
+	// index maps names to slots once a scope holds many of them, as the
+	// globals do. Smaller scopes are searched.
+	index map[string]int
+	// consts maps each constant in this scope to its declaration.
+	consts map[string]token.Token
 }
 
+// indexed is the number of names from which a scope indexes them.
+const indexed = 8
+
 func NewEnvironment() *Environment {
-	e := &Environment{
-		values: make(map[string]interface{}),
-	}
-	return e
+	return &Environment{}
 }
 
 func NewEnclosedEnvironment(enclosing *Environment) *Environment {
-	e := NewEnvironment()
-	e.enclosing = enclosing
-	return e
+	return &Environment{enclosing: enclosing}
+}
+
+// slot returns the slot of name in e itself, or -1.
+func (e *Environment) slot(name string) int {
+	if e.index != nil {
+		if slot, ok := e.index[name]; ok {
+			return slot
+		}
+		return -1
+	}
+	for slot := len(e.names) - 1; slot >= 0; slot-- {
+		if e.names[slot] == name {
+			return slot
+		}
+	}
+	return -1
 }
 
+// Define binds name in e, in a new slot unless e already has the name.
 func (e *Environment) Define(name string, value interface{}) {
-	e.values[name] = value
+	if slot := e.slot(name); slot >= 0 {
+		e.values[slot] = value
+		return
+	}
+	e.names = append(e.names, name)
+	e.values = append(e.values, value)
+	if e.index != nil {
+		e.index[name] = len(e.names) - 1
+	} else if len(e.names) == indexed {
+		e.index = make(map[string]int, indexed)
+		for slot, name := range e.names {
+			e.index[name] = slot
+		}
+	}
 }
 
-func (e *Environment) Get(name token.Token) interface{} {
-	if value, ok := e.values[name.Lexeme]; ok {
-		return value
+// DefineConst defines a binding that Assign and Declare refuse to change.
+func (e *Environment) DefineConst(name token.Token, value interface{}) {
+	e.Declare(name)
+	e.Define(name.Lexeme, value)
+	if e.consts == nil {
+		e.consts = make(map[string]token.Token)
+	}
+	e.consts[name.Lexeme] = name
+}
+
+// Declare checks that name may be (re)declared in this scope, which is not
+// the case if it is already a constant here.
+func (e *Environment) Declare(name token.Token) {
+	if declaration, ok := e.consts[name.Lexeme]; ok {
+		runtimeError(name, "Cannot redeclare constant '%s' declared at Ln %d, Col %d.", name.Lexeme, declaration.Line, declaration.Col)
 	}
-	if e.enclosing != nil {
-		return e.enclosing.Get(name)
+}
+
+// Get looks name up by name, from e outwards.
+func (e *Environment) Get(name token.Token) interface{} {
+	for environment := e; environment != nil; environment = environment.enclosing {
+		if slot := environment.slot(name.Lexeme); slot >= 0 {
+			return environment.values[slot]
+		}
 	}
-	panic(fmt.Errorf("%s Undefined variable '%s'", name.ToString(), name.Lexeme))
+	runtimeError(name, "Undefined variable '%s'.", name.Lexeme)
+	return nil
 }
 
+// Assign sets name by name, from e outwards.
 func (e *Environment) Assign(name token.Token, value interface{}) {
-	if _, ok := e.values[name.Lexeme]; ok {
-		e.values[name.Lexeme] = value
+	for environment := e; environment != nil; environment = environment.enclosing {
+		if slot := environment.slot(name.Lexeme); slot >= 0 {
+			environment.assign(slot, name, value)
+			return
+		}
+	}
+	runtimeError(name, "Undefined variable '%s'.", name.Lexeme)
+}
+
+// GetAt returns the value in slot of the scope depth scopes out from e.
+func (e *Environment) GetAt(depth int, slot int) interface{} {
+	return e.ancestor(depth).values[slot]
+}
+
+// AssignAt sets the value in slot of the scope depth scopes out from e,
+// which binds name.
+func (e *Environment) AssignAt(depth int, slot int, name token.Token, value interface{}) {
+	e.ancestor(depth).assign(slot, name, value)
+}
+
+func (e *Environment) assign(slot int, name token.Token, value interface{}) {
+	if declaration, ok := e.consts[name.Lexeme]; ok {
+		runtimeError(name, "Cannot assign to constant '%s' declared at Ln %d, Col %d.", name.Lexeme, declaration.Line, declaration.Col)
 	}
-	if e.enclosing != nil {
-		e.enclosing.Assign(name, value)
+	e.values[slot] = value
+}
+
+func (e *Environment) ancestor(depth int) *Environment {
+	environment := e
+	for n := 0; n < depth; n++ {
+		environment = environment.enclosing
 	}
-	panic(fmt.Errorf("%s Undefined variable '%s'", name.ToString(), name.Lexeme))
+	return environment
 }
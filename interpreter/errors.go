@@ -0,0 +1,122 @@
+package interpreter
+
+import (
+	"Glox/token"
+	"fmt"
+	"strings"
+)
+
+// RuntimeError is raised when evaluation fails, for example on operands of
+// the wrong type or an undefined variable. A try statement catches it as a
+// *LoxError value.
+type RuntimeError struct {
+	Token   token.Token
+	Message string
+	Stack   []string // filled in while unwinding, innermost frame first.
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s %s%s", e.Token.ToString(), e.Message, formatStack(e.Stack))
+}
+
+// Throw carries the value of a throw statement up to the nearest catch.
+type Throw struct {
+	Keyword token.Token
+	Value   interface{}
+	Stack   []string
+}
+
+func (t *Throw) Error() string {
+	if err, ok := t.Value.(*LoxError); ok {
+		return fmt.Sprintf("%s Uncaught error: %s%s", t.Keyword.ToString(), err.Message, formatStack(err.Stack))
+	}
+	return fmt.Sprintf("%s Uncaught exception: %s%s", t.Keyword.ToString(), Stringify(t.Value), formatStack(t.Stack))
+}
+
+// LoxError is the error object seen by Lox code. Runtime errors are caught
+// as LoxError values and scripts can build their own with Error(message).
+type LoxError struct {
+	Message string
+	Line    int
+	Stack   []string
+}
+
+// Get reads the message, line and stack properties of the error.
+func (e *LoxError) Get(name token.Token) interface{} {
+	switch name.Lexeme {
+	case "message":
+		return e.Message
+	case "line":
+		return int64(e.Line)
+	case "stack":
+		stack := make([]interface{}, len(e.Stack))
+		for n, entry := range e.Stack {
+			stack[n] = entry
+		}
+		return NewList(stack)
+	}
+	runtimeError(name, "Undefined property '%s'.", name.Lexeme)
+	return nil
+}
+
+func (e *LoxError) String() string {
+	return "Error: " + e.Message
+}
+
+// runtimeError aborts the evaluation, reporting the position of tok.
+func runtimeError(tok token.Token, format string, args ...interface{}) {
+	panic(&RuntimeError{Token: tok, Message: fmt.Sprintf(format, args...)})
+}
+
+// frame is an entry of the call stack kept for stack traces and debuggers.
+type frame struct {
+	name   string
+	file   string       // canonical path of the file the code comes from.
+	call   token.Token  // call site in the caller, zero for the script frame.
+	caller *Environment // environment of the caller at the call.
+}
+
+// maxFrames bounds the call depth, so runaway recursion is reported instead
+// of overflowing the Go stack. Every call nests a few Go calls per statement
+// and expression of the body, so the bound is lower than the VM's.
+const maxFrames = 1 << 16
+
+// stackTrace describes the call stack, innermost frame first. line is the
+// line being executed in the innermost frame.
+func (i *Interpreter) stackTrace(line int) []string {
+	trace := []string{}
+	for _, frame := range i.Frames(line) {
+		trace = append(trace, fmt.Sprintf("at %s (line %d)", frame.Name, frame.Line))
+	}
+	return trace
+}
+
+// capture records the stack trace of a runtime error. Frames are not popped
+// while a panic unwinds, so the first handler to see the error still has
+// the complete call stack.
+func (i *Interpreter) capture(r interface{}) {
+	if err, ok := r.(*RuntimeError); ok && err.Stack == nil {
+		err.Stack = i.stackTrace(err.Token.Line)
+	}
+}
+
+// caught turns a recovered panic into the value seen by a catch clause. It
+// reports false for panics that a catch clause must not intercept, such as
+// returns.
+func (i *Interpreter) caught(r interface{}) (interface{}, bool) {
+	switch err := r.(type) {
+	case *RuntimeError:
+		i.capture(err)
+		return &LoxError{Message: err.Message, Line: err.Token.Line, Stack: err.Stack}, true
+	case *Throw:
+		return err.Value, true
+	}
+	return nil, false
+}
+
+func formatStack(stack []string) string {
+	if len(stack) == 0 {
+		return ""
+	}
+	return "\n    " + strings.Join(stack, "\n    ")
+}
@@ -0,0 +1,92 @@
+package interpreter
+
+import (
+	"Glox/ast"
+	"Glox/token"
+)
+
+// LoxFunction is a function declared in Lox code, or a lambda, along with
+// the environment it closes over.
+type LoxFunction struct {
+	name          string
+	params        []token.Token
+	body          []ast.Statement
+	closure       *Environment
+	file          string // canonical path of the file declaring it.
+	isInitializer bool
+}
+
+func NewLoxFunction(declaration *ast.FunStmt, closure *Environment, file string, isInitializer bool) *LoxFunction {
+	return &LoxFunction{
+		name:          declaration.Name.Lexeme,
+		params:        declaration.Params,
+		body:          declaration.Body,
+		closure:       closure,
+		file:          file,
+		isInitializer: isInitializer,
+	}
+}
+
+// NewLoxLambda creates the closure for a lambda expression. Lambdas the
+// parser couldn't name show up as <lambda> in stack traces.
+func NewLoxLambda(lambda *ast.Lambda, closure *Environment, file string) *LoxFunction {
+	name := lambda.Name.Lexeme
+	if name == "" {
+		name = "<lambda>"
+	}
+	return &LoxFunction{name: name, params: lambda.Params, body: lambda.Body, closure: closure, file: file}
+}
+
+// Bind returns a copy of the method with "this" bound to an instance, or to
+// the class for static methods, as the only name of a scope of its own.
+func (f *LoxFunction) Bind(this interface{}) *LoxFunction {
+	environment := NewEnclosedEnvironment(f.closure)
+	environment.Define("this", this)
+	bound := *f
+	bound.closure = environment
+	return &bound
+}
+
+func (f *LoxFunction) Arity() int {
+	return len(f.params)
+}
+
+func (f *LoxFunction) Call(i *Interpreter, paren token.Token, arguments []interface{}) (result interface{}) {
+	environment := NewEnclosedEnvironment(f.closure)
+	for n, param := range f.params {
+		environment.Define(param.Lexeme, arguments[n])
+	}
+
+	if len(i.frames) == maxFrames {
+		runtimeError(paren, "Stack overflow.")
+	}
+	i.frames = append(i.frames, frame{name: f.name, file: f.file, call: paren, caller: i.environment})
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ret, ok := r.(*returnValue)
+				if !ok {
+					// Leave the frame in place for the stack trace.
+					panic(r)
+				}
+				result = ret.value
+			}
+		}()
+		i.executeBlock(f.body, environment)
+	}()
+	i.frames = i.frames[:len(i.frames)-1]
+	if f.isInitializer {
+		// init always returns the instance, even after a bare return.
+		return f.closure.values[0]
+	}
+	return result
+}
+
+func (f *LoxFunction) String() string {
+	return "<fn " + f.name + ">"
+}
+
+// returnValue unwinds a function body up to its call.
+type returnValue struct {
+	value interface{}
+}
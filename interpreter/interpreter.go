@@ -2,27 +2,76 @@ package interpreter
 
 import (
 	"Glox/ast"
+	"Glox/resolver"
 	"Glox/token"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type Interpreter struct {
+	globals     *Environment
 	environment *Environment
+	frames      []frame
+	debugger    Debugger
+	// bindings locates the variable each use of a name refers to.
+	bindings map[ast.Expression]resolver.Binding
+
+	file      string             // canonical path of the file being evaluated, "" in the REPL.
+	module    *Module            // module being evaluated, nil for the main script.
+	modules   map[string]*Module // evaluated modules by canonical path.
+	importing []string           // files being evaluated, to detect import cycles.
 }
 
 func NewInterpreter() *Interpreter {
 	i := &Interpreter{}
-	i.environment = NewEnvironment()
+	i.globals = NewEnclosedEnvironment(nativeScope())
+	i.environment = i.globals
+	i.frames = []frame{{name: "<script>"}}
+	i.modules = make(map[string]*Module)
+	i.bindings = make(map[ast.Expression]resolver.Binding)
 	return i
 }
 
-func (i *Interpreter) Interpret(statements []ast.Statement) {
+// Interpret executes statements and returns the runtime error or the
+// uncaught throw that stopped them, if any.
+func (i *Interpreter) Interpret(statements []ast.Statement) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			i.capture(r)
+			i.frames = i.frames[:1]
+			i.environment = i.globals
+			switch r := r.(type) {
+			case *RuntimeError:
+				err = r
+			case *Throw:
+				err = r
+			default:
+				panic(r)
+			}
+		}
+	}()
+	i.resolve(statements)
 	for _, stmt := range statements {
 		i.execute(stmt)
 	}
+	return nil
+}
+
+// resolve records where the names in statements refer to. Only statements
+// that resolve without errors are run, so the errors were reported before.
+func (i *Interpreter) resolve(statements []ast.Statement) {
+	r := resolver.NewResolver()
+	r.Resolve(statements)
+	for expr, binding := range r.Bindings() {
+		i.bindings[expr] = binding
+	}
 }
 
 func (i *Interpreter) execute(stmt ast.Statement) {
+	if i.debugger != nil {
+		i.debugger.Before(i, stmt)
+	}
 	stmt.Accept(i)
 }
 
@@ -36,7 +85,46 @@ func (i *Interpreter) VisitBlockStmt(stmt *ast.BlockStmt) interface{} {
 	return nil
 }
 
+func (i *Interpreter) VisitBreakStmt(stmt *ast.BreakStmt) interface{} {
+	panic(&breakLoop{})
+}
+
+func (i *Interpreter) VisitContinueStmt(stmt *ast.ContinueStmt) interface{} {
+	panic(&continueLoop{})
+}
+
 func (i *Interpreter) VisitClassStmt(stmt *ast.ClassStmt) interface{} {
+	var superclass *LoxClass
+	if stmt.Superclass != nil {
+		class, ok := i.evaluate(stmt.Superclass).(*LoxClass)
+		if !ok {
+			runtimeError(stmt.Superclass.Name, "Superclass must be a class.")
+		}
+		superclass = class
+	}
+	i.environment.Declare(stmt.Name)
+	i.environment.Define(stmt.Name.Lexeme, nil)
+
+	environment := i.environment
+	if superclass != nil {
+		environment = NewEnclosedEnvironment(i.environment)
+		environment.Define("super", superclass)
+	}
+	class := NewLoxClass(stmt.Name.Lexeme, superclass)
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		switch method.Kind {
+		case ast.StaticMethod:
+			class.statics[method.Name.Lexeme] = NewLoxFunction(method, environment, i.File(), false)
+		case ast.Getter:
+			class.getters[method.Name.Lexeme] = NewLoxFunction(method, environment, i.File(), false)
+		case ast.Setter:
+			class.setters[method.Name.Lexeme] = NewLoxFunction(method, environment, i.File(), false)
+		default:
+			class.methods[method.Name.Lexeme] = NewLoxFunction(method, environment, i.File(), method.Name.Lexeme == "init")
+		}
+	}
+	i.environment.Assign(stmt.Name, class)
 	return nil
 }
 
@@ -46,20 +134,74 @@ func (i *Interpreter) VisitExpressionStmt(stmt *ast.ExpressionStmt) interface{}
 }
 
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.FunStmt) interface{} {
+	i.environment.Declare(stmt.Name)
+	i.environment.Define(stmt.Name.Lexeme, NewLoxFunction(stmt, i.environment, i.File(), false))
 	return nil
 }
 
 func (i *Interpreter) VisitIfStmt(stmt *ast.IfStmt) interface{} {
+	if IsTruthy(i.evaluate(stmt.Condition)) {
+		i.execute(stmt.ThenBranch)
+	} else if stmt.ElseBranch != nil {
+		i.execute(stmt.ElseBranch)
+	}
 	return nil
 }
 
 func (i *Interpreter) VisitPrintStmt(stmt *ast.PrintStmt) interface{} {
 	value := i.evaluate(stmt.Expression)
-	fmt.Println(stringify(value))
+	fmt.Println(Stringify(value))
 	return nil
 }
 
 func (i *Interpreter) VisitReturnStmt(stmt *ast.ReturnStmt) interface{} {
+	var value interface{}
+	if stmt.Value != nil {
+		value = i.evaluate(stmt.Value)
+	}
+	panic(&returnValue{value: value})
+}
+
+func (i *Interpreter) VisitThrowStmt(stmt *ast.ThrowStmt) interface{} {
+	value := i.evaluate(stmt.Value)
+	panic(&Throw{Keyword: stmt.Keyword, Value: value, Stack: i.stackTrace(stmt.Keyword.Line)})
+}
+
+// VisitTryStmt runs the catch clause for runtime errors and thrown values.
+// The finally clause runs on every way out of the statement, including
+// returns and errors that propagate further.
+func (i *Interpreter) VisitTryStmt(stmt *ast.TryStmt) interface{} {
+	depth := len(i.frames)
+	if stmt.Finally != nil {
+		defer func() {
+			r := recover()
+			if r != nil {
+				i.capture(r)
+				i.frames = i.frames[:depth]
+			}
+			i.executeBlock(stmt.Finally.Statements, NewEnclosedEnvironment(i.environment))
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
+	func() {
+		if stmt.Catch != nil {
+			defer func() {
+				if r := recover(); r != nil {
+					value, ok := i.caught(r)
+					if !ok {
+						panic(r)
+					}
+					i.frames = i.frames[:depth]
+					environment := NewEnclosedEnvironment(i.environment)
+					environment.Define(stmt.Param.Lexeme, value)
+					i.executeBlock(stmt.Catch.Statements, environment)
+				}
+			}()
+		}
+		i.executeBlock(stmt.Body, NewEnclosedEnvironment(i.environment))
+	}()
 	return nil
 }
 
@@ -68,31 +210,114 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.VarStmt) interface{} {
 	if stmt.Initializer != nil {
 		value = i.evaluate(stmt.Initializer)
 	}
+	if stmt.Const {
+		i.environment.DefineConst(stmt.Name, value)
+		return nil
+	}
+	i.environment.Declare(stmt.Name)
 	i.environment.Define(stmt.Name.Lexeme, value)
 	return nil
 }
 
 func (i *Interpreter) VisitWhileStmt(stmt *ast.WhileStmt) interface{} {
+	for IsTruthy(i.evaluate(stmt.Condition)) {
+		if i.executeLoopBody(stmt.Body) {
+			break
+		}
+		if stmt.Increment != nil {
+			i.evaluate(stmt.Increment)
+		}
+	}
 	return nil
 }
 
 // Expressions Interpretation
 func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) interface{} {
 	value := i.evaluate(expr.Value)
-	i.environment.Assign(expr.Name, value)
+	i.assignVariable(expr, expr.Name, value)
 	return value
 }
 
 func (i *Interpreter) VisitCallExpr(expr *ast.Call) interface{} {
-	return nil
+	callee := i.evaluate(expr.Callee)
+
+	arguments := []interface{}{}
+	for _, argument := range expr.Arguments {
+		arguments = append(arguments, i.evaluate(argument))
+	}
+
+	function, ok := callee.(Callable)
+	if !ok {
+		runtimeError(expr.Paren, "Can only call functions and classes.")
+	}
+	if arity := function.Arity(); arity >= 0 && len(arguments) != arity {
+		runtimeError(expr.Paren, "Expected %d arguments but got %d.", arity, len(arguments))
+	}
+	return function.Call(i, expr.Paren, arguments)
+}
+
+func (i *Interpreter) VisitCompoundAssignExpr(expr *ast.CompoundAssign) interface{} {
+	get, set := i.reference(expr.Target)
+	current := get()
+	value := Binary(BinaryOperator(expr.Operator), current, i.evaluate(expr.Value))
+	set(value)
+	return value
+}
+
+func (i *Interpreter) VisitIncrementExpr(expr *ast.Increment) interface{} {
+	get, set := i.reference(expr.Target)
+	current := get()
+	value := Increment(expr.Operator, current)
+	set(value)
+	if expr.Prefix {
+		return value
+	}
+	return current
+}
+
+func (i *Interpreter) VisitCommaExpr(expr *ast.Comma) interface{} {
+	i.evaluate(expr.Left)
+	return i.evaluate(expr.Right)
+}
+
+func (i *Interpreter) VisitConditionalExpr(expr *ast.Conditional) interface{} {
+	if IsTruthy(i.evaluate(expr.Condition)) {
+		return i.evaluate(expr.Then)
+	}
+	return i.evaluate(expr.Else)
 }
 
 func (i *Interpreter) VisitGetExpr(expr *ast.Get) interface{} {
-	return nil
+	return i.getProperty(i.evaluate(expr.Object), expr.Name)
 }
 
 func (i *Interpreter) VisitGroupingExpr(expr *ast.Grouping) interface{} {
-	return nil
+	return i.evaluate(expr.Expression)
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *ast.Index) interface{} {
+	object := i.evaluate(expr.Object)
+	return getIndex(expr.Bracket, object, i.evaluate(expr.Index))
+}
+
+func (i *Interpreter) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	var out strings.Builder
+	for _, part := range expr.Parts {
+		out.WriteString(Stringify(i.evaluate(part)))
+	}
+	return out.String()
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	return NewLoxLambda(expr, i.environment, i.File())
+}
+
+func (i *Interpreter) VisitListExpr(expr *ast.List) interface{} {
+	elements := []interface{}{}
+	for _, element := range expr.Elements {
+		elements = append(elements, i.evaluate(element))
+	}
+	return NewList(elements)
 }
 
 func (i *Interpreter) VisitLiteralExpr(expr *ast.Literal) interface{} {
@@ -100,109 +325,300 @@ func (i *Interpreter) VisitLiteralExpr(expr *ast.Literal) interface{} {
 }
 
 func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) interface{} {
-	return nil
+	left := i.evaluate(expr.Left)
+	if expr.Operator.Type == token.OR {
+		if IsTruthy(left) {
+			return left
+		}
+	} else if !IsTruthy(left) {
+		return left
+	}
+	return i.evaluate(expr.Right)
+}
+
+func (i *Interpreter) VisitMapExpr(expr *ast.Map) interface{} {
+	m := NewMap()
+	for n, key := range expr.Keys {
+		m.Set(expr.Brace, i.evaluate(key), i.evaluate(expr.Values[n]))
+	}
+	return m
 }
 
 func (i *Interpreter) VisitSetExpr(expr *ast.Set) interface{} {
+	object := i.evaluate(expr.Object)
+	value := i.evaluate(expr.Value)
+	i.setProperty(object, expr.Name, value)
+	return value
+}
+
+func (i *Interpreter) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	value := i.evaluate(expr.Value)
+	setIndex(expr.Bracket, object, index, value)
+	return value
+}
+
+func (i *Interpreter) VisitSliceExpr(expr *ast.Slice) interface{} {
+	object := i.evaluate(expr.Object)
+	var start, end interface{}
+	if expr.Start != nil {
+		start = i.evaluate(expr.Start)
+	}
+	if expr.End != nil {
+		end = i.evaluate(expr.End)
+	}
+	if list, ok := object.(*List); ok {
+		return list.Slice(expr.Bracket, start, end)
+	}
+	runtimeError(expr.Bracket, "Only lists can be sliced.")
 	return nil
 }
 
 func (i *Interpreter) VisitSuperExpr(expr *ast.Super) interface{} {
-	return nil
+	this := token.Token{Type: token.THIS, Lexeme: "this", Line: expr.Keyword.Line, Col: expr.Keyword.Col}
+	var superclass *LoxClass
+	var object interface{}
+	if binding, ok := i.bindings[expr]; ok {
+		// "this" is bound in the scope just inside the one binding "super".
+		superclass = i.environment.GetAt(binding.Depth, binding.Slot).(*LoxClass)
+		object = i.environment.GetAt(binding.Depth-1, 0)
+	} else {
+		superclass = i.environment.Get(expr.Keyword).(*LoxClass)
+		object = i.environment.Get(this)
+	}
+	var method *LoxFunction
+	if _, ok := object.(*LoxClass); ok {
+		method = superclass.FindStatic(expr.Method.Lexeme)
+	} else {
+		method = superclass.FindMethod(expr.Method.Lexeme)
+	}
+	if method == nil {
+		runtimeError(expr.Method, "Undefined property '%s'.", expr.Method.Lexeme)
+	}
+	return method.Bind(object)
 }
 
 func (i *Interpreter) VisitThisExpr(expr *ast.This) interface{} {
-	return nil
+	return i.variable(expr, expr.Keyword)
 }
 
 func (i *Interpreter) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	return Unary(expr.Operator, i.evaluate(expr.Right))
+}
+
+func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	left := i.evaluate(expr.Left)
 	right := i.evaluate(expr.Right)
-	switch expr.Operator.Type {
+	return Binary(expr.Operator, left, right)
+}
+
+// Unary applies a prefix operator. Like Binary and Increment it is shared
+// with the bytecode VM, so both engines compute and fail the same way.
+func Unary(operator token.Token, right interface{}) interface{} {
+	switch operator.Type {
 	case token.MINUS:
-		checkNumberOperand(expr.Operator, right)
-		if num, ok := right.(float64); ok {
-			return -num
-		}
+		checkNumberOperand(operator, right)
+		return negate(operator, right)
 	case token.BANG:
-		return !isTruthy(right)
+		return !IsTruthy(right)
 	}
 	return nil
 }
 
-func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) interface{} {
-	leftEval := i.evaluate(expr.Left)
-	rightEval := i.evaluate(expr.Right)
-
-	var left float64
-	var right float64
-
-	if TypeOf(leftEval) == 'n' && TypeOf(rightEval) == 'n' {
-		left, _ = leftEval.(float64)
-		right, _ = rightEval.(float64)
-	}
+// Increment applies ++ or -- to the current value of the target.
+func Increment(operator token.Token, current interface{}) interface{} {
+	checkNumberOperand(operator, current)
+	return arithmetic(BinaryOperator(operator), current, int64(1))
+}
 
-	switch expr.Operator.Type {
+// Binary applies a binary operator to two evaluated operands.
+func Binary(operator token.Token, left interface{}, right interface{}) interface{} {
+	switch operator.Type {
 	case token.PLUS:
-		if TypeOf(leftEval) == 'n' && TypeOf(rightEval) == 'n' {
-			return left + right
+		if TypeOf(left) == 'c' && TypeOf(right) == 'c' {
+			return left.(string) + right.(string)
 		}
-		if TypeOf(leftEval) == 'c' && TypeOf(rightEval) == 'c' {
-			left, _ := leftEval.(string)
-			right, _ := rightEval.(string)
-			return left + right
+		if isNumber(left) && isNumber(right) {
+			return arithmetic(operator, left, right)
 		}
-		panic("Operands must be two numbers or two strings.")
-	case token.MINUS:
-		checkNumberOperands(expr.Operator, left, right)
-		return left - right
-	case token.STAR:
-		checkNumberOperands(expr.Operator, left, right)
-		return left * right
-	case token.SLASH:
-		// TODO: division by zero.
-		checkNumberOperands(expr.Operator, left, right)
-		return left / right
-	case token.GREATER:
-		checkNumberOperand(expr.Operator, left)
-		return left > right
-	case token.GREATER_EQUAL:
-		checkNumberOperands(expr.Operator, left, right)
-		return left >= right
-	case token.LESS:
-		checkNumberOperands(expr.Operator, left, right)
-		return left < right
-	case token.LESS_EQUAL:
-		checkNumberOperands(expr.Operator, left, right)
-		return left <= right
+		runtimeError(operator, "Operands must be two numbers or two strings.")
+		return nil
+	case token.MINUS, token.STAR, token.SLASH, token.BACKSLASH, token.PERCENT:
+		checkNumberOperands(operator, left, right)
+		return arithmetic(operator, left, right)
+	case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+		checkNumberOperands(operator, left, right)
+		return compare(operator, left, right)
 	case token.BANG_EQUAL:
-		return !isEqual(left, right)
+		return !IsEqual(left, right)
 	case token.EQUAL_EQUAL:
-		return isEqual(left, right)
+		return IsEqual(left, right)
 	default:
 		return nil
 	}
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *ast.Variable) interface{} {
-	return i.environment.Get(expr.Name)
+	return i.variable(expr, expr.Name)
 }
 
 // Interpreter helper functions
+
+// variable returns the value of the variable expr, a use of name, refers
+// to. Expressions the resolver never saw, such as those a debugger
+// evaluates, look the name up from the current scope outwards.
+func (i *Interpreter) variable(expr ast.Expression, name token.Token) interface{} {
+	binding, ok := i.bindings[expr]
+	switch {
+	case !ok:
+		return i.environment.Get(name)
+	case binding.Slot == resolver.Global:
+		return i.environment.ancestor(binding.Depth).Get(name)
+	}
+	return i.environment.GetAt(binding.Depth, binding.Slot)
+}
+
+// assignVariable sets the variable expr, an assignment to name, refers to.
+func (i *Interpreter) assignVariable(expr ast.Expression, name token.Token, value interface{}) {
+	binding, ok := i.bindings[expr]
+	switch {
+	case !ok:
+		i.environment.Assign(name, value)
+	case binding.Slot == resolver.Global:
+		i.environment.ancestor(binding.Depth).Assign(name, value)
+	default:
+		i.environment.AssignAt(binding.Depth, binding.Slot, name, value)
+	}
+}
+
+// reference evaluates the object and index of an assignment target once and
+// returns accessors for the location it denotes.
+func (i *Interpreter) reference(target ast.Expression) (func() interface{}, func(interface{})) {
+	switch target := target.(type) {
+	case *ast.Variable:
+		// The value is evaluated in the same scope, which the accessors
+		// then look the variable up from.
+		get := func() interface{} { return i.variable(target, target.Name) }
+		set := func(value interface{}) { i.assignVariable(target, target.Name, value) }
+		return get, set
+	case *ast.Get:
+		object := i.evaluate(target.Object)
+		get := func() interface{} { return i.getProperty(object, target.Name) }
+		set := func(value interface{}) { i.setProperty(object, target.Name, value) }
+		return get, set
+	case *ast.Index:
+		object := i.evaluate(target.Object)
+		index := i.evaluate(target.Index)
+		get := func() interface{} { return getIndex(target.Bracket, object, index) }
+		set := func(value interface{}) { setIndex(target.Bracket, object, index, value) }
+		return get, set
+	}
+	panic(fmt.Errorf("invalid assignment target %s", target.String()))
+}
+
+// BinaryOperator maps the operator of a compound assignment or an
+// increment to the binary operator it applies, keeping its position.
+func BinaryOperator(operator token.Token) token.Token {
+	switch operator.Type {
+	case token.PLUS_EQUAL, token.PLUS_PLUS:
+		operator.Type = token.PLUS
+	case token.MINUS_EQUAL, token.MINUS_MINUS:
+		operator.Type = token.MINUS
+	case token.STAR_EQUAL:
+		operator.Type = token.STAR
+	case token.SLASH_EQUAL:
+		operator.Type = token.SLASH
+	}
+	return operator
+}
+
+func (i *Interpreter) getProperty(object interface{}, name token.Token) interface{} {
+	switch object := object.(type) {
+	case *LoxInstance:
+		return object.Get(i, name)
+	case *LoxClass:
+		return object.Get(name)
+	case *LoxError:
+		return object.Get(name)
+	case *Module:
+		return object.Get(name)
+	}
+	runtimeError(name, "Only instances have properties.")
+	return nil
+}
+
+func (i *Interpreter) setProperty(object interface{}, name token.Token, value interface{}) {
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		runtimeError(name, "Only instances have fields.")
+	}
+	instance.Set(i, name, value)
+}
+
+func getIndex(bracket token.Token, object interface{}, index interface{}) interface{} {
+	switch object := object.(type) {
+	case *List:
+		return object.Get(bracket, index)
+	case *Map:
+		return object.Get(bracket, index)
+	}
+	runtimeError(bracket, "Only lists and maps can be indexed.")
+	return nil
+}
+
+func setIndex(bracket token.Token, object interface{}, index interface{}, value interface{}) {
+	switch object := object.(type) {
+	case *List:
+		object.Set(bracket, index, value)
+		return
+	case *Map:
+		object.Set(bracket, index, value)
+		return
+	}
+	runtimeError(bracket, "Only lists and maps support indexed assignment.")
+}
 func (i *Interpreter) executeBlock(statements []ast.Statement, environment *Environment) {
 	// save the current env.
 	envAct := i.environment
 
+	// restore env, also when a return or an error unwinds the block.
+	defer func() {
+		i.environment = envAct
+	}()
+
 	i.environment = environment
 	for _, stmt := range statements {
 		i.execute(stmt)
 	}
+}
 
-	// restore env
-	i.environment = envAct
+// breakLoop and continueLoop unwind the body of the innermost loop.
+type breakLoop struct{}
+type continueLoop struct{}
+
+// executeLoopBody runs one iteration of a loop and reports whether it ended
+// with a break. Blocks in the body restore their environments as the jump
+// unwinds them.
+func (i *Interpreter) executeLoopBody(body ast.Statement) (broke bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case *breakLoop:
+				broke = true
+			case *continueLoop:
+				broke = false
+			default:
+				panic(r)
+			}
+		}
+	}()
+	i.execute(body)
+	return false
 }
 
-// isTruthy ::= false and nil are Falsey otherwise is Truthy
-func isTruthy(object interface{}) bool {
+// IsTruthy ::= false and nil are Falsey otherwise is Truthy
+func IsTruthy(object interface{}) bool {
 	switch val := object.(type) {
 	case bool:
 		return val
@@ -213,14 +629,22 @@ func isTruthy(object interface{}) bool {
 	}
 }
 
-// isEqual()
-func isEqual(left interface{}, right interface{}) bool {
+// IsEqual() compares numbers by value regardless of their kind, so 1 == 1.0.
+func IsEqual(left interface{}, right interface{}) bool {
 	if TypeOf(left) == 'x' && TypeOf(right) == 'x' {
 		return true
 	}
 	if TypeOf(left) == 'x' {
 		return false
 	}
+	if isNumber(left) && isNumber(right) {
+		a, aInt := left.(int64)
+		b, bInt := right.(int64)
+		if aInt && bInt {
+			return a == b
+		}
+		return toFloat(left) == toFloat(right)
+	}
 	return left == right
 }
 
@@ -231,6 +655,8 @@ func TypeOf(o interface{}) byte {
 		return 'c'
 	case float64:
 		return 'n'
+	case int64:
+		return 'i'
 	case bool:
 		return 'l'
 	case nil:
@@ -240,29 +666,111 @@ func TypeOf(o interface{}) byte {
 	}
 }
 
+// typeName describes the type of a value in error messages.
+func typeName(o interface{}) string {
+	switch value := o.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "float"
+	case int64:
+		return "integer"
+	case bool:
+		return "boolean"
+	case nil:
+		return "nil"
+	case *List:
+		return "list"
+	case *Map:
+		return "map"
+	case *LoxError:
+		return "error"
+	case *Module:
+		return "module"
+	case *LoxClass:
+		return "class"
+	case *LoxInstance:
+		return "instance"
+	case interface{ TypeName() string }:
+		// Values of the bytecode VM name their own type.
+		return value.TypeName()
+	case Callable:
+		return "function"
+	default:
+		return "object"
+	}
+}
+
+func isNumber(o interface{}) bool {
+	return TypeOf(o) == 'n' || TypeOf(o) == 'i'
+}
+
 func checkNumberOperand(operator token.Token, operand interface{}) {
-	if _, ok := operand.(float64); ok {
+	if isNumber(operand) {
 		return
 	}
-	panic(fmt.Errorf("%v Operand must be a number", operand))
+	runtimeError(operator, "Operand must be a number.")
 }
 
 func checkNumberOperands(operator token.Token, left interface{}, right interface{}) {
-	if _, ok := left.(float64); ok {
-		if _, ok := right.(float64); ok {
-			return
-		}
+	if isNumber(left) && isNumber(right) {
+		return
 	}
-	panic(fmt.Errorf("%v Operands must be numbers", operator))
+	runtimeError(operator, "Operands must be numbers.")
+}
+
+// Stringify formats a value the way print shows it.
+func Stringify(object interface{}) string {
+	return format(object, map[interface{}]bool{})
 }
 
-func stringify(object interface{}) string {
-	if TypeOf(object) == 'x' {
+// format is Stringify for values nested in a collection. Strings are quoted
+// there and seen guards against collections that contain themselves.
+func format(object interface{}, seen map[interface{}]bool) string {
+	switch TypeOf(object) {
+	case 'x':
 		return "nil"
+	case 'i':
+		return strconv.FormatInt(object.(int64), 10)
+	case 'n':
+		return formatFloat(object.(float64))
+	}
+	if list, ok := object.(*List); ok {
+		if seen[list] {
+			return "[...]"
+		}
+		seen[list] = true
+		defer delete(seen, list)
+		elements := make([]string, len(list.Elements))
+		for n, element := range list.Elements {
+			elements[n] = formatElement(element, seen)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
 	}
-	if TypeOf(object) == 'n' {
-		text := fmt.Sprintf("%v", object)
-		return text
+	if m, ok := object.(*Map); ok {
+		if seen[m] {
+			return "{...}"
+		}
+		seen[m] = true
+		defer delete(seen, m)
+		entries := make([]string, len(m.entries))
+		for n, entry := range m.entries {
+			entries[n] = formatElement(entry.key, seen) + ": " + formatElement(entry.value, seen)
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
 	}
 	return fmt.Sprintf("%v", object)
 }
+
+// Inspect formats a value the way it appears inside a list, where strings
+// are quoted.
+func Inspect(value interface{}) string {
+	return formatElement(value, map[interface{}]bool{})
+}
+
+func formatElement(element interface{}, seen map[interface{}]bool) string {
+	if text, ok := element.(string); ok {
+		return strconv.Quote(text)
+	}
+	return format(element, seen)
+}
@@ -0,0 +1,289 @@
+package interpreter
+
+import (
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run interprets source on a new interpreter and returns what it printed
+// along with the error that stopped it.
+func run(t *testing.T, source string) (string, error) {
+	t.Helper()
+	return runFile(t, "", source)
+}
+
+// runFiles writes files to a temporary directory and runs main.lox there,
+// so that it can import the others.
+func runFiles(t *testing.T, files map[string]string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return runFile(t, filepath.Join(dir, "main.lox"), files["main.lox"])
+}
+
+// runFile interprets source as the file at path, or as REPL input when
+// path is "".
+func runFile(t *testing.T, path string, source string) (string, error) {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	r := resolver.NewResolver()
+	if len(s.Errors()) == 0 && len(p.Errors()) == 0 {
+		r.Resolve(statements)
+	}
+	if errors := append(append(s.Errors(), p.Errors()...), r.Errors()...); len(errors) > 0 {
+		t.Fatalf("compiling %q: %s", source, strings.Join(errors, "; "))
+	}
+
+	i := NewInterpreter()
+	if path != "" {
+		i.SetFile(path)
+	}
+	var err error
+	output := captureStdout(t, func() {
+		err = i.Interpret(statements)
+	})
+	return output, err
+}
+
+// captureStdout returns what f prints, print statements write to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		io.Copy(&out, reader)
+		done <- out.String()
+	}()
+	f()
+	writer.Close()
+	return <-done
+}
+
+func TestStackOverflow(t *testing.T) {
+	output, err := run(t, `
+fun down(n) { return down(n + 1); }
+try {
+  down(0);
+} catch (e) {
+  print e.message;
+}
+down(0);
+`)
+	if output != "Stack overflow.\n" {
+		t.Errorf("got output %q, want the caught error's message", output)
+	}
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("got error %v, want a *RuntimeError", err)
+	}
+	// The call that overflows is the recursive one.
+	if runtimeErr.Message != "Stack overflow." || runtimeErr.Token.Line != 2 {
+		t.Errorf("got %q on line %d, want \"Stack overflow.\" on line 2", runtimeErr.Message, runtimeErr.Token.Line)
+	}
+	if len(runtimeErr.Stack) != maxFrames {
+		t.Errorf("got %d frames in the stack trace, want %d", len(runtimeErr.Stack), maxFrames)
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	// Recursion below the limit still works.
+	output, err := run(t, `
+fun sum(n) { if (n == 0) return 0; return n + sum(n - 1); }
+print sum(10000);
+`)
+	if err != nil || output != "50005000\n" {
+		t.Errorf("got %q, %v, want \"50005000\\n\"", output, err)
+	}
+}
+
+func TestModuleIsolation(t *testing.T) {
+	output, err := runFiles(t, map[string]string{
+		"main.lox": `
+var secret = "main";
+import "module.lox" as m;
+print m.count();
+try { m.read(); } catch (e) { print e.message; }
+try { m.write(); } catch (e) { print e.message; }
+print secret;
+`,
+		"module.lox": `
+export fun count() { return len([1, 2, 3]); }
+export fun read() { return secret; }
+export fun write() { secret = "module"; }
+`,
+	})
+	want := "3\nUndefined variable 'secret'.\nUndefined variable 'secret'.\nmain\n"
+	if err != nil || output != want {
+		t.Errorf("got %q, %v, want %q", output, err, want)
+	}
+}
+
+func TestModuleNatives(t *testing.T) {
+	// Shadowing or overwriting a native stays within the file doing it.
+	output, err := runFiles(t, map[string]string{
+		"main.lox": `
+import "module.lox" as m;
+len = nil;
+print m.size("abc");
+`,
+		"module.lox": `
+export fun size(s) { return len(s); }
+clock = nil;
+`,
+	})
+	if err != nil || output != "3\n" {
+		t.Errorf("got %q, %v, want \"3\\n\"", output, err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"9007199254740993 + 0", "9007199254740993"},
+		{"7 - 10", "-3"},
+		{"6 * 7", "42"},
+		{"7 / 2", "3.5"},
+		{"6 / 3", "2.0"},
+		{"7 \\ 2", "3"},
+		{"-7 \\ 2", "-3"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"1 + 0.5", "1.5"},
+		{"2 * 1.5", "3.0"},
+		{"7.5 \\ 2", "3.0"},
+		{"-7.5 % 2", "-1.5"},
+		{"1 == 1.0", "true"},
+		{"2 > 1.5", "true"},
+		{"1e21", "1e+21"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+	}
+	for _, test := range tests {
+		output, err := run(t, "print "+test.expr+";")
+		if err != nil || output != test.want+"\n" {
+			t.Errorf("%s: got %q, %v, want %s", test.expr, output, err, test.want)
+		}
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"9223372036854775807 + 1", "Integer overflow."},
+		{"-9223372036854775807 - 2", "Integer overflow."},
+		{"4294967296 * 4294967296", "Integer overflow."},
+		{"-(-9223372036854775807 - 1)", "Integer overflow."},
+		{"(-9223372036854775807 - 1) \\ -1", "Integer overflow."},
+		{"1 \\ 0", "Division by zero."},
+		{"1 % 0", "Division by zero."},
+		{"1 + nil", "Operands must be two numbers or two strings."},
+	}
+	for _, test := range tests {
+		_, err := run(t, "print "+test.expr+";")
+		if runtimeErr, ok := err.(*RuntimeError); !ok || runtimeErr.Message != test.want {
+			t.Errorf("%s: got %v, want %q", test.expr, err, test.want)
+		}
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"[1, 2][2];", "List index 2 out of range for list of length 2."},
+		{"[1, 2][-3];", "List index -3 out of range for list of length 2."},
+		{"[1, 2][\"a\"];", "List index must be an integer."},
+		{"[1, 2][0.5];", "List index must be an integer."},
+		{"[1, 2][0:\"b\"];", "Slice bounds must be integers."},
+		{"var xs = [1]; xs[1] = 2;", "List index 1 out of range for list of length 1."},
+		{"pop([]);", "Can't pop from an empty list."},
+		{"push(1, 2);", "push() expects a list as its first argument."},
+		{"1[0];", "Only lists and maps can be indexed."},
+		{"len(1);", "len() expects a list, a map or a string."},
+	}
+	for _, test := range tests {
+		_, err := run(t, test.source)
+		if runtimeErr, ok := err.(*RuntimeError); !ok || runtimeErr.Message != test.want {
+			t.Errorf("%s: got %v, want %q", test.source, err, test.want)
+		}
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var m = {}; m[[1]] = 1;", "Unhashable map key of type list."},
+		{"print {}[{}];", "Unhashable map key of type map."},
+		{"fun f() {} has({}, f);", "Unhashable map key of type function."},
+		{"print {\"a\": 1}[\"b\"];", "Key b not found in map."},
+		{"keys([]);", "keys() expects a map as its first argument."},
+	}
+	for _, test := range tests {
+		_, err := run(t, test.source)
+		if runtimeErr, ok := err.(*RuntimeError); !ok || runtimeErr.Message != test.want {
+			t.Errorf("%s: got %v, want %q", test.source, err, test.want)
+		}
+	}
+}
+
+func TestConstantsInREPL(t *testing.T) {
+	// Each line of the REPL is resolved on its own, so the interpreter
+	// protects the constants of earlier lines.
+	i := NewInterpreter()
+	lines := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{"const a = 1;", ""},
+		{"a = 2;", "Cannot assign to constant 'a' declared at Ln 1, Col 7."},
+		{"a += 1;", "Cannot assign to constant 'a' declared at Ln 1, Col 7."},
+		{"var a = 3;", "Cannot redeclare constant 'a' declared at Ln 1, Col 7."},
+		{"{ var a = 4; a = 5; }", ""},
+		{"print a;", ""},
+	}
+	var output string
+	for _, line := range lines {
+		statements := parser.NewParser(scanner.NewScanner(line.source).ScanTokens()).Parse()
+		var err error
+		output += captureStdout(t, func() {
+			err = i.Interpret(statements)
+		})
+		runtimeErr, _ := err.(*RuntimeError)
+		if line.want == "" && err != nil || line.want != "" && (runtimeErr == nil || runtimeErr.Message != line.want) {
+			t.Errorf("%s: got %v, want %q", line.source, err, line.want)
+		}
+	}
+	if output != "1\n" {
+		t.Errorf("got output %q, want the constant unchanged", output)
+	}
+}
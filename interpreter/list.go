@@ -0,0 +1,95 @@
+package interpreter
+
+import (
+	"Glox/token"
+)
+
+// List is the runtime value of a list literal. Lists are mutable and shared
+// by reference.
+type List struct {
+	Elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{Elements: elements}
+}
+
+// Get returns the element at index. Negative indices count from the end.
+func (l *List) Get(bracket token.Token, index interface{}) interface{} {
+	return l.Elements[l.position(bracket, index)]
+}
+
+// Set replaces the element at index. Negative indices count from the end.
+func (l *List) Set(bracket token.Token, index interface{}, value interface{}) {
+	l.Elements[l.position(bracket, index)] = value
+}
+
+// Slice returns a new list with the elements in [start, end). Nil bounds
+// default to the start and end of the list, negative bounds count from the
+// end and bounds past either end are clamped.
+func (l *List) Slice(bracket token.Token, start interface{}, end interface{}) *List {
+	from, to := 0, len(l.Elements)
+	if start != nil {
+		from = l.clamp(sliceBound(bracket, start))
+	}
+	if end != nil {
+		to = l.clamp(sliceBound(bracket, end))
+	}
+	elements := []interface{}{}
+	if from < to {
+		elements = append(elements, l.Elements[from:to]...)
+	}
+	return NewList(elements)
+}
+
+func (l *List) Push(value interface{}) {
+	l.Elements = append(l.Elements, value)
+}
+
+func (l *List) Pop(paren token.Token) interface{} {
+	if len(l.Elements) == 0 {
+		runtimeError(paren, "Can't pop from an empty list.")
+	}
+	last := l.Elements[len(l.Elements)-1]
+	l.Elements = l.Elements[:len(l.Elements)-1]
+	return last
+}
+
+// position turns a Lox index into a Go one, raising a runtime error when it
+// isn't an integer or falls outside the list.
+func (l *List) position(bracket token.Token, index interface{}) int {
+	n, ok := index.(int64)
+	if !ok {
+		runtimeError(bracket, "List index must be an integer.")
+	}
+	position := n
+	if position < 0 {
+		position += int64(len(l.Elements))
+	}
+	if position < 0 || position >= int64(len(l.Elements)) {
+		runtimeError(bracket, "List index %d out of range for list of length %d.", n, len(l.Elements))
+	}
+	return int(position)
+}
+
+func (l *List) clamp(bound int64) int {
+	length := int64(len(l.Elements))
+	if bound < 0 {
+		bound += length
+	}
+	if bound < 0 {
+		return 0
+	}
+	if bound > length {
+		return int(length)
+	}
+	return int(bound)
+}
+
+func sliceBound(bracket token.Token, bound interface{}) int64 {
+	n, ok := bound.(int64)
+	if !ok {
+		runtimeError(bracket, "Slice bounds must be integers.")
+	}
+	return n
+}
@@ -0,0 +1,104 @@
+package interpreter
+
+import (
+	"Glox/token"
+	"math"
+)
+
+// Map is the runtime value of a map literal. Entries keep their insertion
+// order. Keys may be strings, numbers, booleans or nil; numbers compare by
+// value, so 1 and 1.0 are the same key.
+type Map struct {
+	entries []mapEntry
+	index   map[interface{}]int
+}
+
+type mapEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+func NewMap() *Map {
+	return &Map{index: make(map[interface{}]int)}
+}
+
+func (m *Map) Len() int {
+	return len(m.entries)
+}
+
+// Get returns the value stored under key, raising a runtime error when the
+// key is missing.
+func (m *Map) Get(bracket token.Token, key interface{}) interface{} {
+	if n, ok := m.index[hashKey(bracket, key)]; ok {
+		return m.entries[n].value
+	}
+	runtimeError(bracket, "Key %s not found in map.", format(key, map[interface{}]bool{}))
+	return nil
+}
+
+// Set stores value under key. Overwriting a key keeps its original position.
+func (m *Map) Set(bracket token.Token, key interface{}, value interface{}) {
+	hash := hashKey(bracket, key)
+	if n, ok := m.index[hash]; ok {
+		m.entries[n].value = value
+		return
+	}
+	m.index[hash] = len(m.entries)
+	m.entries = append(m.entries, mapEntry{key: key, value: value})
+}
+
+func (m *Map) Has(bracket token.Token, key interface{}) bool {
+	_, ok := m.index[hashKey(bracket, key)]
+	return ok
+}
+
+// Remove deletes key and returns its value, or nil when it wasn't present.
+func (m *Map) Remove(bracket token.Token, key interface{}) interface{} {
+	hash := hashKey(bracket, key)
+	n, ok := m.index[hash]
+	if !ok {
+		return nil
+	}
+	value := m.entries[n].value
+	delete(m.index, hash)
+	m.entries = append(m.entries[:n], m.entries[n+1:]...)
+	for ; n < len(m.entries); n++ {
+		m.index[hashKey(bracket, m.entries[n].key)] = n
+	}
+	return value
+}
+
+func (m *Map) Keys() *List {
+	keys := make([]interface{}, len(m.entries))
+	for n, entry := range m.entries {
+		keys[n] = entry.key
+	}
+	return NewList(keys)
+}
+
+func (m *Map) Values() *List {
+	values := make([]interface{}, len(m.entries))
+	for n, entry := range m.entries {
+		values[n] = entry.value
+	}
+	return NewList(values)
+}
+
+// hashKey returns the Go map key for a Lox value, raising a runtime error
+// when the value can't be used as a key.
+func hashKey(bracket token.Token, key interface{}) interface{} {
+	switch k := key.(type) {
+	case nil, bool, string, int64:
+		return k
+	case float64:
+		if math.IsNaN(k) {
+			runtimeError(bracket, "NaN can't be used as a map key.")
+		}
+		if k == math.Trunc(k) && k >= math.MinInt64 && k < math.MaxInt64 {
+			return int64(k)
+		}
+		return k
+	}
+	runtimeError(bracket, "Unhashable map key of type %s.", typeName(key))
+	return nil
+}
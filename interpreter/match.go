@@ -0,0 +1,81 @@
+package interpreter
+
+import (
+	"Glox/ast"
+)
+
+func (i *Interpreter) VisitMatchStmt(stmt *ast.MatchStmt) interface{} {
+	subject := i.evaluate(stmt.Subject)
+	for _, c := range stmt.Cases {
+		for _, pattern := range c.Patterns {
+			// Each alternative binds into its own scope, which the guard
+			// and the body then run in.
+			environment := NewEnclosedEnvironment(i.environment)
+			if !i.matchPattern(pattern, subject, environment) {
+				continue
+			}
+			if c.Guard != nil && !IsTruthy(i.evaluateIn(c.Guard, environment)) {
+				continue
+			}
+			i.executeBlock([]ast.Statement{c.Body}, environment)
+			return nil
+		}
+	}
+	if stmt.Default == nil {
+		runtimeError(stmt.Keyword, "No case matches %s.", Inspect(subject))
+	}
+	i.execute(stmt.Default)
+	return nil
+}
+
+// matchPattern reports whether value matches pattern, defining the pattern's
+// bindings in environment as it goes.
+func (i *Interpreter) matchPattern(pattern ast.Pattern, value interface{}, environment *Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		return IsEqual(i.evaluateIn(pattern.Value, environment), value)
+	case *ast.BindingPattern:
+		if pattern.Name.Lexeme != "_" {
+			environment.Define(pattern.Name.Lexeme, value)
+		}
+		return true
+	case *ast.ListPattern:
+		list, ok := value.(*List)
+		if !ok || len(list.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for n, element := range pattern.Elements {
+			if !i.matchPattern(element, list.Elements[n], environment) {
+				return false
+			}
+		}
+		return true
+	case *ast.InstancePattern:
+		class, ok := i.evaluateIn(pattern.Class, environment).(*LoxClass)
+		if !ok {
+			runtimeError(pattern.Class.Name, "'%s' in pattern is not a class.", pattern.Class.Name.Lexeme)
+		}
+		instance, ok := value.(*LoxInstance)
+		if !ok || !instance.IsA(class) {
+			return false
+		}
+		for n, field := range pattern.Fields {
+			property, ok := instance.lookup(i, field)
+			if !ok || !i.matchPattern(pattern.Patterns[n], property, environment) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// evaluateIn evaluates expr with environment as the current scope.
+func (i *Interpreter) evaluateIn(expr ast.Expression, environment *Environment) interface{} {
+	previous := i.environment
+	defer func() {
+		i.environment = previous
+	}()
+	i.environment = environment
+	return i.evaluate(expr)
+}
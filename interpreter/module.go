@@ -0,0 +1,179 @@
+package interpreter
+
+import (
+	"Glox/ast"
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"Glox/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Module is the value bound by import "path" as name. Only the exported
+// top-level names of the module are visible through it.
+type Module struct {
+	path        string
+	environment *Environment
+	exports     map[string]bool
+}
+
+func (m *Module) Get(name token.Token) interface{} {
+	if m.exports[name.Lexeme] {
+		return m.environment.Get(name)
+	}
+	runtimeError(name, "Module '%s' has no export '%s'.", filepath.Base(m.path), name.Lexeme)
+	return nil
+}
+
+func (m *Module) String() string {
+	return "<module " + filepath.Base(m.path) + ">"
+}
+
+// SetFile tells the interpreter which file it runs, so imports resolve
+// relative to it and importing it back is reported as a cycle.
+func (i *Interpreter) SetFile(path string) {
+	if canonical, err := CanonicalPath(path); err == nil {
+		i.file = canonical
+		i.frames[0].file = canonical
+		i.importing = []string{canonical}
+	}
+}
+
+func (i *Interpreter) VisitExportStmt(stmt *ast.ExportStmt) interface{} {
+	i.execute(stmt.Declaration)
+	if i.module == nil {
+		// Exports of the main script have no importer.
+		return nil
+	}
+	switch declaration := stmt.Declaration.(type) {
+	case *ast.VarStmt:
+		i.module.exports[declaration.Name.Lexeme] = true
+	case *ast.FunStmt:
+		i.module.exports[declaration.Name.Lexeme] = true
+	case *ast.ClassStmt:
+		i.module.exports[declaration.Name.Lexeme] = true
+	}
+	return nil
+}
+
+func (i *Interpreter) VisitImportStmt(stmt *ast.ImportStmt) interface{} {
+	module := i.importModule(stmt.Path)
+	if stmt.Names == nil {
+		i.environment.Declare(stmt.Alias)
+		i.environment.Define(stmt.Alias.Lexeme, module)
+		return nil
+	}
+	for _, name := range stmt.Names {
+		i.environment.Declare(name)
+		i.environment.Define(name.Lexeme, module.Get(name))
+	}
+	return nil
+}
+
+// importModule returns the module at path, evaluating it the first time it
+// is imported. Modules are cached by their canonical path.
+func (i *Interpreter) importModule(path token.Token) *Module {
+	resolved := ResolveModule(path, i.file)
+	for n, importing := range i.importing {
+		if importing == resolved {
+			cycle := append(append([]string{}, i.importing[n:]...), resolved)
+			runtimeError(path, "Import cycle: %s.", strings.Join(cycle, " -> "))
+		}
+	}
+	if module, ok := i.modules[resolved]; ok {
+		return module
+	}
+	statements := ParseModule(path, resolved)
+	i.resolve(statements)
+
+	module := &Module{
+		path:        resolved,
+		environment: NewEnclosedEnvironment(nativeScope()),
+		exports:     make(map[string]bool),
+	}
+	file, current := i.file, i.module
+	i.importing = append(i.importing, resolved)
+	defer func() {
+		i.file, i.module = file, current
+		i.importing = i.importing[:len(i.importing)-1]
+	}()
+	i.file, i.module = resolved, module
+
+	i.frames = append(i.frames, frame{name: module.String(), file: resolved, call: path, caller: i.environment})
+	i.executeBlock(statements, module.environment)
+	i.frames = i.frames[:len(i.frames)-1]
+
+	i.modules[resolved] = module
+	return module
+}
+
+// ParseModule reads, parses and resolves the module at the canonical path
+// resolved, raising a runtime error at the import's path when it can't.
+func ParseModule(path token.Token, resolved string) []ast.Statement {
+	source, err := os.ReadFile(resolved)
+	if err != nil {
+		runtimeError(path, "Could not read module %s: %s", path.Lexeme, err)
+	}
+	s := scanner.NewScanner(string(source))
+	tokens := s.ScanTokens()
+	if len(s.Errors()) > 0 {
+		runtimeError(path, "Could not compile module %s:\n    %s", path.Lexeme, strings.Join(s.Errors(), "\n    "))
+	}
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	if len(p.Errors()) > 0 {
+		runtimeError(path, "Could not compile module %s:\n    %s", path.Lexeme, strings.Join(p.Errors(), "\n    "))
+	}
+	r := resolver.NewResolver()
+	r.Resolve(statements)
+	if len(r.Errors()) > 0 {
+		runtimeError(path, "Could not compile module %s:\n    %s", path.Lexeme, strings.Join(r.Errors(), "\n    "))
+	}
+	return statements
+}
+
+// ResolveModule finds the file an import in importer refers to, importer
+// being "" in the REPL. Relative paths are looked up next to the importing
+// file first and then in every directory of the GLOXPATH search list,
+// unless they start with "./" or "../".
+func ResolveModule(path token.Token, importer string) string {
+	name := path.Literal.(string)
+	var candidates []string
+	if filepath.IsAbs(name) {
+		candidates = append(candidates, name)
+	} else {
+		dir := "."
+		if importer != "" {
+			dir = filepath.Dir(importer)
+		}
+		candidates = append(candidates, filepath.Join(dir, name))
+		if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+			for _, dir := range filepath.SplitList(os.Getenv("GLOXPATH")) {
+				if dir != "" {
+					candidates = append(candidates, filepath.Join(dir, name))
+				}
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			if canonical, err := CanonicalPath(candidate); err == nil {
+				return canonical
+			}
+		}
+	}
+	runtimeError(path, "Cannot find module %s (searched %s).", path.Lexeme, strings.Join(candidates, ", "))
+	return ""
+}
+
+// CanonicalPath is the absolute path of a file with symlinks resolved, the
+// key modules are cached and compared by.
+func CanonicalPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
@@ -0,0 +1,99 @@
+package interpreter
+
+import (
+	"Glox/token"
+	"time"
+	"unicode/utf8"
+)
+
+// nativeScope returns a new scope holding only the built-in functions. The
+// top level of the main script and of every module is nested in a scope of
+// its own, so a module can't reach the globals of the file importing it.
+func nativeScope() *Environment {
+	natives := NewEnvironment()
+	defineNatives(natives)
+	return natives
+}
+
+// defineNatives installs the built-in functions in an environment.
+func defineNatives(globals *Environment) {
+	natives := []*NativeFunction{
+		{name: "clock", arity: 0, fn: nativeClock},
+		{name: "Error", arity: 1, fn: nativeError},
+		{name: "len", arity: 1, fn: nativeLen},
+		{name: "push", arity: 2, fn: nativePush},
+		{name: "pop", arity: 1, fn: nativePop},
+		{name: "keys", arity: 1, fn: nativeKeys},
+		{name: "values", arity: 1, fn: nativeValues},
+		{name: "has", arity: 2, fn: nativeHas},
+		{name: "remove", arity: 2, fn: nativeRemove},
+	}
+	for _, native := range natives {
+		globals.Define(native.name, native)
+	}
+}
+
+func nativeClock(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return float64(time.Now().UnixNano()) / float64(time.Second)
+}
+
+// nativeError builds an error object whose line and stack trace point at
+// the call.
+func nativeError(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return &LoxError{Message: Stringify(arguments[0]), Line: paren.Line, Stack: i.stackTrace(paren.Line)}
+}
+
+func nativeLen(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	switch value := arguments[0].(type) {
+	case *List:
+		return int64(len(value.Elements))
+	case *Map:
+		return int64(value.Len())
+	case string:
+		return int64(utf8.RuneCountInString(value))
+	}
+	runtimeError(paren, "len() expects a list, a map or a string.")
+	return nil
+}
+
+func nativePush(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	list := checkList(paren, "push", arguments[0])
+	list.Push(arguments[1])
+	return list
+}
+
+func nativePop(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return checkList(paren, "pop", arguments[0]).Pop(paren)
+}
+
+func checkList(paren token.Token, name string, value interface{}) *List {
+	list, ok := value.(*List)
+	if !ok {
+		runtimeError(paren, "%s() expects a list as its first argument.", name)
+	}
+	return list
+}
+
+func nativeKeys(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "keys", arguments[0]).Keys()
+}
+
+func nativeValues(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "values", arguments[0]).Values()
+}
+
+func nativeHas(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "has", arguments[0]).Has(paren, arguments[1])
+}
+
+func nativeRemove(i *Interpreter, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "remove", arguments[0]).Remove(paren, arguments[1])
+}
+
+func checkMap(paren token.Token, name string, value interface{}) *Map {
+	m, ok := value.(*Map)
+	if !ok {
+		runtimeError(paren, "%s() expects a map as its first argument.", name)
+	}
+	return m
+}
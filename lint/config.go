@@ -0,0 +1,62 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ConfigFile is the name glox lint looks for in the working directory when
+// no config is given.
+const ConfigFile = ".gloxlint.json"
+
+// Config changes the severity of rules. Its file is a JSON object mapping
+// rule IDs to "off", "warning" or "error":
+//
+//	{"rules": {"shadowed-variable": "off", "empty-block": "error"}}
+//
+// Rules it doesn't mention keep their default severity. A nil *Config
+// leaves every rule at its default.
+type Config struct {
+	Severities map[string]Severity
+}
+
+// LoadConfig reads a config file. Unknown rules and severities are errors,
+// so typos don't silently leave a rule on.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules map[string]string `json:"rules"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	config := &Config{Severities: make(map[string]Severity)}
+	for id, name := range file.Rules {
+		if _, ok := ruleNamed(id); !ok {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, id)
+		}
+		severity, ok := severityNamed(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: rule %q has unknown severity %q; use \"off\", \"warning\" or \"error\"", path, id, name)
+		}
+		config.Severities[id] = severity
+	}
+	return config, nil
+}
+
+// severity returns the severity of a rule under the config.
+func (c *Config) severity(rule Rule) Severity {
+	if c != nil {
+		if severity, ok := c.Severities[rule.ID]; ok {
+			return severity
+		}
+	}
+	return rule.Severity
+}
@@ -0,0 +1,314 @@
+// Package lint reports code that runs but is likely a mistake: unused
+// locals, shadowed names, unreachable statements and the like. Each rule
+// has an ID and a default severity that a Config can change, and a
+//
+//	// glox:ignore rule-id
+//
+// comment silences the rules it names on its own line, or on the next line
+// when it has a line to itself. Without IDs it silences every rule.
+package lint
+
+import (
+	"Glox/ast"
+	"Glox/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Off Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"off", "warning", "error"}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func severityNamed(name string) (Severity, bool) {
+	for s, n := range severityNames {
+		if n == name {
+			return Severity(s), true
+		}
+	}
+	return Off, false
+}
+
+// Rule is a check the linter runs.
+type Rule struct {
+	ID       string
+	Severity Severity // the default.
+	Summary  string
+}
+
+var (
+	UnusedVariable    = Rule{"unused-variable", Warning, "a local variable, function or class is never used"}
+	UnusedParameter   = Rule{"unused-parameter", Warning, "a parameter is never used; prefix it with _ to keep it"}
+	ShadowedVariable  = Rule{"shadowed-variable", Warning, "a declaration hides one of the same name in an enclosing scope"}
+	UnreachableCode   = Rule{"unreachable-code", Error, "a statement follows a return, throw, break or continue"}
+	SelfAssignment    = Rule{"self-assignment", Error, "a variable, property or element is assigned to itself"}
+	SelfComparison    = Rule{"self-comparison", Warning, "an expression is compared with itself"}
+	ConstantCondition = Rule{"constant-condition", Warning, "an if condition is a literal"}
+	EmptyBlock        = Rule{"empty-block", Warning, "a block has no statements and no comment"}
+)
+
+// Rules lists every rule.
+var Rules = []Rule{
+	UnusedVariable, UnusedParameter, ShadowedVariable, UnreachableCode,
+	SelfAssignment, SelfComparison, ConstantCondition, EmptyBlock,
+}
+
+func ruleNamed(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Diagnostic is a problem found by a rule, at Token.
+type Diagnostic struct {
+	Rule     string
+	Severity Severity
+	Token    token.Token
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("Ln %d, Col %d %s: %s [%s]", d.Token.Line, d.Token.Col, d.Severity, d.Message, d.Rule)
+}
+
+// Lint checks a program that parsed and resolved cleanly. tokens are what a
+// scanner in trivia mode returned for it, so the linter can see comments.
+// The diagnostics are sorted by position.
+func Lint(statements []ast.Statement, tokens []token.Token, config *Config) []Diagnostic {
+	l := &linter{config: config, ignored: make(map[int][]string), muted: make(map[int]bool)}
+	for _, tok := range tokens {
+		switch tok.Type {
+		case token.COMMENT:
+			l.comments = append(l.comments, tok)
+		case token.WHITESPACE:
+		default:
+			l.tokens = append(l.tokens, tok)
+		}
+	}
+	l.ignoreComments()
+
+	l.beginScope()
+	l.statements(statements)
+	l.endScope()
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		return before(l.diagnostics[i].Token, l.diagnostics[j].Token)
+	})
+	return l.diagnostics
+}
+
+type linter struct {
+	config      *Config
+	diagnostics []Diagnostic
+	scope       *scope
+
+	tokens   []token.Token // the tokens of the source that aren't trivia.
+	comments []token.Token
+	ignored  map[int][]string // the rules silenced on a line.
+	muted    map[int]bool     // the lines where every rule is silenced.
+}
+
+// scope holds the locals declared in a block or function. Function bodies
+// run later than they are declared and see names declared after them, so
+// the linter walks them when their scope ends instead of where they appear.
+type scope struct {
+	enclosing *scope
+	locals    map[string]*local
+	bodies    []func()
+}
+
+type localKind int
+
+const (
+	variable localKind = iota
+	parameter
+	function
+	class
+	caught // a catch parameter, which the syntax requires.
+)
+
+type local struct {
+	name token.Token
+	kind localKind
+	used bool
+}
+
+func (l *linter) beginScope() {
+	l.scope = &scope{enclosing: l.scope, locals: make(map[string]*local)}
+}
+
+// endScope walks the bodies of the functions declared in the scope, then
+// reports the locals nothing used. The global scope is exempt, since other
+// modules and the REPL may use its names.
+func (l *linter) endScope() {
+	for len(l.scope.bodies) > 0 {
+		body := l.scope.bodies[0]
+		l.scope.bodies = l.scope.bodies[1:]
+		body()
+	}
+	if l.scope.enclosing != nil {
+		for _, local := range l.scope.locals {
+			l.unused(local)
+		}
+	}
+	l.scope = l.scope.enclosing
+}
+
+// later walks a function body when the current scope ends.
+func (l *linter) later(body func()) {
+	l.scope.bodies = append(l.scope.bodies, body)
+}
+
+// declare adds a local to the current scope. Since function bodies are
+// walked late, only outer declarations that come first in the source count
+// as shadowed.
+func (l *linter) declare(name token.Token, kind localKind) {
+	for s := l.scope.enclosing; s != nil; s = s.enclosing {
+		if outer, ok := s.locals[name.Lexeme]; ok && before(outer.name, name) {
+			l.report(ShadowedVariable, name, fmt.Sprintf("'%s' shadows the declaration at Ln %d, Col %d.", name.Lexeme, outer.name.Line, outer.name.Col))
+			break
+		}
+	}
+	if previous, ok := l.scope.locals[name.Lexeme]; ok && l.scope.enclosing != nil {
+		l.unused(previous)
+	}
+	l.scope.locals[name.Lexeme] = &local{name: name, kind: kind}
+}
+
+func before(a token.Token, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+}
+
+// use marks the local name refers to as used. Names that were never
+// declared are natives or errors the interpreter reports.
+func (l *linter) use(name token.Token) {
+	for s := l.scope; s != nil; s = s.enclosing {
+		if local, ok := s.locals[name.Lexeme]; ok {
+			local.used = true
+			return
+		}
+	}
+}
+
+func (l *linter) unused(local *local) {
+	if local.used || strings.HasPrefix(local.name.Lexeme, "_") {
+		return
+	}
+	name := local.name
+	switch local.kind {
+	case variable:
+		l.report(UnusedVariable, name, fmt.Sprintf("Local variable '%s' is never used.", name.Lexeme))
+	case function:
+		l.report(UnusedVariable, name, fmt.Sprintf("Local function '%s' is never used.", name.Lexeme))
+	case class:
+		l.report(UnusedVariable, name, fmt.Sprintf("Local class '%s' is never used.", name.Lexeme))
+	case parameter:
+		l.report(UnusedParameter, name, fmt.Sprintf("Parameter '%s' is never used.", name.Lexeme))
+	}
+}
+
+func (l *linter) report(rule Rule, at token.Token, msg string) {
+	severity := l.config.severity(rule)
+	if severity == Off || l.isIgnored(rule, at.Line) {
+		return
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{Rule: rule.ID, Severity: severity, Token: at, Message: msg})
+}
+
+// ignoreComments finds the glox:ignore comments. A comment after code on
+// its line applies to that line, and one on a line of its own applies to
+// the next line with code.
+func (l *linter) ignoreComments() {
+	for _, comment := range l.comments {
+		text := strings.TrimPrefix(comment.Lexeme, "//")
+		if strings.HasPrefix(text, "/*") {
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		}
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, "glox:ignore") {
+			continue
+		}
+		rest := strings.TrimPrefix(text, "glox:ignore")
+		if rest != "" && !strings.HasPrefix(rest, " ") {
+			// glox:ignorefoo is some other comment.
+			continue
+		}
+		line := comment.Line - strings.Count(comment.Lexeme, "\n")
+		previous := l.tokenBefore(comment)
+		if previous == nil || previous.Line != line {
+			if next := l.tokenAfter(comment); next != nil {
+				line = next.Line - strings.Count(next.Lexeme, "\n")
+			}
+		}
+		ids := strings.FieldsFunc(rest, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' })
+		if len(ids) == 0 {
+			l.muted[line] = true
+		}
+		l.ignored[line] = append(l.ignored[line], ids...)
+	}
+}
+
+func (l *linter) isIgnored(rule Rule, line int) bool {
+	if l.muted[line] {
+		return true
+	}
+	for _, id := range l.ignored[line] {
+		if id == rule.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenBefore returns the last source token before a comment, or nil.
+func (l *linter) tokenBefore(comment token.Token) *token.Token {
+	n := l.tokenIndex(comment)
+	if n == 0 {
+		return nil
+	}
+	return &l.tokens[n-1]
+}
+
+// tokenAfter returns the first source token after a comment, or nil at the
+// end of the source.
+func (l *linter) tokenAfter(comment token.Token) *token.Token {
+	n := l.tokenIndex(comment)
+	if n == len(l.tokens) || l.tokens[n].Type == token.EOF {
+		return nil
+	}
+	return &l.tokens[n]
+}
+
+// tokenIndex returns the index of the first source token after a comment.
+// Tokens sit at their last character and never overlap comments.
+func (l *linter) tokenIndex(comment token.Token) int {
+	return sort.Search(len(l.tokens), func(n int) bool {
+		tok := l.tokens[n]
+		return tok.Type == token.EOF || tok.Line > comment.Line || tok.Line == comment.Line && tok.Col > comment.Col
+	})
+}
+
+// hasComment reports whether a comment follows brace before the next
+// source token, as in a block that is empty on purpose.
+func (l *linter) hasComment(brace token.Token) bool {
+	for _, comment := range l.comments {
+		if before(brace, comment) {
+			previous := l.tokenBefore(comment)
+			return previous != nil && previous.Line == brace.Line && previous.Col == brace.Col
+		}
+	}
+	return false
+}
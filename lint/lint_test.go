@@ -0,0 +1,116 @@
+package lint
+
+import (
+	"Glox/parser"
+	"Glox/scanner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lint lints source and returns its diagnostics, one per line.
+func lint(t *testing.T, source string, config *Config) string {
+	t.Helper()
+	s := scanner.NewTriviaScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(scanner.WithoutTrivia(tokens))
+	statements := p.Parse()
+	if errors := append(s.Errors(), p.Errors()...); len(errors) > 0 {
+		t.Fatalf("parsing %q: %s", source, strings.Join(errors, "; "))
+	}
+	var lines []string
+	for _, diagnostic := range Lint(statements, tokens, config) {
+		lines = append(lines, diagnostic.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"unused variable", `{ var a = 1; }`, "Ln 1, Col 7 warning: Local variable 'a' is never used. [unused-variable]"},
+		{"unused function", `{ fun f() {} }`, "Ln 1, Col 7 warning: Local function 'f' is never used. [unused-variable]"},
+		{"unused class", `{ class K {} }`, "Ln 1, Col 9 warning: Local class 'K' is never used. [unused-variable]"},
+		{"globals", `var a = 1; fun f() {}`, ""},
+		{"underscore", `{ var _a = 1; }`, ""},
+		{"used later by a function", `{ fun f() { return a; } var a = 1; print f(); }`, ""},
+		{"unused parameter", `fun f(a, b) { return b; }`, "Ln 1, Col 7 warning: Parameter 'a' is never used. [unused-parameter]"},
+		{"catch parameter", `try {} catch (e) { print 1; }`, "Ln 1, Col 3 warning: Empty block. [empty-block]"},
+		{"shadowed", `var a = 1; fun f() { var a = 2; return a; }`, "Ln 1, Col 26 warning: 'a' shadows the declaration at Ln 1, Col 5. [shadowed-variable]"},
+		{"unreachable", `fun f() { return 1; print 2; }`, "Ln 1, Col 27 error: Unreachable code. [unreachable-code]"},
+		{"self-assignment", `var a = 1; a = a;`, "Ln 1, Col 12 error: 'a' is assigned to itself. [self-assignment]"},
+		{"self-comparison", `var a = 1; print a == a;`, "Ln 1, Col 21 warning: 'a' is compared with itself. [self-comparison]"},
+		{"constant condition", `if (true) print 1;`, "Ln 1, Col 8 warning: The condition is always true. [constant-condition]"},
+		{"empty block", `while (false) {}`, "Ln 1, Col 15 warning: Empty block. [empty-block]"},
+		{"commented empty block", `while (false) { /* later */ }`, ""},
+	}
+	for _, test := range tests {
+		if got := lint(t, test.source, nil); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"same line", "{ var a = 1; // glox:ignore unused-variable\n}", ""},
+		{"next line", "{\n  // glox:ignore unused-variable\n  var a = 1;\n}", ""},
+		{"block comment", "{ var a = 1; /* glox:ignore */ }", ""},
+		{"other rule", "{ var a = 1; // glox:ignore empty-block\n}", "Ln 1, Col 7 warning: Local variable 'a' is never used. [unused-variable]"},
+		{"several rules", "{ var a = a == a; } // glox:ignore unused-variable, self-comparison", ""},
+		{"not an ignore", "{ var a = 1; // glox:ignored\n}", "Ln 1, Col 7 warning: Local variable 'a' is never used. [unused-variable]"},
+		{"only the next line", "// glox:ignore\n{ var a = 1;\n  var b = 2; }", "Ln 3, Col 7 warning: Local variable 'b' is never used. [unused-variable]"},
+	}
+	for _, test := range tests {
+		if got := lint(t, test.source, nil); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config := &Config{Severities: map[string]Severity{"unused-variable": Off, "empty-block": Error}}
+	want := "Ln 1, Col 21 error: Empty block. [empty-block]"
+	if got := lint(t, `{ var a = 1; if (a) {} }`, config); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		file string
+		want string // the error, or "" to load the file.
+	}{
+		{`{"rules": {"shadowed-variable": "off", "empty-block": "error"}}`, ""},
+		{`{"rules": {"unused": "off"}}`, `unknown rule "unused"`},
+		{`{"rules": {"empty-block": "loud"}}`, `rule "empty-block" has unknown severity "loud"`},
+		{`{"rule": {}}`, `unknown field "rule"`},
+		{`{"rules": `, "unexpected EOF"},
+	}
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	for _, test := range tests {
+		if err := os.WriteFile(path, []byte(test.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadConfig(path)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%s: %s", test.file, err)
+		case test.want == "" && (config.severity(ShadowedVariable) != Off || config.severity(EmptyBlock) != Error || config.severity(UnusedVariable) != Warning):
+			t.Errorf("%s: got %v", test.file, config.Severities)
+		case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+			t.Errorf("%s: got %v, want an error containing %q", test.file, err, test.want)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loading a missing file succeeded")
+	}
+}
@@ -0,0 +1,406 @@
+package lint
+
+import (
+	"Glox/ast"
+	"Glox/token"
+	"fmt"
+)
+
+// statements walks a list of statements and reports the first one that
+// can't run because an earlier one always jumps away.
+func (l *linter) statements(statements []ast.Statement) {
+	reported := false
+	for n, stmt := range statements {
+		if n > 0 && !reported && terminates(statements[n-1]) {
+			if at, ok := ast.FirstToken(stmt); ok {
+				l.report(UnreachableCode, at, "Unreachable code.")
+			}
+			reported = true
+		}
+		l.statement(stmt)
+	}
+}
+
+// terminates reports whether a statement always returns, throws, breaks
+// or continues.
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStmt, *ast.ThrowStmt, *ast.BreakStmt, *ast.ContinueStmt:
+		return true
+	case *ast.BlockStmt:
+		for _, inner := range stmt.Statements {
+			if terminates(inner) {
+				return true
+			}
+		}
+	case *ast.IfStmt:
+		return stmt.ElseBranch != nil && terminates(stmt.ThenBranch) && terminates(stmt.ElseBranch)
+	}
+	return false
+}
+
+func (l *linter) statement(stmt ast.Statement) {
+	stmt.Accept(l)
+}
+
+func (l *linter) expression(expr ast.Expression) {
+	expr.Accept(l)
+}
+
+// block walks the statements of a block in a new scope.
+func (l *linter) block(brace token.Token, statements []ast.Statement) {
+	if len(statements) == 0 && brace.Line > 0 && !l.hasComment(brace) {
+		l.report(EmptyBlock, brace, "Empty block.")
+	}
+	l.beginScope()
+	l.statements(statements)
+	l.endScope()
+}
+
+// function declares the parameters of a function and walks its body once
+// the current scope ends.
+func (l *linter) function(params []token.Token, body []ast.Statement) {
+	l.later(func() {
+		l.beginScope()
+		for _, param := range params {
+			l.declare(param, parameter)
+		}
+		l.statements(body)
+		l.endScope()
+	})
+}
+
+func (l *linter) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		l.expression(pattern.Value)
+	case *ast.BindingPattern:
+		// The patterns of a case bind the same names, once.
+		if _, ok := l.scope.locals[pattern.Name.Lexeme]; !ok && pattern.Name.Lexeme != "_" {
+			l.declare(pattern.Name, variable)
+		}
+	case *ast.ListPattern:
+		for _, element := range pattern.Elements {
+			l.pattern(element)
+		}
+	case *ast.InstancePattern:
+		l.expression(pattern.Class)
+		for _, sub := range pattern.Patterns {
+			l.pattern(sub)
+		}
+	}
+}
+
+// isPure reports whether evaluating expr twice has no effects, so that an
+// expression compared or assigned to itself is a mistake.
+func isPure(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.Variable, *ast.This, *ast.Super, *ast.Literal:
+		return true
+	case *ast.Grouping:
+		return isPure(expr.Expression)
+	case *ast.Get:
+		return isPure(expr.Object)
+	case *ast.Index:
+		return isPure(expr.Object) && isPure(expr.Index)
+	}
+	return false
+}
+
+func (l *linter) VisitBlockStmt(stmt *ast.BlockStmt) interface{} {
+	l.block(stmt.Brace, stmt.Statements)
+	return nil
+}
+
+func (l *linter) VisitBreakStmt(stmt *ast.BreakStmt) interface{} {
+	return nil
+}
+
+func (l *linter) VisitClassStmt(stmt *ast.ClassStmt) interface{} {
+	l.declare(stmt.Name, class)
+	if stmt.Superclass != nil {
+		l.expression(stmt.Superclass)
+	}
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		l.function(method.Params, method.Body)
+	}
+	return nil
+}
+
+func (l *linter) VisitContinueStmt(stmt *ast.ContinueStmt) interface{} {
+	return nil
+}
+
+func (l *linter) VisitExportStmt(stmt *ast.ExportStmt) interface{} {
+	l.statement(stmt.Declaration)
+	return nil
+}
+
+func (l *linter) VisitExpressionStmt(stmt *ast.ExpressionStmt) interface{} {
+	l.expression(stmt.Expression)
+	return nil
+}
+
+func (l *linter) VisitFunctionStmt(stmt *ast.FunStmt) interface{} {
+	l.declare(stmt.Name, function)
+	l.function(stmt.Params, stmt.Body)
+	return nil
+}
+
+func (l *linter) VisitIfStmt(stmt *ast.IfStmt) interface{} {
+	condition := stmt.Condition
+	for {
+		grouping, ok := condition.(*ast.Grouping)
+		if !ok {
+			break
+		}
+		condition = grouping.Expression
+	}
+	if literal, ok := condition.(*ast.Literal); ok {
+		truthy := literal.Value != nil && literal.Value != false
+		l.report(ConstantCondition, literal.Token, fmt.Sprintf("The condition is always %t.", truthy))
+	}
+	l.expression(stmt.Condition)
+	l.statement(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		l.statement(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (l *linter) VisitImportStmt(stmt *ast.ImportStmt) interface{} {
+	if stmt.Names == nil {
+		l.declare(stmt.Alias, variable)
+		return nil
+	}
+	for _, name := range stmt.Names {
+		l.declare(name, variable)
+	}
+	return nil
+}
+
+func (l *linter) VisitMatchStmt(stmt *ast.MatchStmt) interface{} {
+	l.expression(stmt.Subject)
+	for _, c := range stmt.Cases {
+		l.beginScope()
+		for _, pattern := range c.Patterns {
+			l.pattern(pattern)
+		}
+		if c.Guard != nil {
+			l.expression(c.Guard)
+		}
+		l.statement(c.Body)
+		l.endScope()
+	}
+	if stmt.Default != nil {
+		l.statement(stmt.Default)
+	}
+	return nil
+}
+
+func (l *linter) VisitPrintStmt(stmt *ast.PrintStmt) interface{} {
+	l.expression(stmt.Expression)
+	return nil
+}
+
+func (l *linter) VisitReturnStmt(stmt *ast.ReturnStmt) interface{} {
+	if stmt.Value != nil {
+		l.expression(stmt.Value)
+	}
+	return nil
+}
+
+func (l *linter) VisitThrowStmt(stmt *ast.ThrowStmt) interface{} {
+	l.expression(stmt.Value)
+	return nil
+}
+
+func (l *linter) VisitTryStmt(stmt *ast.TryStmt) interface{} {
+	l.block(stmt.Keyword, stmt.Body)
+	if stmt.Catch != nil {
+		if len(stmt.Catch.Statements) == 0 && !l.hasComment(stmt.Catch.Brace) {
+			l.report(EmptyBlock, stmt.Catch.Brace, "Empty block.")
+		}
+		l.beginScope()
+		l.declare(stmt.Param, caught)
+		l.statements(stmt.Catch.Statements)
+		l.endScope()
+	}
+	if stmt.Finally != nil {
+		l.statement(stmt.Finally)
+	}
+	return nil
+}
+
+func (l *linter) VisitVarStmt(stmt *ast.VarStmt) interface{} {
+	if stmt.Initializer != nil {
+		l.expression(stmt.Initializer)
+	}
+	l.declare(stmt.Name, variable)
+	return nil
+}
+
+func (l *linter) VisitWhileStmt(stmt *ast.WhileStmt) interface{} {
+	l.expression(stmt.Condition)
+	l.statement(stmt.Body)
+	if stmt.Increment != nil {
+		l.expression(stmt.Increment)
+	}
+	return nil
+}
+
+func (l *linter) VisitAssignExpr(expr *ast.Assign) interface{} {
+	if value, ok := expr.Value.(*ast.Variable); ok && value.Name.Lexeme == expr.Name.Lexeme {
+		l.report(SelfAssignment, expr.Name, fmt.Sprintf("'%s' is assigned to itself.", expr.Name.Lexeme))
+	}
+	// Assigning to a variable isn't using it.
+	l.expression(expr.Value)
+	return nil
+}
+
+func (l *linter) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	switch expr.Operator.Type {
+	case token.EQUAL_EQUAL, token.BANG_EQUAL, token.LESS, token.LESS_EQUAL, token.GREATER, token.GREATER_EQUAL:
+		if _, constant := expr.Left.(*ast.Literal); !constant && isPure(expr.Left) && ast.Equal(expr.Left, expr.Right) {
+			l.report(SelfComparison, expr.Operator, fmt.Sprintf("'%s' is compared with itself.", expr.Left))
+		}
+	}
+	l.expression(expr.Left)
+	l.expression(expr.Right)
+	return nil
+}
+
+func (l *linter) VisitCallExpr(expr *ast.Call) interface{} {
+	l.expression(expr.Callee)
+	for _, argument := range expr.Arguments {
+		l.expression(argument)
+	}
+	return nil
+}
+
+func (l *linter) VisitCommaExpr(expr *ast.Comma) interface{} {
+	l.expression(expr.Left)
+	l.expression(expr.Right)
+	return nil
+}
+
+func (l *linter) VisitCompoundAssignExpr(expr *ast.CompoundAssign) interface{} {
+	l.expression(expr.Target)
+	l.expression(expr.Value)
+	return nil
+}
+
+func (l *linter) VisitConditionalExpr(expr *ast.Conditional) interface{} {
+	l.expression(expr.Condition)
+	l.expression(expr.Then)
+	l.expression(expr.Else)
+	return nil
+}
+
+func (l *linter) VisitGetExpr(expr *ast.Get) interface{} {
+	l.expression(expr.Object)
+	return nil
+}
+
+func (l *linter) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	l.expression(expr.Expression)
+	return nil
+}
+
+func (l *linter) VisitIncrementExpr(expr *ast.Increment) interface{} {
+	l.expression(expr.Target)
+	return nil
+}
+
+func (l *linter) VisitIndexExpr(expr *ast.Index) interface{} {
+	l.expression(expr.Object)
+	l.expression(expr.Index)
+	return nil
+}
+
+func (l *linter) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	for _, part := range expr.Parts {
+		l.expression(part)
+	}
+	return nil
+}
+
+func (l *linter) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	l.function(expr.Params, expr.Body)
+	return nil
+}
+
+func (l *linter) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		l.expression(element)
+	}
+	return nil
+}
+
+func (l *linter) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	return nil
+}
+
+func (l *linter) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	l.expression(expr.Left)
+	l.expression(expr.Right)
+	return nil
+}
+
+func (l *linter) VisitMapExpr(expr *ast.Map) interface{} {
+	for n, key := range expr.Keys {
+		l.expression(key)
+		l.expression(expr.Values[n])
+	}
+	return nil
+}
+
+func (l *linter) VisitSetExpr(expr *ast.Set) interface{} {
+	if value, ok := expr.Value.(*ast.Get); ok && value.Name.Lexeme == expr.Name.Lexeme && isPure(expr.Object) && ast.Equal(expr.Object, value.Object) {
+		l.report(SelfAssignment, expr.Name, fmt.Sprintf("'%s' is assigned to itself.", value))
+	}
+	l.expression(expr.Object)
+	l.expression(expr.Value)
+	return nil
+}
+
+func (l *linter) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	if value, ok := expr.Value.(*ast.Index); ok && isPure(expr.Object) && isPure(expr.Index) &&
+		ast.Equal(expr.Object, value.Object) && ast.Equal(expr.Index, value.Index) {
+		l.report(SelfAssignment, expr.Bracket, fmt.Sprintf("'%s' is assigned to itself.", value))
+	}
+	l.expression(expr.Object)
+	l.expression(expr.Index)
+	l.expression(expr.Value)
+	return nil
+}
+
+func (l *linter) VisitSliceExpr(expr *ast.Slice) interface{} {
+	l.expression(expr.Object)
+	if expr.Start != nil {
+		l.expression(expr.Start)
+	}
+	if expr.End != nil {
+		l.expression(expr.End)
+	}
+	return nil
+}
+
+func (l *linter) VisitSuperExpr(expr *ast.Super) interface{} {
+	return nil
+}
+
+func (l *linter) VisitThisExpr(expr *ast.This) interface{} {
+	return nil
+}
+
+func (l *linter) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	l.expression(expr.Right)
+	return nil
+}
+
+func (l *linter) VisitVariableExpr(expr *ast.Variable) interface{} {
+	l.use(expr.Name)
+	return nil
+}
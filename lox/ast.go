@@ -0,0 +1,60 @@
+package lox
+
+import (
+	"Glox/ast"
+	"Glox/resolver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// PrintAST prints the syntax tree of a script, as JSON when asJSON is set
+// and as source-like text otherwise. A path ending in .json is read as a
+// tree in that JSON form, such as one another tool produced, rather than
+// parsed. It returns whether the tree was read and resolved.
+func PrintAST(path string, asJSON bool) bool {
+	read := parseFile
+	if strings.HasSuffix(path, ".json") {
+		read = readJSONFile
+	}
+	statements, ok := read(path)
+	if !ok {
+		return false
+	}
+	if !asJSON {
+		for _, stmt := range statements {
+			fmt.Println(stmt.String())
+		}
+		return true
+	}
+	data, err := ast.ToJSON(statements)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	os.Stdout.Write(data)
+	fmt.Println()
+	return true
+}
+
+// readJSONFile reads and resolves a tree ToJSON encoded.
+func readJSONFile(path string) ([]ast.Statement, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+	statements, err := ast.FromJSON(data)
+	if err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		return nil, false
+	}
+	r := resolver.NewResolver()
+	r.Resolve(statements)
+	if len(r.Errors()) > 0 {
+		printFileErrors(path, r.Errors())
+		return nil, false
+	}
+	return statements, true
+}
@@ -0,0 +1,31 @@
+package lox
+
+import (
+	"Glox/checker"
+	"fmt"
+)
+
+// CheckFile reports the errors in a script without running it: syntax
+// errors, resolver errors and, when types is set, type errors. It returns
+// whether the script is clean.
+func CheckFile(path string, types bool) bool {
+	statements, ok := parseFile(path)
+	if !ok {
+		return false
+	}
+	if types {
+		c := checker.NewChecker()
+		c.Check(statements)
+		if len(c.Errors()) > 0 {
+			printFileErrors(path, c.Errors())
+			return false
+		}
+	}
+	return true
+}
+
+func printFileErrors(path string, errors []string) {
+	for _, msg := range errors {
+		fmt.Printf("%s: %s\n", path, msg)
+	}
+}
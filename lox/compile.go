@@ -0,0 +1,137 @@
+package lox
+
+import (
+	"Glox/ast"
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"Glox/token"
+	"Glox/vm"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// CompileFile compiles a script to bytecode and saves it in the .loxc
+// format at out, or next to the script when out is empty. It returns
+// whether it succeeded.
+func CompileFile(path string, out string) bool {
+	function, ok := compileFile(path)
+	if !ok {
+		return false
+	}
+	if out == "" {
+		out = strings.TrimSuffix(path, ".lox") + ".loxc"
+	}
+	var buf bytes.Buffer
+	if err := vm.Encode(&buf, function); err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		return false
+	}
+	if err := ioutil.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	return true
+}
+
+// DisassembleFile prints the bytecode of a script, or of a .loxc file. It
+// returns whether it succeeded.
+func DisassembleFile(path string) bool {
+	function, ok := loadFunction(path)
+	if ok {
+		vm.Disassemble(os.Stdout, function)
+	}
+	return ok
+}
+
+// RunCompiledFile runs a .loxc file on the VM. Source files are run by
+// RunFile instead, so check with vm.IsLoxc first.
+func RunCompiledFile(path string) {
+	function, ok := loadFunction(path)
+	if !ok {
+		os.Exit(65)
+	}
+	machine := vm.NewVM()
+	machine.SetFile(path)
+	if err := machine.Run(function); err != nil {
+		fmt.Println(err)
+		os.Exit(70)
+	}
+}
+
+// IsCompiledFile reports whether path holds compiled bytecode rather than
+// source.
+func IsCompiledFile(path string) bool {
+	bytes, err := ioutil.ReadFile(path)
+	return err == nil && vm.IsLoxc(bytes)
+}
+
+// loadFunction decodes a .loxc file, or compiles a source file.
+func loadFunction(path string) (*vm.Function, bool) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+	if !vm.IsLoxc(bytes) {
+		return compileFile(path)
+	}
+	function, err := vm.Decode(bytes)
+	if err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		return nil, false
+	}
+	return function, true
+}
+
+func compileFile(path string) (*vm.Function, bool) {
+	statements, ok := parseFile(path)
+	if !ok {
+		return nil, false
+	}
+	function, err := vm.Compile(statements, "<script>")
+	if err != nil {
+		printFileErrors(path, err.(*vm.CompileError).Errors)
+		return nil, false
+	}
+	return function, true
+}
+
+// parseFile scans, parses and resolves a script, printing the errors
+// prefixed with its path.
+func parseFile(path string) ([]ast.Statement, bool) {
+	statements, _, ok := parseFileWithTrivia(path)
+	return statements, ok
+}
+
+// parseFileWithTrivia is parseFile for tools that look at comments: it
+// also returns the tokens of the script with their trivia.
+func parseFileWithTrivia(path string) ([]ast.Statement, []token.Token, bool) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		return nil, nil, false
+	}
+	s := scanner.NewTriviaScanner(string(bytes))
+	tokens := s.ScanTokens()
+	if len(s.Errors()) > 0 {
+		printFileErrors(path, s.Errors())
+		return nil, nil, false
+	}
+	p := parser.NewParser(scanner.WithoutTrivia(tokens))
+	statements := p.Parse()
+	if len(p.Errors()) > 0 {
+		printFileErrors(path, p.Errors())
+		return nil, nil, false
+	}
+	r := resolver.NewResolver()
+	r.Resolve(statements)
+	if len(r.Errors()) > 0 {
+		printFileErrors(path, r.Errors())
+		return nil, nil, false
+	}
+	return statements, tokens, true
+}
@@ -0,0 +1,51 @@
+package lox
+
+import (
+	"Glox/format"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// FormatFile formats a script. By default it prints the result; with write
+// it saves the result over the script when they differ, and with check it
+// prints the path of the script when it isn't formatted. It returns false
+// when the script can't be formatted, or, with check, isn't formatted.
+func FormatFile(path string, write bool, check bool) bool {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	formatted, err := format.Source(string(bytes))
+	if err != nil {
+		if syntaxErr, ok := err.(*format.SyntaxError); ok {
+			printFileErrors(path, syntaxErr.Errors)
+		} else {
+			fmt.Printf("%s: %s\n", path, err)
+		}
+		return false
+	}
+	changed := formatted != string(bytes)
+	switch {
+	case check:
+		if changed {
+			fmt.Println(path)
+		}
+		return !changed
+	case write:
+		if changed {
+			info, err := os.Stat(path)
+			if err == nil {
+				err = ioutil.WriteFile(path, []byte(formatted), info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Println(err)
+				return false
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package lox
+
+import (
+	"Glox/lint"
+)
+
+// LintFile prints the lint diagnostics of a script under config. It
+// returns false when the script has errors, or diagnostics of error
+// severity.
+func LintFile(path string, config *lint.Config) bool {
+	statements, tokens, ok := parseFileWithTrivia(path)
+	if !ok {
+		return false
+	}
+	clean := true
+	var messages []string
+	for _, diagnostic := range lint.Lint(statements, tokens, config) {
+		messages = append(messages, diagnostic.String())
+		if diagnostic.Severity == lint.Error {
+			clean = false
+		}
+	}
+	printFileErrors(path, messages)
+	return clean
+}
@@ -1,10 +1,13 @@
 package lox
 
 import (
-	"Glox/interpreter"
+	"Glox/ast"
 	"Glox/parser"
+	"Glox/resolver"
 	"Glox/scanner"
+	"Glox/vm"
 	"bufio"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,9 +15,19 @@ import (
 )
 
 // hadError will ensure don't try to execute code that has a known error.
-var HadError = true
+var HadError = false
 
-func RunFile(path string, i *interpreter.Interpreter) {
+// HadRuntimeError is set when a script stops on a runtime error.
+var HadRuntimeError = false
+
+// Engine executes resolved statements: the tree-walking interpreter or the
+// bytecode VM.
+type Engine interface {
+	Interpret(statements []ast.Statement) error
+	SetFile(path string)
+}
+
+func RunFile(path string, i Engine) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		fmt.Printf("file does not exist: %s", path)
 		os.Exit(65)
@@ -23,13 +36,18 @@ func RunFile(path string, i *interpreter.Interpreter) {
 	if err != nil {
 		panic(err)
 	}
-	run(string(bytes), i)
+	i.SetFile(path)
+	run(string(bytes), i, resolver.NewResolver())
 	if HadError {
 		os.Exit(65)
 	}
+	if HadRuntimeError {
+		os.Exit(70)
+	}
 }
 
-func RunPrompt(i *interpreter.Interpreter) {
+func RunPrompt(i Engine) {
+	r := resolver.NewResolver()
 	mode := "console"
 	//mode := "debug"
 	if mode != "debug" {
@@ -50,7 +68,7 @@ func RunPrompt(i *interpreter.Interpreter) {
 			if line == "quit" {
 				break
 			}
-			run(line, i)
+			run(line, i, r)
 		}
 	} else {
 		line := `
@@ -58,15 +76,16 @@ func RunPrompt(i *interpreter.Interpreter) {
 		var name = "Irwin";
 		print name;
 		`
-		run(line, i)
+		run(line, i, r)
 	}
 }
 
-func run(source string, i *interpreter.Interpreter) bool {
+func run(source string, i Engine, r *resolver.Resolver) bool {
 	s := scanner.NewScanner(source)
 	tokens := s.ScanTokens()
 	if len(s.Errors()) > 0 {
 		printErrors(s.Errors())
+		HadError = true
 		return false
 	}
 
@@ -74,10 +93,27 @@ func run(source string, i *interpreter.Interpreter) bool {
 	statements := p.Parse()
 	if len(p.Errors()) > 0 {
 		printErrors(p.Errors())
+		HadError = true
+		return false
+	}
+
+	r.Resolve(statements)
+	if len(r.Errors()) > 0 {
+		printErrors(r.Errors())
+		HadError = true
 		return false
 	}
 
-	i.Interpret(statements)
+	if err := i.Interpret(statements); err != nil {
+		fmt.Println(err)
+		var compileError *vm.CompileError
+		if errors.As(err, &compileError) {
+			HadError = true
+		} else {
+			HadRuntimeError = true
+		}
+		return false
+	}
 
 	return true
 }
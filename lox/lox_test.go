@@ -0,0 +1,118 @@
+package lox
+
+import (
+	"Glox/ast"
+	"Glox/interpreter"
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"Glox/vm"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// engines are the ways to run a script, which must print the same.
+var engines = map[string]func() Engine{
+	"tree": func() Engine { return interpreter.NewInterpreter() },
+	"vm":   func() Engine { return vm.NewVM() },
+}
+
+// TestGolden runs every script in testdata on both engines and compares
+// what it prints, runtime errors included, with the .out file next to it.
+func TestGolden(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range scripts {
+		source, err := os.ReadFile(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile(strings.TrimSuffix(script, ".lox") + ".out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, engine := range engines {
+			e := engine()
+			e.SetFile(script)
+			output := captureStdout(t, func() {
+				run(string(source), e, resolver.NewResolver())
+			})
+			if output != string(want) {
+				t.Errorf("%s on %s: got\n%s\nwant\n%s", script, name, output, want)
+			}
+		}
+	}
+}
+
+func TestPrintASTFromJSON(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lox")
+	source := "class A < B { m() { return super.m() + this.x; } }\nvar f = (x) => x[1:];\n"
+	if err := os.WriteFile(script, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var ok bool
+	tree := filepath.Join(dir, "tree.json")
+	data := captureStdout(t, func() { ok = PrintAST(script, true) })
+	if !ok {
+		t.Fatalf("printing the tree of %q failed: %s", source, data)
+	}
+	if err := os.WriteFile(tree, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := captureStdout(t, func() { PrintAST(script, false) })
+	if got := captureStdout(t, func() { ok = PrintAST(tree, false) }); !ok || got != want {
+		t.Errorf("got %q from the JSON tree, want %q", got, want)
+	}
+	if got := captureStdout(t, func() { ok = PrintAST(tree, true) }); !ok || got != data {
+		t.Errorf("re-encoding the JSON tree changed it:\n%s", got)
+	}
+
+	// Trees from JSON are checked like parsed ones.
+	statements := parser.NewParser(scanner.NewScanner("const a = 1;\na = 2;").ScanTokens()).Parse()
+	constant, err := ast.ToJSON(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for document, want := range map[string]string{
+		`[{"node": "ReturnStmt"}]`: "$[0].Keyword: expected a token",
+		string(constant):           "Ln 2, Col 1 Cannot assign to constant 'a' declared at Ln 1, Col 7.",
+	} {
+		if err := os.WriteFile(tree, []byte(document), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got := captureStdout(t, func() { ok = PrintAST(tree, false) })
+		if ok || got != tree+": "+want+"\n" {
+			t.Errorf("got %q, %v, want the error %q", got, ok, want)
+		}
+	}
+}
+
+// captureStdout returns what f prints, print statements write to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		io.Copy(&out, reader)
+		done <- out.String()
+	}()
+	f()
+	writer.Close()
+	return <-done
+}
@@ -0,0 +1,402 @@
+package lsp
+
+import (
+	"Glox/ast"
+	"Glox/lint"
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"Glox/token"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is an open file and what the server knows about it. It is
+// analyzed again from scratch on every change.
+type document struct {
+	uri   string
+	lines []string
+
+	all        []token.Token // the tokens, trivia included.
+	tokens     []token.Token // the tokens without trivia, EOF included.
+	statements []ast.Statement
+
+	diagnostics []Diagnostic
+	// The declaration each name token declares or uses, by its position.
+	declared map[[2]int]*resolver.Declaration
+	used     map[[2]int]*resolver.Declaration
+	uses     []resolver.Reference
+	methods  map[[2]int]bool // the names of methods.
+	fields   map[[2]int]bool // the names of class fields.
+}
+
+// analyze scans, parses and resolves a document like glox check, and lints
+// it when it has no errors.
+func analyze(uri string, text string) *document {
+	d := &document{
+		uri:         uri,
+		lines:       strings.Split(text, "\n"),
+		diagnostics: []Diagnostic{},
+		declared:    make(map[[2]int]*resolver.Declaration),
+		used:        make(map[[2]int]*resolver.Declaration),
+		methods:     make(map[[2]int]bool),
+		fields:      make(map[[2]int]bool),
+	}
+	s := scanner.NewTriviaScanner(text)
+	d.all = s.ScanTokens()
+	d.tokens = scanner.WithoutTrivia(d.all)
+	if len(s.Errors()) > 0 {
+		d.addErrors(s.Errors())
+		return d
+	}
+	p := parser.NewParser(d.tokens)
+	d.statements = p.Parse()
+	d.addErrors(p.Errors())
+
+	r := resolver.NewResolver()
+	r.Resolve(d.statements)
+	for _, declaration := range r.Declarations() {
+		d.declared[key(declaration.Name)] = declaration
+	}
+	d.uses = r.References()
+	for _, reference := range d.uses {
+		if reference.Declaration != nil {
+			d.used[key(reference.Name)] = reference.Declaration
+		}
+	}
+	d.members(d.statements)
+	if len(p.Errors()) > 0 {
+		// Resolver errors in a partial tree would only be noise.
+		return d
+	}
+	d.addErrors(r.Errors())
+	if len(r.Errors()) == 0 {
+		for _, diagnostic := range lint.Lint(d.statements, d.all, nil) {
+			severity := SeverityWarning
+			if diagnostic.Severity == lint.Error {
+				severity = SeverityError
+			}
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.tokenRange(diagnostic.Token),
+				Severity: severity,
+				Code:     diagnostic.Rule,
+				Source:   "glox lint",
+				Message:  diagnostic.Message,
+			})
+		}
+	}
+	return d
+}
+
+func key(tok token.Token) [2]int {
+	return [2]int{tok.Line, tok.Col}
+}
+
+// addErrors adds the errors of the scanner, parser or resolver, which are
+// "Ln 1, Col 2 message" or "at end: message".
+func (d *document) addErrors(errors []string) {
+	for _, msg := range errors {
+		var line, col int
+		var r Range
+		if _, err := fmt.Sscanf(msg, "Ln %d, Col %d ", &line, &col); err == nil {
+			msg = strings.TrimPrefix(msg, fmt.Sprintf("Ln %d, Col %d ", line, col))
+			r = d.errorRange(line, col)
+		} else {
+			msg = strings.TrimPrefix(msg, "at end: ")
+			end := d.position(len(d.lines), utf8.RuneCountInString(d.line(len(d.lines)))+1)
+			r = Range{Start: end, End: end}
+		}
+		d.diagnostics = append(d.diagnostics, Diagnostic{Range: r, Severity: SeverityError, Source: "glox", Message: msg})
+	}
+}
+
+// errorRange is the range of the token that ends at line and col, or of
+// the character there if no token does.
+func (d *document) errorRange(line int, col int) Range {
+	n := sort.Search(len(d.tokens), func(n int) bool {
+		tok := d.tokens[n]
+		return tok.Type == token.EOF || tok.Line > line || tok.Line == line && tok.Col >= col
+	})
+	if n < len(d.tokens) && d.tokens[n].Line == line && d.tokens[n].Col == col {
+		return d.tokenRange(d.tokens[n])
+	}
+	return Range{Start: d.position(line, col), End: d.position(line, col+1)}
+}
+
+// members records the names of methods and fields, which the resolver
+// doesn't declare.
+func (d *document) members(statements []ast.Statement) {
+	walkStatements(statements, func(stmt ast.Statement) {
+		if class, ok := stmt.(*ast.ClassStmt); ok {
+			for _, method := range class.Methods {
+				d.methods[key(method.(*ast.FunStmt).Name)] = true
+			}
+			for _, field := range class.Fields {
+				d.fields[key(field.Name)] = true
+			}
+		}
+	})
+}
+
+// walkStatements calls visit for every statement, including the ones in
+// the bodies of other statements and functions.
+func walkStatements(statements []ast.Statement, visit func(ast.Statement)) {
+	for _, stmt := range statements {
+		visit(stmt)
+		switch stmt := stmt.(type) {
+		case *ast.ClassStmt:
+			for _, method := range stmt.Methods {
+				walkStatements(method.(*ast.FunStmt).Body, visit)
+			}
+		case *ast.FunStmt:
+			walkStatements(stmt.Body, visit)
+		default:
+			walkStatements(nested(stmt), visit)
+		}
+	}
+}
+
+// nested returns the statements in blocks and control flow statements.
+func nested(stmt ast.Statement) []ast.Statement {
+	var statements []ast.Statement
+	switch stmt := stmt.(type) {
+	case *ast.BlockStmt:
+		statements = stmt.Statements
+	case *ast.ExportStmt:
+		statements = []ast.Statement{stmt.Declaration}
+	case *ast.IfStmt:
+		statements = []ast.Statement{stmt.ThenBranch}
+		if stmt.ElseBranch != nil {
+			statements = append(statements, stmt.ElseBranch)
+		}
+	case *ast.MatchStmt:
+		for _, c := range stmt.Cases {
+			statements = append(statements, c.Body)
+		}
+		if stmt.Default != nil {
+			statements = append(statements, stmt.Default)
+		}
+	case *ast.TryStmt:
+		statements = append(statements, stmt.Body...)
+		if stmt.Catch != nil {
+			statements = append(statements, stmt.Catch)
+		}
+		if stmt.Finally != nil {
+			statements = append(statements, stmt.Finally)
+		}
+	case *ast.WhileStmt:
+		statements = []ast.Statement{stmt.Body}
+	}
+	return statements
+}
+
+// line returns a line of the text by its one-based number.
+func (d *document) line(n int) string {
+	if n < 1 || n > len(d.lines) {
+		return ""
+	}
+	return d.lines[n-1]
+}
+
+// position converts a one-based line and rune column to a Position. col is
+// the column of the character the position is in front of.
+func (d *document) position(line int, col int) Position {
+	text := []rune(d.line(line))
+	if col-1 > len(text) {
+		col = len(text) + 1
+	}
+	if col < 1 {
+		col = 1
+	}
+	return Position{Line: line - 1, Character: len(utf16.Encode(text[:col-1]))}
+}
+
+// tokenRange returns the range of a token, which sits at its last
+// character and may span lines.
+func (d *document) tokenRange(tok token.Token) Range {
+	startLine := tok.Line - strings.Count(tok.Lexeme, "\n")
+	var startCol int
+	if startLine == tok.Line {
+		startCol = tok.Col - utf8.RuneCountInString(tok.Lexeme) + 1
+	} else {
+		first := tok.Lexeme[:strings.IndexByte(tok.Lexeme, '\n')]
+		startCol = utf8.RuneCountInString(d.line(startLine)) - utf8.RuneCountInString(first) + 1
+	}
+	return Range{Start: d.position(startLine, startCol), End: d.position(tok.Line, tok.Col+1)}
+}
+
+// nameAt returns the identifier at p.
+func (d *document) nameAt(p Position) (token.Token, bool) {
+	for _, tok := range d.tokens {
+		if tok.Type == token.IDENTIFIER && d.tokenRange(tok).contains(p) {
+			return tok, true
+		}
+	}
+	return token.Token{}, false
+}
+
+// declarationAt returns the declaration of the name at p, which is either
+// declared or used there.
+func (d *document) declarationAt(p Position) (*resolver.Declaration, token.Token, bool) {
+	name, ok := d.nameAt(p)
+	if !ok {
+		return nil, name, false
+	}
+	if declaration, ok := d.declared[key(name)]; ok {
+		return declaration, name, true
+	}
+	declaration, ok := d.used[key(name)]
+	return declaration, name, ok
+}
+
+func (d *document) location(tok token.Token) Location {
+	return Location{URI: d.uri, Range: d.tokenRange(tok)}
+}
+
+// hover describes the name at p by its kind and the line that declares it.
+func (d *document) hover(p Position) *Hover {
+	declaration, name, ok := d.declarationAt(p)
+	if !ok {
+		return nil
+	}
+	text := fmt.Sprintf("(%s) %s\n\n```glox\n%s\n```\n\nDeclared on line %d.",
+		declaration.Kind, declaration.Name.Lexeme, strings.TrimSpace(d.line(declaration.Name.Line)), declaration.Name.Line)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: d.tokenRange(name)}
+}
+
+func (d *document) definition(p Position) []Location {
+	declaration, _, ok := d.declarationAt(p)
+	if !ok {
+		return []Location{}
+	}
+	return []Location{d.location(declaration.Name)}
+}
+
+func (d *document) references(p Position, includeDeclaration bool) []Location {
+	locations := []Location{}
+	declaration, _, ok := d.declarationAt(p)
+	if !ok {
+		return locations
+	}
+	if includeDeclaration {
+		locations = append(locations, d.location(declaration.Name))
+	}
+	for _, reference := range d.uses {
+		if reference.Declaration == declaration {
+			locations = append(locations, d.location(reference.Name))
+		}
+	}
+	sort.SliceStable(locations, func(i, j int) bool {
+		return locations[i].Range.Start.before(locations[j].Range.Start)
+	})
+	return locations
+}
+
+// symbols lists the classes with their members and the functions, with
+// the functions declared inside them as children.
+func (d *document) symbols(statements []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.ClassStmt:
+			symbols = append(symbols, d.classSymbol(stmt))
+		case *ast.FunStmt:
+			symbol := d.symbol(stmt.Name, SymbolFunction, "function")
+			symbol.Children = d.symbols(stmt.Body)
+			symbols = append(symbols, symbol)
+		default:
+			symbols = append(symbols, d.symbols(nested(stmt))...)
+		}
+	}
+	return symbols
+}
+
+func (d *document) classSymbol(stmt *ast.ClassStmt) DocumentSymbol {
+	class := d.symbol(stmt.Name, SymbolClass, "class")
+	for _, field := range stmt.Fields {
+		class.Children = append(class.Children, DocumentSymbol{
+			Name:           field.Name.Lexeme,
+			Detail:         "field",
+			Kind:           SymbolField,
+			Range:          d.tokenRange(field.Name),
+			SelectionRange: d.tokenRange(field.Name),
+		})
+	}
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		kind, detail := SymbolMethod, "method"
+		switch {
+		case method.Kind == ast.Getter:
+			kind, detail = SymbolProperty, "getter"
+		case method.Kind == ast.Setter:
+			kind, detail = SymbolProperty, "setter"
+		case method.Kind == ast.StaticMethod:
+			detail = "static method"
+		case method.Name.Lexeme == "init":
+			kind, detail = SymbolConstructor, "initializer"
+		}
+		symbol := d.symbol(method.Name, kind, detail)
+		symbol.Children = d.symbols(method.Body)
+		class.Children = append(class.Children, symbol)
+	}
+	return class
+}
+
+// symbol makes the symbol of a declaration, spanning from its keyword to
+// its closing brace.
+func (d *document) symbol(name token.Token, kind int, detail string) DocumentSymbol {
+	selection := d.tokenRange(name)
+	r := selection
+	n := sort.Search(len(d.tokens), func(n int) bool {
+		tok := d.tokens[n]
+		return tok.Type == token.EOF || tok.Line > name.Line || tok.Line == name.Line && tok.Col >= name.Col
+	})
+	if n > 0 {
+		switch previous := d.tokens[n-1]; {
+		case previous.Type == token.FUN || previous.Type == token.CLASS,
+			previous.Type == token.IDENTIFIER && (previous.Lexeme == "static" || previous.Lexeme == "set"):
+			r.Start = d.tokenRange(previous).Start
+		}
+	}
+	if end, ok := d.closingBrace(n); ok {
+		r.End = d.tokenRange(end).End
+	}
+	return DocumentSymbol{Name: name.Lexeme, Detail: detail, Kind: kind, Range: r, SelectionRange: selection}
+}
+
+// closingBrace finds the brace that closes the body starting after token n.
+func (d *document) closingBrace(n int) (token.Token, bool) {
+	depth := 0
+	for ; n < len(d.tokens); n++ {
+		switch d.tokens[n].Type {
+		case token.LEFT_PAREN:
+			depth++
+		case token.RIGHT_PAREN:
+			depth--
+		case token.LEFT_BRACE:
+			if depth == 0 {
+				return d.matchingBrace(n)
+			}
+		}
+	}
+	return token.Token{}, false
+}
+
+func (d *document) matchingBrace(n int) (token.Token, bool) {
+	depth := 0
+	for ; n < len(d.tokens); n++ {
+		switch d.tokens[n].Type {
+		case token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_BRACE:
+			depth--
+			if depth == 0 {
+				return d.tokens[n], true
+			}
+		}
+	}
+	return token.Token{}, false
+}
@@ -0,0 +1,145 @@
+package lsp
+
+import (
+	"Glox/resolver"
+	"Glox/token"
+	"strings"
+	"unicode/utf16"
+)
+
+// The semantic token types and modifiers, in the order of their indices.
+var legend = SemanticTokensLegend{
+	TokenTypes: []string{
+		"class", "parameter", "variable", "property", "function", "method",
+		"keyword", "comment", "string", "number", "operator",
+	},
+	TokenModifiers: []string{"declaration", "readonly"},
+}
+
+const (
+	semanticClass = iota
+	semanticParameter
+	semanticVariable
+	semanticProperty
+	semanticFunction
+	semanticMethod
+	semanticKeyword
+	semanticComment
+	semanticString
+	semanticNumber
+	semanticOperator
+)
+
+const (
+	modifierDeclaration = 1 << iota
+	modifierReadonly
+)
+
+// semanticTokens classifies the tokens of the document. Each token is five
+// numbers: its line and start relative to the previous token, its length,
+// its type and its modifiers. Tokens that span lines are split at the
+// line breaks.
+func (d *document) semanticTokens() SemanticTokens {
+	data := []int{}
+	line, start := 0, 0
+	for n, tok := range d.all {
+		kind, modifiers, ok := d.classify(n)
+		if !ok {
+			continue
+		}
+		r := d.tokenRange(tok)
+		for i, text := range strings.Split(tok.Lexeme, "\n") {
+			at := Position{Line: r.Start.Line + i}
+			if i == 0 {
+				at.Character = r.Start.Character
+			}
+			length := len(utf16.Encode([]rune(strings.TrimSuffix(text, "\r"))))
+			if length == 0 {
+				continue
+			}
+			if at.Line != line {
+				start = 0
+			}
+			data = append(data, at.Line-line, at.Character-start, length, kind, modifiers)
+			line, start = at.Line, at.Character
+		}
+	}
+	return SemanticTokens{Data: data}
+}
+
+// classify returns the semantic type and modifiers of d.all[n], or false
+// for tokens that have none, like punctuation.
+func (d *document) classify(n int) (int, int, bool) {
+	tok := d.all[n]
+	switch {
+	case tok.Type == token.COMMENT:
+		return semanticComment, 0, true
+	case tok.Type == token.STRING || tok.Type == token.INTERPOLATION:
+		return semanticString, 0, true
+	case tok.Type == token.NUMBER:
+		return semanticNumber, 0, true
+	case tok.Type >= token.AND && tok.Type <= token.WHILE:
+		return semanticKeyword, 0, true
+	case tok.Type >= token.MINUS && tok.Type <= token.MINUS_MINUS && tok.Type != token.SEMICOLON:
+		return semanticOperator, 0, true
+	case tok.Type != token.IDENTIFIER:
+		return 0, 0, false
+	}
+	if declaration, ok := d.declared[key(tok)]; ok {
+		kind, modifiers := declarationType(declaration)
+		return kind, modifiers | modifierDeclaration, true
+	}
+	if declaration, ok := d.used[key(tok)]; ok {
+		kind, modifiers := declarationType(declaration)
+		return kind, modifiers, true
+	}
+	if d.methods[key(tok)] {
+		return semanticMethod, modifierDeclaration, true
+	}
+	if d.fields[key(tok)] {
+		return semanticProperty, modifierDeclaration, true
+	}
+	previous, next := d.neighbors(n)
+	switch {
+	case previous.Type == token.DOT && next.Type == token.LEFT_PAREN:
+		return semanticMethod, 0, true
+	case previous.Type == token.DOT:
+		return semanticProperty, 0, true
+	case next.Type == token.LEFT_PAREN:
+		// Natives, which no declaration introduces.
+		return semanticFunction, 0, true
+	}
+	return 0, 0, false
+}
+
+func declarationType(declaration *resolver.Declaration) (int, int) {
+	switch declaration.Kind {
+	case resolver.Constant:
+		return semanticVariable, modifierReadonly
+	case resolver.Parameter:
+		return semanticParameter, 0
+	case resolver.Function:
+		return semanticFunction, 0
+	case resolver.Class:
+		return semanticClass, 0
+	}
+	return semanticVariable, 0
+}
+
+// neighbors returns the tokens around d.all[n] that aren't trivia.
+func (d *document) neighbors(n int) (token.Token, token.Token) {
+	previous, next := token.Token{Type: token.EOF}, token.Token{Type: token.EOF}
+	for i := n - 1; i >= 0; i-- {
+		if !d.all[i].Type.IsTrivia() {
+			previous = d.all[i]
+			break
+		}
+	}
+	for i := n + 1; i < len(d.all); i++ {
+		if !d.all[i].Type.IsTrivia() {
+			next = d.all[i]
+			break
+		}
+	}
+	return previous, next
+}
@@ -0,0 +1,219 @@
+// Package lsp is a Language Server Protocol server for Glox. It publishes
+// the errors and lint warnings of open documents as they change, and
+// answers hover, go to definition, find references, document symbol and
+// semantic token requests using the scanner, parser and resolver.
+//
+// The server reads requests from an io.Reader and writes responses to an
+// io.Writer, so it can run over stdin and stdout or be driven by a test.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document
+
+	initialized bool
+	shutdown    bool
+	err         error // the first error writing a notification.
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, documents: make(map[string]*document)}
+}
+
+// Run serves requests until the client sends exit or in ends. It returns an
+// error if the client exits without asking the server to shut down first,
+// or the stream breaks.
+func (s *Server) Run() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return errors.New("client closed the connection without exiting")
+		}
+		if err != nil {
+			return err
+		}
+		var request message
+		if err := json.Unmarshal(body, &request); err != nil {
+			if err := s.respond(nil, nil, &responseError{parseErrorCode, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if request.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("client exited without shutting the server down")
+			}
+			return nil
+		}
+		result, rpcErr := s.handle(request.Method, request.Params)
+		if s.err != nil {
+			return s.err
+		}
+		if request.ID == nil {
+			// Notifications have no response, not even for errors.
+			continue
+		}
+		if err := s.respond(request.ID, result, rpcErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) respond(id *json.RawMessage, result interface{}, rpcErr *responseError) error {
+	response := &message{ID: id, Error: rpcErr}
+	if id == nil {
+		null := json.RawMessage("null")
+		response.ID = &null
+	}
+	if rpcErr == nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		response.Result = encoded
+	}
+	return writeMessage(s.out, response)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	encoded, err := json.Marshal(params)
+	if err == nil {
+		err = writeMessage(s.out, &message{Method: method, Params: encoded})
+	}
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// handle runs a request or a notification and returns its result.
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, *responseError) {
+	switch {
+	case method == "initialize":
+		s.initialized = true
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// Full synchronization: every change sends the whole text.
+				"textDocumentSync":       map[string]interface{}{"openClose": true, "change": 1},
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+				"semanticTokensProvider": map[string]interface{}{"legend": legend, "full": true},
+			},
+			"serverInfo": map[string]string{"name": "glox"},
+		}, nil
+	case !s.initialized:
+		return nil, &responseError{serverNotInitialized, "server is not initialized"}
+	case s.shutdown:
+		return nil, &responseError{invalidRequest, "server is shutting down"}
+	}
+
+	switch method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		s.update(p.TextDocument.URI, p.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+		return nil, nil
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.documents, p.TextDocument.URI)
+		s.publish(p.TextDocument.URI, []Diagnostic{})
+		return nil, nil
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		d, err := s.document(params, &p, &p.TextDocument)
+		if err != nil || d == nil {
+			return nil, err
+		}
+		if hover := d.hover(p.Position); hover != nil {
+			return hover, nil
+		}
+		return nil, nil
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		d, err := s.document(params, &p, &p.TextDocument)
+		if err != nil || d == nil {
+			return []Location{}, err
+		}
+		return d.definition(p.Position), nil
+	case "textDocument/references":
+		var p ReferenceParams
+		d, err := s.document(params, &p, &p.TextDocument)
+		if err != nil || d == nil {
+			return []Location{}, err
+		}
+		return d.references(p.Position, p.Context.IncludeDeclaration), nil
+	case "textDocument/documentSymbol":
+		var p DocumentParams
+		d, err := s.document(params, &p, &p.TextDocument)
+		if err != nil || d == nil {
+			return []DocumentSymbol{}, err
+		}
+		return d.symbols(d.statements), nil
+	case "textDocument/semanticTokens/full":
+		var p DocumentParams
+		d, err := s.document(params, &p, &p.TextDocument)
+		if err != nil || d == nil {
+			return SemanticTokens{Data: []int{}}, err
+		}
+		return d.semanticTokens(), nil
+	}
+	return nil, &responseError{methodNotFound, fmt.Sprintf("method %q is not supported", method)}
+}
+
+func decodeParams(params json.RawMessage, v interface{}) *responseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{invalidParams, err.Error()}
+	}
+	return nil
+}
+
+// document decodes the params of a request about a document into v, and
+// returns the document its id names, or nil if it isn't open.
+func (s *Server) document(params json.RawMessage, v interface{}, id *TextDocumentIdentifier) (*document, *responseError) {
+	if err := decodeParams(params, v); err != nil {
+		return nil, err
+	}
+	return s.documents[id.URI], nil
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri string, text string) {
+	d := analyze(uri, text)
+	s.documents[uri] = d
+	s.publish(uri, d.diagnostics)
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) {
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
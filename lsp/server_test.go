@@ -0,0 +1,329 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a Server over pipes the way an editor would: it writes
+// requests and notifications, and reads what the server sends back.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan message
+	// pending are the notifications read while waiting for a response.
+	pending []message
+	id      int
+	done    chan error
+}
+
+const uri = "file:///test.lox"
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	c := &client{t: t, in: inWriter, messages: make(chan message), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inReader, outWriter).Run()
+		outWriter.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		r := bufio.NewReader(outReader)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			var m message
+			if err := json.Unmarshal(body, &m); err != nil {
+				t.Errorf("server sent %s: %s", body, err)
+				return
+			}
+			c.messages <- m
+		}
+	}()
+	return c
+}
+
+// initialize starts a client with the server initialized.
+func initialize(t *testing.T) *client {
+	t.Helper()
+	c := newClient(t)
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func (c *client) send(m *message) {
+	c.t.Helper()
+	if err := writeMessage(c.in, m); err != nil {
+		c.t.Fatalf("sending %s: %s", m.Method, err)
+	}
+}
+
+func (c *client) receive() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return message{}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(&message{Method: method, Params: encoded})
+}
+
+// request sends a request and returns the response to it.
+func (c *client) request(method string, params interface{}) message {
+	c.t.Helper()
+	c.id++
+	id := mustMarshal(c.t, c.id)
+	c.send(&message{ID: &id, Method: method, Params: mustMarshal(c.t, params)})
+	for {
+		m := c.receive()
+		if m.ID == nil {
+			c.pending = append(c.pending, m)
+			continue
+		}
+		if string(*m.ID) != string(id) {
+			c.t.Fatalf("got the response to %s, want the one to %s", *m.ID, id)
+		}
+		return m
+	}
+}
+
+// call sends a request and decodes its result into result, failing the
+// test if it returns an error.
+func (c *client) call(method string, params interface{}, result interface{}) {
+	c.t.Helper()
+	m := c.request(method, params)
+	if m.Error != nil {
+		c.t.Fatalf("%s: %s", method, m.Error.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(m.Result, result); err != nil {
+			c.t.Fatalf("%s: decoding %s: %s", method, m.Result, err)
+		}
+	}
+}
+
+// diagnostics returns the next diagnostics the server publishes.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	var m message
+	if len(c.pending) > 0 {
+		m, c.pending = c.pending[0], c.pending[1:]
+	} else {
+		m = c.receive()
+	}
+	if m.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("got %s, want diagnostics", m.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(m.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+// open opens a document and returns its diagnostics.
+func (c *client) open(text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "glox", Version: 1, Text: text},
+	})
+	return c.diagnostics().Diagnostics
+}
+
+// exit shuts the server down and checks that it stops cleanly.
+func (c *client) exit() {
+	c.t.Helper()
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("the server stopped with %s", err)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: Position{line, start}, End: Position{line, end}}
+}
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+	if m := c.request("textDocument/hover", at(0, 0)); m.Error == nil || m.Error.Code != serverNotInitialized {
+		t.Errorf("hover before initialize: got %+v, want error %d", m.Error, serverNotInitialized)
+	}
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result)
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "referencesProvider", "documentSymbolProvider"} {
+		if result.Capabilities[capability] != true {
+			t.Errorf("%s is %v, want true", capability, result.Capabilities[capability])
+		}
+	}
+	if m := c.request("textDocument/formatting", nil); m.Error == nil || m.Error.Code != methodNotFound {
+		t.Errorf("unknown method: got %+v, want error %d", m.Error, methodNotFound)
+	}
+	c.exit()
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := initialize(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err == nil {
+		t.Error("the server stopped cleanly, want an error")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := initialize(t)
+	defer c.exit()
+
+	got := c.open("var a = 1;\nprint a +;\n")
+	want := []Diagnostic{{Range: span(1, 9, 10), Severity: SeverityError, Source: "glox", Message: "Expect expression."}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parse error: got %+v, want %+v", got, want)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "{\n  var unused = 1;\n}\n"}},
+	})
+	got = c.diagnostics().Diagnostics
+	if len(got) != 1 || got[0].Code != "unused-variable" || got[0].Severity != SeverityWarning || got[0].Range != span(1, 6, 12) {
+		t.Errorf("lint warning: got %+v, want unused-variable on unused", got)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if got := c.diagnostics(); got.URI != uri || len(got.Diagnostics) != 0 {
+		t.Errorf("closing: got %+v, want no diagnostics", got)
+	}
+}
+
+const source = `var count = 0;
+fun add(n) {
+  count = count + n;
+}
+add(2);
+`
+
+func TestHover(t *testing.T) {
+	c := initialize(t)
+	defer c.exit()
+	c.open(source)
+
+	var hover Hover
+	c.call("textDocument/hover", at(2, 11), &hover)
+	if want := span(2, 10, 15); hover.Range != want {
+		t.Errorf("got range %+v, want %+v", hover.Range, want)
+	}
+	if !strings.HasPrefix(hover.Contents.Value, "(variable) count") || !strings.Contains(hover.Contents.Value, "var count = 0;") {
+		t.Errorf("got %q, want the variable and its declaration", hover.Contents.Value)
+	}
+	c.call("textDocument/hover", at(2, 19), &hover)
+	if !strings.HasPrefix(hover.Contents.Value, "(parameter) n") {
+		t.Errorf("got %q, want the parameter", hover.Contents.Value)
+	}
+
+	// Nothing is declared at a keyword or past the end of the text.
+	for _, p := range []TextDocumentPositionParams{at(1, 1), at(9, 0)} {
+		if m := c.request("textDocument/hover", p); m.Error != nil || string(m.Result) != "null" {
+			t.Errorf("hover at %+v: got %s, %+v, want null", p.Position, m.Result, m.Error)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := initialize(t)
+	defer c.exit()
+	c.open(source)
+
+	tests := []struct {
+		name string
+		at   TextDocumentPositionParams
+		want []Location
+	}{
+		{"use", at(4, 1), []Location{{URI: uri, Range: span(1, 4, 7)}}},
+		{"assignment", at(2, 2), []Location{{URI: uri, Range: span(0, 4, 9)}}},
+		{"declaration", at(1, 8), []Location{{URI: uri, Range: span(1, 8, 9)}}},
+		{"nothing", at(0, 0), []Location{}},
+	}
+	for _, test := range tests {
+		var got []Location
+		c.call("textDocument/definition", test.at, &got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	// A document that isn't open has no definitions.
+	var got []Location
+	c.call("textDocument/definition", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: "file:///other.lox"}}, &got)
+	if len(got) != 0 {
+		t.Errorf("got %+v for a closed document", got)
+	}
+}
+
+func TestUTF16Positions(t *testing.T) {
+	c := initialize(t)
+	defer c.exit()
+
+	// The emoji takes two UTF-16 code units, é one.
+	text := "var é = \"😀\"; var s = é;\nprint \"😀😀\" + s;\nprint \"😀\" +;\n"
+	diagnostics := c.open(text)
+	if want := span(2, 12, 13); len(diagnostics) != 1 || diagnostics[0].Range != want {
+		t.Errorf("got %+v, want an error at %+v", diagnostics, want)
+	}
+
+	var got []Location
+	c.call("textDocument/definition", at(1, 15), &got)
+	if want := []Location{{URI: uri, Range: span(0, 18, 19)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("s: got %+v, want %+v", got, want)
+	}
+	c.call("textDocument/definition", at(0, 22), &got)
+	if want := []Location{{URI: uri, Range: span(0, 4, 5)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("é: got %+v, want %+v", got, want)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", at(1, 15), &hover)
+	if want := span(1, 15, 16); hover.Range != want {
+		t.Errorf("got hover range %+v, want %+v", hover.Range, want)
+	}
+}
@@ -1,20 +1,211 @@
 package main
 
 import (
+	"Glox/debugger"
 	"Glox/interpreter"
+	"Glox/lint"
 	"Glox/lox"
+	"Glox/lsp"
+	"Glox/vm"
+	"flag"
 	"fmt"
 	"os"
 )
 
+const usage = `Usage:
+  glox [--engine=tree|vm] [script]       run a script, or start the REPL
+  glox run [--engine=tree|vm] file       run a script or a compiled .loxc file
+  glox check [--types] script...         report errors without running
+  glox compile [-o out.loxc] script      compile a script to bytecode
+  glox disasm file                       print the bytecode of a script or .loxc file
+  glox ast [--json] script|tree.json     print the syntax tree of a script or of a JSON tree
+  glox fmt [-w | --check] script...      format scripts, printing the result by default
+  glox lint [--config file] script...    report likely mistakes
+  glox lsp                               run the language server on stdin and stdout
+  glox debug script                      run a script under the interactive debugger`
+
 func main() {
-	i := interpreter.NewInterpreter()
 	if len(os.Args) > 1 {
-		fmt.Println("Usage: jlox [script]")
+		switch os.Args[1] {
+		case "check":
+			check(os.Args[2:])
+			return
+		case "run":
+			run(os.Args[2:], true)
+			return
+		case "compile":
+			compile(os.Args[2:])
+			return
+		case "disasm":
+			disasm(os.Args[2:])
+			return
+		case "ast":
+			printAST(os.Args[2:])
+			return
+		case "fmt":
+			formatFiles(os.Args[2:])
+			return
+		case "lint":
+			lintFiles(os.Args[2:])
+			return
+		case "lsp":
+			if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		case "debug":
+			debug(os.Args[2:])
+			return
+		}
+	}
+	run(os.Args[1:], false)
+}
+
+// run runs a script, or starts the REPL when there is none and the file
+// isn't required.
+func run(args []string, fileRequired bool) {
+	flags := flag.NewFlagSet("glox", flag.ExitOnError)
+	engine := flags.String("engine", "tree", "execution engine: tree (the interpreter) or vm (bytecode)")
+	flags.Parse(args)
+
+	var e lox.Engine
+	switch *engine {
+	case "tree":
+		e = interpreter.NewInterpreter()
+	case "vm":
+		e = vm.NewVM()
+	default:
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	if flags.NArg() > 1 || (fileRequired && flags.NArg() == 0) {
+		fmt.Println(usage)
 		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		lox.RunFile(os.Args[0], i)
+	} else if flags.NArg() == 1 {
+		if lox.IsCompiledFile(flags.Arg(0)) {
+			lox.RunCompiledFile(flags.Arg(0))
+		} else {
+			lox.RunFile(flags.Arg(0), e)
+		}
 	} else {
-		lox.RunPrompt(i)
+		lox.RunPrompt(e)
+	}
+}
+
+// debug runs a script on the tree-walking interpreter under the debugger,
+// which reads its commands from stdin.
+func debug(args []string) {
+	if len(args) != 1 {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	lox.RunFile(args[0], debugger.NewDebugger(os.Stdin, os.Stdout))
+}
+
+func check(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	types := flags.Bool("types", false, "also run the static type checker")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	clean := true
+	for _, path := range flags.Args() {
+		if !lox.CheckFile(path, *types) {
+			clean = false
+		}
+	}
+	if !clean {
+		os.Exit(65)
+	}
+}
+
+func compile(args []string) {
+	flags := flag.NewFlagSet("compile", flag.ExitOnError)
+	out := flags.String("o", "", "output file, the script's name with a .loxc extension by default")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	if !lox.CompileFile(flags.Arg(0), *out) {
+		os.Exit(65)
+	}
+}
+
+func disasm(args []string) {
+	if len(args) != 1 {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	if !lox.DisassembleFile(args[0]) {
+		os.Exit(65)
+	}
+}
+
+func printAST(args []string) {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tree as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	if !lox.PrintAST(flags.Arg(0), *asJSON) {
+		os.Exit(65)
+	}
+}
+
+func formatFiles(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result back to the scripts that aren't formatted")
+	check := flags.Bool("check", false, "list the scripts that aren't formatted instead of formatting them")
+	flags.Parse(args)
+	if flags.NArg() == 0 || (*write && *check) {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	clean := true
+	for _, path := range flags.Args() {
+		if !lox.FormatFile(path, *write, *check) {
+			clean = false
+		}
+	}
+	if !clean {
+		os.Exit(65)
+	}
+}
+
+func lintFiles(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	configPath := flags.String("config", "", "config file, "+lint.ConfigFile+" in the working directory by default")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println(usage)
+		os.Exit(64)
+	}
+	var config *lint.Config
+	if *configPath == "" {
+		if _, err := os.Stat(lint.ConfigFile); err == nil {
+			*configPath = lint.ConfigFile
+		}
+	}
+	if *configPath != "" {
+		var err error
+		if config, err = lint.LoadConfig(*configPath); err != nil {
+			fmt.Println(err)
+			os.Exit(64)
+		}
+	}
+	clean := true
+	for _, path := range flags.Args() {
+		if !lox.LintFile(path, config) {
+			clean = false
+		}
+	}
+	if !clean {
+		os.Exit(65)
 	}
 }
@@ -4,20 +4,33 @@ import (
 	"Glox/ast"
 	"Glox/token"
 	"fmt"
-	"strconv"
+	"strings"
 )
 
 type Parser struct {
-	tokens  []token.Token
-	current int
-	errors  []string
+	tokens    []token.Token
+	current   int
+	errors    []string
+	functions int // depth of the function bodies being parsed.
+	loops     int // depth of the loop bodies in the current function.
+	// interpolations holds the "${" of every interpolation being parsed,
+	// innermost last.
+	interpolations []token.Token
+	// guard is the index of the first token of the match guard being
+	// parsed, or -1 outside of guards.
+	guard int
 }
 
+// parseError unwinds the parser to the enclosing declaration, which then
+// synchronizes on the next statement.
+type parseError struct{}
+
 func NewParser(tokens []token.Token) *Parser {
 	p := &Parser{
 		tokens:  tokens,
 		current: 0,
 		errors:  []string{},
+		guard:   -1,
 	}
 	return p
 }
@@ -29,17 +42,109 @@ func (p *Parser) Errors() []string {
 func (p *Parser) Parse() []ast.Statement {
 	statements := []ast.Statement{}
 	for !p.isAtEnd() {
-		statements = append(statements, p.declaration())
+		if stmt := p.topLevel(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 	return statements
 }
 
+// ParseExpression parses tokens that hold a single expression, such as a
+// condition typed in the debugger. It returns nil when there are errors.
+func (p *Parser) ParseExpression() (expr ast.Expression) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(parseError); !ok {
+				panic(r)
+			}
+			expr = nil
+		}
+	}()
+	expr = p.expression()
+	if !p.isAtEnd() {
+		panic(p.error(p.peek(), "Expect end of expression."))
+	}
+	return expr
+}
+
+// topLevel parses the declarations that are only allowed at the top level
+// of a file, falling back to any other declaration.
+func (p *Parser) topLevel() (stmt ast.Statement) {
+	defer p.recover(&stmt)
+	if p.match(token.IMPORT) {
+		return p.importDeclaration()
+	}
+	if p.match(token.FROM) {
+		return p.fromDeclaration()
+	}
+	if p.match(token.EXPORT) {
+		return p.exportDeclaration()
+	}
+	return p.declaration()
+}
+
+func (p *Parser) importDeclaration() ast.Statement {
+	keyword := p.previous()
+	path := p.consume(token.STRING, "Expect module path after 'import'.")
+	p.consume(token.AS, "Expect 'as' after module path.")
+	alias := p.consume(token.IDENTIFIER, "Expect module name after 'as'.")
+	p.consume(token.SEMICOLON, "Expect ';' after import.")
+	return &ast.ImportStmt{Keyword: keyword, Path: path, Alias: alias}
+}
+
+func (p *Parser) fromDeclaration() ast.Statement {
+	keyword := p.previous()
+	path := p.consume(token.STRING, "Expect module path after 'from'.")
+	p.consume(token.IMPORT, "Expect 'import' after module path.")
+	names := []token.Token{}
+	for {
+		names = append(names, p.consume(token.IDENTIFIER, "Expect name to import."))
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	p.consume(token.SEMICOLON, "Expect ';' after import.")
+	return &ast.ImportStmt{Keyword: keyword, Path: path, Names: names}
+}
+
+func (p *Parser) exportDeclaration() ast.Statement {
+	keyword := p.previous()
+	if !p.check(token.FUN) && !p.check(token.VAR) && !p.check(token.CONST) && !p.check(token.CLASS) {
+		p.error(p.peek(), "Expect declaration after 'export'.")
+	}
+	return &ast.ExportStmt{Keyword: keyword, Declaration: p.declaration()}
+}
+
 func (p *Parser) statement() ast.Statement {
+	if p.match(token.FOR) {
+		return p.forStatement()
+	}
+	if p.match(token.IF) {
+		return p.ifStatement()
+	}
+	if p.match(token.MATCH) {
+		return p.matchStatement()
+	}
 	if p.match(token.PRINT) {
 		return p.printStatement()
 	}
+	if p.match(token.RETURN) {
+		return p.returnStatement()
+	}
+	if p.match(token.THROW) {
+		return p.throwStatement()
+	}
+	if p.match(token.TRY) {
+		return p.tryStatement()
+	}
+	if p.match(token.WHILE) {
+		return p.whileStatement()
+	}
+	if p.match(token.BREAK, token.CONTINUE) {
+		return p.loopJump()
+	}
 	if p.match(token.LEFT_BRACE) {
-		return &ast.BlockStmt{Statements: p.Block()}
+		return &ast.BlockStmt{Brace: p.previous(), Statements: p.Block()}
 	}
 	return p.expressionStatement()
 }
@@ -47,27 +152,447 @@ func (p *Parser) statement() ast.Statement {
 func (p *Parser) Block() []ast.Statement {
 	statements := []ast.Statement{}
 	for !p.isAtEnd() && !p.check(token.RIGHT_BRACE) {
-		statements = append(statements, p.declaration())
+		if stmt := p.declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 	p.consume(token.RIGHT_BRACE, "Expect '}' after block.")
 	return statements
 }
 
+// forStatement desugars a for loop into a while loop that carries the
+// increment clause, wrapped in a block when there is an initializer.
+func (p *Parser) forStatement() ast.Statement {
+	p.consume(token.LEFT_PAREN, "Expect '(' after 'for'.")
+	var initializer ast.Statement
+	if p.match(token.SEMICOLON) {
+		initializer = nil
+	} else if p.match(token.VAR) {
+		initializer = p.varDeclaration()
+	} else {
+		initializer = p.expressionStatement()
+	}
+
+	var condition ast.Expression
+	if !p.check(token.SEMICOLON) {
+		condition = p.expression()
+	}
+	p.consume(token.SEMICOLON, "Expect ';' after loop condition.")
+
+	var increment ast.Expression
+	if !p.check(token.RIGHT_PAREN) {
+		increment = p.expression()
+	}
+	p.consume(token.RIGHT_PAREN, "Expect ')' after for clauses.")
+
+	body := p.loopBody()
+	if condition == nil {
+		condition = &ast.Literal{Value: true}
+	}
+	var loop ast.Statement = &ast.WhileStmt{Condition: condition, Body: body, Increment: increment}
+	if initializer != nil {
+		loop = &ast.BlockStmt{Statements: []ast.Statement{initializer, loop}}
+	}
+	return loop
+}
+
+func (p *Parser) whileStatement() ast.Statement {
+	p.consume(token.LEFT_PAREN, "Expect '(' after 'while'.")
+	condition := p.expression()
+	p.consume(token.RIGHT_PAREN, "Expect ')' after condition.")
+	return &ast.WhileStmt{Condition: condition, Body: p.loopBody()}
+}
+
+func (p *Parser) loopBody() ast.Statement {
+	p.loops += 1
+	defer func() {
+		p.loops -= 1
+	}()
+	return p.statement()
+}
+
+// loopJump parses break and continue, which are only valid inside a loop
+// of the current function.
+func (p *Parser) loopJump() ast.Statement {
+	keyword := p.previous()
+	if p.loops == 0 {
+		p.error(keyword, fmt.Sprintf("Can't use '%s' outside of a loop.", keyword.Lexeme))
+	}
+	p.consume(token.SEMICOLON, fmt.Sprintf("Expect ';' after '%s'.", keyword.Lexeme))
+	if keyword.Type == token.BREAK {
+		return &ast.BreakStmt{Keyword: keyword}
+	}
+	return &ast.ContinueStmt{Keyword: keyword}
+}
+
+func (p *Parser) ifStatement() ast.Statement {
+	p.consume(token.LEFT_PAREN, "Expect '(' after 'if'.")
+	condition := p.expression()
+	p.consume(token.RIGHT_PAREN, "Expect ')' after if condition.")
+
+	thenBranch := p.statement()
+	var elseBranch ast.Statement
+	if p.match(token.ELSE) {
+		elseBranch = p.statement()
+	}
+	return &ast.IfStmt{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
+}
+
 func (p *Parser) printStatement() ast.Statement {
 	value := p.expression()
 	p.consume(token.SEMICOLON, "Expect ';' after value.")
 	return &ast.PrintStmt{Expression: value}
 }
 
+func (p *Parser) returnStatement() ast.Statement {
+	keyword := p.previous()
+	if p.functions == 0 {
+		p.error(keyword, "Can't return from top-level code.")
+	}
+	var value ast.Expression
+	if !p.check(token.SEMICOLON) {
+		value = p.expression()
+	}
+	p.consume(token.SEMICOLON, "Expect ';' after return value.")
+	return &ast.ReturnStmt{Keyword: keyword, Value: value}
+}
+
+func (p *Parser) throwStatement() ast.Statement {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(token.SEMICOLON, "Expect ';' after thrown value.")
+	return &ast.ThrowStmt{Keyword: keyword, Value: value}
+}
+
+func (p *Parser) tryStatement() ast.Statement {
+	stmt := &ast.TryStmt{Keyword: p.previous()}
+	p.consume(token.LEFT_BRACE, "Expect '{' after 'try'.")
+	stmt.Body = p.Block()
+	if p.match(token.CATCH) {
+		p.consume(token.LEFT_PAREN, "Expect '(' after 'catch'.")
+		stmt.Param = p.consume(token.IDENTIFIER, "Expect variable name in catch clause.")
+		p.consume(token.RIGHT_PAREN, "Expect ')' after catch variable.")
+		brace := p.consume(token.LEFT_BRACE, "Expect '{' before catch body.")
+		stmt.Catch = &ast.BlockStmt{Brace: brace, Statements: p.Block()}
+	}
+	if p.match(token.FINALLY) {
+		brace := p.consume(token.LEFT_BRACE, "Expect '{' after 'finally'.")
+		stmt.Finally = &ast.BlockStmt{Brace: brace, Statements: p.Block()}
+	}
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.error(p.peek(), "Expect 'catch' or 'finally' after try block.")
+	}
+	return stmt
+}
+
+// matchStatement parses match (subject) { case ... => body ... } with an
+// optional default arm, which must come last.
+func (p *Parser) matchStatement() ast.Statement {
+	stmt := &ast.MatchStmt{Keyword: p.previous()}
+	p.consume(token.LEFT_PAREN, "Expect '(' after 'match'.")
+	stmt.Subject = p.expression()
+	p.consume(token.RIGHT_PAREN, "Expect ')' after match subject.")
+	p.consume(token.LEFT_BRACE, "Expect '{' before match cases.")
+	for p.match(token.CASE) {
+		c := &ast.MatchCase{Keyword: p.previous()}
+		for {
+			c.Patterns = append(c.Patterns, p.pattern())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+		if p.match(token.IF) {
+			c.Guard = p.guardExpression()
+		}
+		p.consume(token.ARROW, "Expect '=>' after case pattern.")
+		c.Body = p.statement()
+		stmt.Cases = append(stmt.Cases, c)
+	}
+	if p.match(token.DEFAULT) {
+		p.consume(token.ARROW, "Expect '=>' after 'default'.")
+		stmt.Default = p.statement()
+	}
+	if p.check(token.CASE) || p.check(token.DEFAULT) {
+		panic(p.error(p.peek(), "The default case must come last."))
+	}
+	p.consume(token.RIGHT_BRACE, "Expect '}' after match cases.")
+	return stmt
+}
+
+// guardExpression parses the guard of a match case. In case x if (y) => ...
+// the parenthesized y is not the parameter list of an arrow function.
+func (p *Parser) guardExpression() ast.Expression {
+	guard := p.guard
+	p.guard = p.current
+	defer func() {
+		p.guard = guard
+	}()
+	return p.expression()
+}
+
+// inGuard reports whether the current token is at the top level of a match
+// guard, outside of any parentheses, brackets or braces opened in it.
+func (p *Parser) inGuard() bool {
+	if p.guard < 0 {
+		return false
+	}
+	depth := 0
+	for _, tok := range p.tokens[p.guard:p.current] {
+		switch tok.Type {
+		case token.LEFT_PAREN, token.LEFT_BRACKET, token.LEFT_BRACE:
+			depth++
+		case token.RIGHT_PAREN, token.RIGHT_BRACKET, token.RIGHT_BRACE:
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// pattern parses a literal, list, instance or binding pattern.
+func (p *Parser) pattern() ast.Pattern {
+	if p.match(token.FALSE, token.TRUE, token.NIL, token.NUMBER, token.STRING) {
+		return &ast.LiteralPattern{Value: p.literal(p.previous())}
+	}
+	if p.match(token.MINUS) {
+		operator := p.previous()
+		number := p.consume(token.NUMBER, "Expect number after '-' in pattern.")
+		return &ast.LiteralPattern{Value: &ast.Unary{Operator: operator, Right: p.literal(number)}}
+	}
+	if p.match(token.LEFT_BRACKET) {
+		pattern := &ast.ListPattern{Bracket: p.previous(), Elements: []ast.Pattern{}}
+		if !p.check(token.RIGHT_BRACKET) {
+			for {
+				pattern.Elements = append(pattern.Elements, p.pattern())
+				if !p.match(token.COMMA) {
+					break
+				}
+			}
+		}
+		p.consume(token.RIGHT_BRACKET, "Expect ']' after list pattern.")
+		return pattern
+	}
+	name := p.consume(token.IDENTIFIER, "Expect pattern.")
+	if !p.match(token.LEFT_BRACE) {
+		return &ast.BindingPattern{Name: name}
+	}
+	pattern := &ast.InstancePattern{Class: &ast.Variable{Name: name}}
+	if !p.check(token.RIGHT_BRACE) {
+		for {
+			field := p.consume(token.IDENTIFIER, "Expect field name in instance pattern.")
+			var sub ast.Pattern = &ast.BindingPattern{Name: field}
+			if p.match(token.COLON) {
+				sub = p.pattern()
+			}
+			pattern.Fields = append(pattern.Fields, field)
+			pattern.Patterns = append(pattern.Patterns, sub)
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(token.RIGHT_BRACE, "Expect '}' after instance pattern.")
+	return pattern
+}
+
+func (p *Parser) function(kind string) ast.Statement {
+	name := p.consume(token.IDENTIFIER, fmt.Sprintf("Expect %s name.", kind))
+	p.consume(token.LEFT_PAREN, fmt.Sprintf("Expect '(' after %s name.", kind))
+	parameters, types := p.parameters()
+	returnType := p.optionalType()
+	p.consume(token.LEFT_BRACE, fmt.Sprintf("Expect '{' before %s body.", kind))
+	return &ast.FunStmt{Name: name, Params: parameters, ParamTypes: types, ReturnType: returnType, Body: p.functionBody()}
+}
+
+// member parses a class member: a method, a static method, a getter
+// declared without a parameter list, a set accessor, or a field
+// declaration. static and set are only special here, so they remain usable
+// as ordinary names. Fields are returned as an *ast.Field.
+func (p *Parser) member() interface{} {
+	kind := ast.Method
+	if p.check(token.IDENTIFIER) && p.checkNext(token.IDENTIFIER) {
+		switch p.peek().Lexeme {
+		case "static":
+			kind = ast.StaticMethod
+		case "set":
+			kind = ast.Setter
+		default:
+			panic(p.error(p.peek(), "Expect '(' or '{' after method name."))
+		}
+		p.advance()
+	}
+	name := p.consume(token.IDENTIFIER, "Expect method name.")
+	if kind == ast.Method && !p.check(token.LEFT_PAREN) {
+		returnType := p.optionalType()
+		if returnType != nil && p.match(token.SEMICOLON) {
+			return &ast.Field{Name: name, Type: returnType}
+		}
+		p.consume(token.LEFT_BRACE, "Expect '(' or '{' after method name.")
+		if name.Lexeme == "init" {
+			p.error(name, "An initializer can't be a getter.")
+		}
+		return &ast.FunStmt{Name: name, ReturnType: returnType, Body: p.functionBody(), Kind: ast.Getter}
+	}
+	p.consume(token.LEFT_PAREN, "Expect '(' after method name.")
+	parameters, types := p.parameters()
+	if kind == ast.Setter && len(parameters) != 1 {
+		p.error(name, "A setter must take exactly one parameter.")
+	}
+	returnType := p.optionalType()
+	p.consume(token.LEFT_BRACE, "Expect '{' before method body.")
+	return &ast.FunStmt{Name: name, Params: parameters, ParamTypes: types, ReturnType: returnType, Body: p.functionBody(), Kind: kind}
+}
+
+// parameters parses a parameter list up to and including the closing ')'.
+// types holds each parameter's annotation, or nil where there is none.
+func (p *Parser) parameters() (parameters []token.Token, types []*ast.TypeAnnotation) {
+	parameters = []token.Token{}
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(parameters) >= 255 {
+				p.error(p.peek(), "Can't have more than 255 parameters.")
+			}
+			parameters = append(parameters, p.consume(token.IDENTIFIER, "Expect parameter name."))
+			types = append(types, p.optionalType())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(token.RIGHT_PAREN, "Expect ')' after parameters.")
+	return parameters, types
+}
+
+// optionalType parses ': Type' if the next token is a colon.
+func (p *Parser) optionalType() *ast.TypeAnnotation {
+	if !p.match(token.COLON) {
+		return nil
+	}
+	return p.typeAnnotation()
+}
+
+// typeAnnotation parses a type name with optional arguments, List<number>.
+func (p *Parser) typeAnnotation() *ast.TypeAnnotation {
+	var name token.Token
+	if p.match(token.NIL) {
+		name = p.previous()
+	} else {
+		name = p.consume(token.IDENTIFIER, "Expect type name.")
+	}
+	annotation := &ast.TypeAnnotation{Name: name}
+	if p.match(token.LESS) {
+		for {
+			annotation.Arguments = append(annotation.Arguments, p.typeAnnotation())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+		p.consume(token.GREATER, "Expect '>' after type arguments.")
+	}
+	return annotation
+}
+
+// lambda parses fun (params) { body } once 'fun' has been consumed.
+func (p *Parser) lambda() ast.Expression {
+	keyword := p.previous()
+	p.consume(token.LEFT_PAREN, "Expect '(' after 'fun'.")
+	parameters, types := p.parameters()
+	returnType := p.optionalType()
+	p.consume(token.LEFT_BRACE, "Expect '{' before function body.")
+	return &ast.Lambda{Keyword: keyword, Params: parameters, ParamTypes: types, ReturnType: returnType, Body: p.functionBody()}
+}
+
+// arrowFunction parses (params) => expression. The body becomes a single
+// return statement.
+func (p *Parser) arrowFunction() ast.Expression {
+	keyword := p.consume(token.LEFT_PAREN, "Expect '(' before parameters.")
+	parameters, types := p.parameters()
+	returnType := p.optionalType()
+	arrow := p.consume(token.ARROW, "Expect '=>' after parameters.")
+	functions, loops := p.functions, p.loops
+	p.functions, p.loops = functions+1, 0
+	body := p.assignment()
+	p.functions, p.loops = functions, loops
+	return &ast.Lambda{
+		Keyword:    keyword,
+		Params:     parameters,
+		ParamTypes: types,
+		ReturnType: returnType,
+		Body:       []ast.Statement{&ast.ReturnStmt{Keyword: arrow, Value: body}},
+		Arrow:      true,
+	}
+}
+
+// isArrow reports whether the '(' at the current token opens the parameter
+// list of an arrow function rather than a grouping. Parameters and the
+// return type may carry annotations, so it skips over anything that can
+// appear in one.
+func (p *Parser) isArrow() bool {
+	n := p.current + 1
+	for p.tokens[n].Type != token.RIGHT_PAREN {
+		if !isTypeToken(p.tokens[n].Type) {
+			return false
+		}
+		n += 1
+	}
+	n += 1
+	if p.tokens[n].Type == token.COLON {
+		n += 1
+		for isTypeToken(p.tokens[n].Type) {
+			n += 1
+		}
+	}
+	return p.tokens[n].Type == token.ARROW
+}
+
+func isTypeToken(t token.TokenType) bool {
+	switch t {
+	case token.IDENTIFIER, token.NIL, token.COMMA, token.COLON, token.LESS, token.GREATER:
+		return true
+	}
+	return false
+}
+
+// nameLambda names an anonymous function after the variable or property it
+// is assigned to, for stack traces.
+func nameLambda(value ast.Expression, name token.Token) {
+	if lambda, ok := value.(*ast.Lambda); ok && lambda.Name.Lexeme == "" {
+		lambda.Name = name
+	}
+}
+
+// functionBody parses the statements of a function. Loops around the
+// function don't extend into its body.
+func (p *Parser) functionBody() []ast.Statement {
+	functions, loops := p.functions, p.loops
+	p.functions, p.loops = functions+1, 0
+	defer func() {
+		p.functions, p.loops = functions, loops
+	}()
+	return p.Block()
+}
+
 func (p *Parser) varDeclaration() ast.Statement {
 	name := p.consume(token.IDENTIFIER, "Expect variable name.")
+	annotation := p.optionalType()
 	var initializer ast.Expression
 	if p.match(token.EQUAL) {
-		initializer = p.expression()
+		initializer = p.assignment()
+		nameLambda(initializer, name)
 	}
 	p.consume(token.SEMICOLON, "Expect ';' after variable declaration.")
 
-	return &ast.VarStmt{Initializer: initializer, Name: name}
+	return &ast.VarStmt{Initializer: initializer, Name: name, Type: annotation}
+}
+
+func (p *Parser) constDeclaration() ast.Statement {
+	name := p.consume(token.IDENTIFIER, "Expect constant name.")
+	annotation := p.optionalType()
+	p.consume(token.EQUAL, "Expect '=' after constant name; a constant needs an initializer.")
+	initializer := p.assignment()
+	nameLambda(initializer, name)
+	p.consume(token.SEMICOLON, "Expect ';' after constant declaration.")
+
+	return &ast.VarStmt{Initializer: initializer, Name: name, Type: annotation, Const: true}
 }
 
 func (p *Parser) expressionStatement() ast.Statement {
@@ -77,31 +602,141 @@ func (p *Parser) expressionStatement() ast.Statement {
 }
 
 func (p *Parser) expression() ast.Expression {
-	return p.assignment()
+	return p.comma()
+}
+
+// comma parses the comma operator. Where commas separate items, such as
+// arguments or list elements, the items are parsed with assignment instead.
+func (p *Parser) comma() ast.Expression {
+	expr := p.assignment()
+	for p.match(token.COMMA) {
+		operator := p.previous()
+		right := p.assignment()
+		expr = &ast.Comma{Left: expr, Operator: operator, Right: right}
+	}
+	return expr
 }
 
 func (p *Parser) assignment() ast.Expression {
-	expr := p.equality()
+	expr := p.conditional()
 	if p.match(token.EQUAL) {
 		equals := p.previous()
 		value := p.assignment()
 
 		if e, ok := expr.(*ast.Variable); ok {
 			name := e.Name
+			nameLambda(value, name)
 			return &ast.Assign{Name: name, Value: value}
 		}
-		p.errors = append(p.errors, fmt.Sprintf("%v invalid assignment target.", equals))
+		if e, ok := expr.(*ast.Get); ok {
+			nameLambda(value, e.Name)
+			return &ast.Set{Object: e.Object, Name: e.Name, Value: value}
+		}
+		if e, ok := expr.(*ast.Index); ok {
+			return &ast.SetIndex{Object: e.Object, Bracket: e.Bracket, Index: e.Index, Value: value}
+		}
+		p.error(equals, "Invalid assignment target.")
+	}
+	if p.match(token.PLUS_EQUAL, token.MINUS_EQUAL, token.STAR_EQUAL, token.SLASH_EQUAL) {
+		operator := p.previous()
+		value := p.assignment()
+		if !isAssignable(expr) {
+			p.error(operator, "Invalid assignment target.")
+		}
+		return &ast.CompoundAssign{Target: expr, Operator: operator, Value: value}
 	}
 	return expr
 }
 
-func (p *Parser) declaration() ast.Statement {
+// isAssignable reports whether expr may be the target of a compound
+// assignment or an increment.
+func isAssignable(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.Variable, *ast.Get, *ast.Index:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) declaration() (stmt ast.Statement) {
+	defer p.recover(&stmt)
+	if p.check(token.IMPORT) || p.check(token.FROM) || p.check(token.EXPORT) {
+		p.error(p.peek(), fmt.Sprintf("'%s' is only allowed at the top level of a file.", p.peek().Lexeme))
+		return p.topLevel()
+	}
+	if p.match(token.CLASS) {
+		return p.classDeclaration()
+	}
+	if p.check(token.FUN) && p.checkNext(token.IDENTIFIER) {
+		p.advance()
+		return p.function("function")
+	}
 	if p.match(token.VAR) {
 		return p.varDeclaration()
 	}
+	if p.match(token.CONST) {
+		return p.constDeclaration()
+	}
 	return p.statement()
 }
 
+func (p *Parser) classDeclaration() ast.Statement {
+	name := p.consume(token.IDENTIFIER, "Expect class name.")
+	var superclass *ast.Variable
+	if p.match(token.LESS) {
+		p.consume(token.IDENTIFIER, "Expect superclass name.")
+		superclass = &ast.Variable{Name: p.previous()}
+	}
+	p.consume(token.LEFT_BRACE, "Expect '{' before class body.")
+	stmt := &ast.ClassStmt{Name: name, Superclass: superclass, Methods: []ast.Statement{}}
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		switch member := p.member().(type) {
+		case *ast.Field:
+			stmt.Fields = append(stmt.Fields, member)
+		case *ast.FunStmt:
+			stmt.Methods = append(stmt.Methods, member)
+		}
+	}
+	p.consume(token.RIGHT_BRACE, "Expect '}' after class body.")
+	return stmt
+}
+
+// conditional parses the right-associative cond ? then : else.
+func (p *Parser) conditional() ast.Expression {
+	expr := p.or()
+	if p.match(token.QUESTION) {
+		question := p.previous()
+		thenBranch := p.expression()
+		if !p.check(token.COLON) {
+			panic(p.error(p.peek(), fmt.Sprintf("Expect ':' after then branch of conditional expression started at Ln %d, Col %d.", question.Line, question.Col)))
+		}
+		p.advance()
+		elseBranch := p.conditional()
+		return &ast.Conditional{Condition: expr, Question: question, Then: thenBranch, Else: elseBranch}
+	}
+	return expr
+}
+
+func (p *Parser) or() ast.Expression {
+	expr := p.and()
+	for p.match(token.OR) {
+		operator := p.previous()
+		right := p.and()
+		expr = &ast.Logical{Left: expr, Operator: operator, Right: right}
+	}
+	return expr
+}
+
+func (p *Parser) and() ast.Expression {
+	expr := p.equality()
+	for p.match(token.AND) {
+		operator := p.previous()
+		right := p.equality()
+		expr = &ast.Logical{Left: expr, Operator: operator, Right: right}
+	}
+	return expr
+}
+
 func (p *Parser) equality() ast.Expression {
 	expr := p.comparison()
 
@@ -138,7 +773,7 @@ func (p *Parser) term() ast.Expression {
 func (p *Parser) factor() ast.Expression {
 	expr := p.unary()
 
-	for p.match(token.STAR, token.SLASH) {
+	for p.match(token.STAR, token.SLASH, token.BACKSLASH, token.PERCENT) {
 		operator := p.previous()
 		right := p.unary()
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
@@ -153,30 +788,126 @@ func (p *Parser) unary() ast.Expression {
 		right := p.unary()
 		return &ast.Unary{Operator: operator, Right: right}
 	}
-	return p.primary()
+	if p.match(token.PLUS_PLUS, token.MINUS_MINUS) {
+		operator := p.previous()
+		target := p.unary()
+		if !isAssignable(target) {
+			p.error(operator, fmt.Sprintf("Invalid operand for '%s'.", operator.Lexeme))
+		}
+		return &ast.Increment{Target: target, Operator: operator, Prefix: true}
+	}
+	return p.postfix()
 }
 
-func (p *Parser) primary() ast.Expression {
-	if p.match(token.FALSE) {
-		return &ast.Literal{Value: false}
+func (p *Parser) postfix() ast.Expression {
+	expr := p.call()
+	if p.match(token.PLUS_PLUS, token.MINUS_MINUS) {
+		operator := p.previous()
+		if !isAssignable(expr) {
+			p.error(operator, fmt.Sprintf("Invalid operand for '%s'.", operator.Lexeme))
+		}
+		return &ast.Increment{Target: expr, Operator: operator, Prefix: false}
 	}
-	if p.match(token.TRUE) {
-		return &ast.Literal{Value: true}
+	return expr
+}
+
+func (p *Parser) call() ast.Expression {
+	expr := p.primary()
+	for {
+		if p.match(token.LEFT_PAREN) {
+			expr = p.finishCall(expr)
+		} else if p.match(token.LEFT_BRACKET) {
+			expr = p.finishIndex(expr)
+		} else if p.match(token.DOT) {
+			name := p.consume(token.IDENTIFIER, "Expect property name after '.'.")
+			expr = &ast.Get{Object: expr, Name: name}
+		} else {
+			break
+		}
 	}
-	if p.match(token.NIL) {
-		return &ast.Literal{Value: nil}
-	}
-	if p.match(token.NUMBER, token.STRING) {
-		tok := p.previous()
-		if tok.Type == token.NUMBER {
-			str := tok.Lexeme
-			val, err := strconv.ParseFloat(str, 64)
-			if err != nil {
-				p.errors = append(p.errors, "could not parse token to float")
+	return expr
+}
+
+func (p *Parser) finishCall(callee ast.Expression) ast.Expression {
+	arguments := []ast.Expression{}
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(arguments) >= 255 {
+				p.error(p.peek(), "Can't have more than 255 arguments.")
 			}
-			return &ast.Literal{Value: val}
+			arguments = append(arguments, p.assignment())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	paren := p.consume(token.RIGHT_PAREN, "Expect ')' after arguments.")
+	return &ast.Call{Callee: callee, Paren: paren, Arguments: arguments}
+}
+
+// finishIndex parses the rest of object[index] or a slice object[start:end]
+// where both bounds are optional.
+func (p *Parser) finishIndex(object ast.Expression) ast.Expression {
+	bracket := p.previous()
+	var start ast.Expression
+	if !p.check(token.COLON) {
+		start = p.expression()
+	}
+	if p.match(token.COLON) {
+		var end ast.Expression
+		if !p.check(token.RIGHT_BRACKET) {
+			end = p.expression()
 		}
-		return &ast.Literal{Value: tok.Lexeme}
+		p.consume(token.RIGHT_BRACKET, "Expect ']' after slice.")
+		return &ast.Slice{Object: object, Bracket: bracket, Start: start, End: end}
+	}
+	p.consume(token.RIGHT_BRACKET, "Expect ']' after index.")
+	return &ast.Index{Object: object, Bracket: bracket, Index: start}
+}
+
+func (p *Parser) literal(tok token.Token) ast.Expression {
+	switch tok.Type {
+	case token.FALSE:
+		return &ast.Literal{Token: tok, Value: false}
+	case token.TRUE:
+		return &ast.Literal{Token: tok, Value: true}
+	case token.NIL:
+		return &ast.Literal{Token: tok, Value: nil}
+	}
+	return &ast.Literal{Token: tok, Value: tok.Literal}
+}
+
+func (p *Parser) primary() ast.Expression {
+	if isResumption(p.peek()) {
+		// The "}" closing an interpolation is not a string literal.
+		panic(p.error(p.peek(), "Expect expression."))
+	}
+	if p.match(token.FALSE, token.TRUE, token.NIL, token.NUMBER, token.STRING) {
+		return p.literal(p.previous())
+	}
+	if p.match(token.SUPER) {
+		keyword := p.previous()
+		p.consume(token.DOT, "Expect '.' after 'super'.")
+		method := p.consume(token.IDENTIFIER, "Expect superclass method name.")
+		return &ast.Super{Keyword: keyword, Method: method}
+	}
+	if p.match(token.THIS) {
+		return &ast.This{Keyword: p.previous()}
+	}
+	if p.match(token.FUN) {
+		return p.lambda()
+	}
+	if p.check(token.LEFT_PAREN) && !p.inGuard() && p.isArrow() {
+		return p.arrowFunction()
+	}
+	if p.match(token.INTERPOLATION) {
+		return p.interpolation()
+	}
+	if p.match(token.LEFT_BRACKET) {
+		return p.list()
+	}
+	if p.match(token.LEFT_BRACE) {
+		return p.mapLiteral()
 	}
 	if p.match(token.IDENTIFIER) {
 		return &ast.Variable{Name: p.previous()}
@@ -186,17 +917,114 @@ func (p *Parser) primary() ast.Expression {
 		p.consume(token.RIGHT_PAREN, "Expect ')' after expression.")
 		return &ast.Grouping{Expression: expr}
 	}
-	p.errors = append(p.errors, "Expect expression.")
-	return nil
+	panic(p.error(p.peek(), "Expect expression."))
+}
+
+// list parses a list literal. A trailing comma is allowed.
+func (p *Parser) list() ast.Expression {
+	bracket := p.previous()
+	elements := []ast.Expression{}
+	for !p.check(token.RIGHT_BRACKET) && !p.isAtEnd() {
+		elements = append(elements, p.assignment())
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements.")
+	return &ast.List{Bracket: bracket, Elements: elements}
+}
+
+// mapLiteral parses a map literal. Braces only start a map in expression
+// position; a statement that begins with '{' is a block. A trailing comma is
+// allowed.
+func (p *Parser) mapLiteral() ast.Expression {
+	brace := p.previous()
+	keys := []ast.Expression{}
+	values := []ast.Expression{}
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		keys = append(keys, p.assignment())
+		p.consume(token.COLON, "Expect ':' after map key.")
+		values = append(values, p.assignment())
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	p.consume(token.RIGHT_BRACE, "Expect '}' after map entries.")
+	return &ast.Map{Brace: brace, Keys: keys, Values: values}
+}
+
+// interpolation parses an interpolated string. The scanner emits one
+// INTERPOLATION token for every segment that ends in "${" and closes the
+// string with a plain STRING token.
+func (p *Parser) interpolation() ast.Expression {
+	parts := []ast.Expression{}
+	segment := p.previous()
+	for {
+		if text, _ := segment.Literal.(string); text != "" {
+			parts = append(parts, &ast.Literal{Token: segment, Value: text})
+		}
+		if segment.Type != token.INTERPOLATION {
+			break
+		}
+		p.interpolations = append(p.interpolations, segment)
+		parts = append(parts, p.interpolated())
+		if p.match(token.INTERPOLATION) {
+			segment = p.previous()
+		} else {
+			segment = p.consume(token.STRING, "Expect '}' after interpolated expression.")
+		}
+	}
+	return &ast.Interpolation{Parts: parts}
+}
+
+// interpolated parses the expression embedded after the last "${" in
+// p.interpolations and pops it, also when the expression is invalid.
+func (p *Parser) interpolated() ast.Expression {
+	defer func() {
+		p.interpolations = p.interpolations[:len(p.interpolations)-1]
+	}()
+	return p.expression()
+}
+
+// isResumption reports whether tok is a string segment that resumes after
+// the "}" of an interpolation. Its position is the end of the segment.
+func isResumption(tok token.Token) bool {
+	return (tok.Type == token.STRING || tok.Type == token.INTERPOLATION) && strings.HasPrefix(tok.Lexeme, "}")
 }
 
 func (p *Parser) consume(t token.TokenType, msg string) token.Token {
 	if p.check(t) {
 		return p.advance()
 	}
-	p.errors = append(p.errors, msg)
+	panic(p.error(p.peek(), msg))
+}
 
-	return token.Token{}
+// error records a parse error. Callers that can't continue panic with the
+// returned value to unwind to the enclosing declaration.
+func (p *Parser) error(tok token.Token, msg string) parseError {
+	if n := len(p.interpolations); n > 0 && isResumption(tok) {
+		// The segment ends further in the string, report the error at the
+		// "${" of the interpolation instead.
+		tok = p.interpolations[n-1]
+	}
+	if tok.Type == token.EOF {
+		p.errors = append(p.errors, fmt.Sprintf("at end: %s", msg))
+	} else {
+		p.errors = append(p.errors, fmt.Sprintf("Ln %d, Col %d %s", tok.Line, tok.Col, msg))
+	}
+	return parseError{}
+}
+
+// recover is deferred by the declaration rules. It stops a parseError and
+// skips to the start of the next statement.
+func (p *Parser) recover(stmt *ast.Statement) {
+	if r := recover(); r != nil {
+		if _, ok := r.(parseError); !ok {
+			panic(r)
+		}
+		p.synchronize()
+		*stmt = nil
+	}
 }
 
 func (p *Parser) match(types ...token.TokenType) bool {
@@ -216,6 +1044,13 @@ func (p *Parser) check(t token.TokenType) bool {
 	return p.peek().Type == t
 }
 
+func (p *Parser) checkNext(t token.TokenType) bool {
+	if p.isAtEnd() || p.tokens[p.current+1].Type == token.EOF {
+		return false
+	}
+	return p.tokens[p.current+1].Type == t
+}
+
 func (p *Parser) advance() token.Token {
 	if !p.isAtEnd() {
 		p.current += 1
@@ -244,14 +1079,8 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
-		case token.CLASS:
-		case token.FUN:
-		case token.VAR:
-		case token.FOR:
-		case token.IF:
-		case token.WHILE:
-		case token.PRINT:
-		case token.RETURN:
+		case token.CLASS, token.CONST, token.FUN, token.VAR, token.FOR, token.IF, token.MATCH, token.WHILE, token.PRINT, token.RETURN,
+			token.BREAK, token.CONTINUE, token.THROW, token.TRY, token.IMPORT, token.FROM, token.EXPORT:
 			return
 		}
 		p.advance()
@@ -0,0 +1,495 @@
+package parser
+
+import (
+	"Glox/ast"
+	"Glox/scanner"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parse scans and parses source, failing the test on scanner errors.
+func parse(t *testing.T, source string) ([]ast.Statement, []string) {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	if len(s.Errors()) > 0 {
+		t.Fatalf("scanning %q: %s", source, strings.Join(s.Errors(), "; "))
+	}
+	p := NewParser(tokens)
+	statements := p.Parse()
+	return statements, p.Errors()
+}
+
+func TestFor(t *testing.T) {
+	statements, errors := parse(t, "for (var i = 0; i < 3; i = i + 1) print i;")
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	// { var i = 0; while (i < 3) print i; } with i = i + 1 as the increment.
+	block, ok := statements[0].(*ast.BlockStmt)
+	if !ok || len(block.Statements) != 2 {
+		t.Fatalf("got %#v, want a block with the initializer and the loop", statements[0])
+	}
+	if _, ok := block.Statements[0].(*ast.VarStmt); !ok {
+		t.Errorf("got initializer %T, want *ast.VarStmt", block.Statements[0])
+	}
+	loop, ok := block.Statements[1].(*ast.WhileStmt)
+	if !ok {
+		t.Fatalf("got %T, want *ast.WhileStmt", block.Statements[1])
+	}
+	if _, ok := loop.Body.(*ast.PrintStmt); !ok || loop.Increment == nil {
+		t.Errorf("got body %#v and increment %#v, want the statement and the increment", loop.Body, loop.Increment)
+	}
+
+	// Without clauses the loop runs while true.
+	statements, _ = parse(t, "for (;;) print 1;")
+	loop, ok = statements[0].(*ast.WhileStmt)
+	if !ok {
+		t.Fatalf("got %T, want *ast.WhileStmt", statements[0])
+	}
+	if literal, ok := loop.Condition.(*ast.Literal); !ok || literal.Value != true {
+		t.Errorf("got condition %#v, want true", loop.Condition)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	statements, errors := parse(t, `
+print 1 +;
+var = 2;
+if (true print 3;
+{
+  print );
+  print 4;
+}
+print 5;
+`)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{2, "Expect expression."},
+		{3, "Expect variable name."},
+		{4, "Expect ')' after if condition."},
+		{6, "Expect expression."},
+	}
+	if len(errors) != len(want) {
+		t.Fatalf("got errors %q, want %d", errors, len(want))
+	}
+	for n, error := range errors {
+		if !strings.HasPrefix(error, fmt.Sprintf("Ln %d, ", want[n].line)) || !strings.HasSuffix(error, " "+want[n].message) {
+			t.Errorf("got %q, want %q on line %d", error, want[n].message, want[n].line)
+		}
+	}
+	// The block keeps the statement after the error, and parsing goes on
+	// after the block.
+	if len(statements) != 2 {
+		t.Fatalf("got %d statements, want the block and the last print", len(statements))
+	}
+	if block, ok := statements[0].(*ast.BlockStmt); !ok || len(block.Statements) != 1 {
+		t.Errorf("got %#v, want a block holding print 4", statements[0])
+	}
+}
+
+func TestReturn(t *testing.T) {
+	_, errors := parse(t, "fun f() { { return 1; } }\nreturn;\nfun g() { fun h() {} return; }")
+	if len(errors) != 1 || !strings.HasPrefix(errors[0], "Ln 2, ") || !strings.HasSuffix(errors[0], " Can't return from top-level code.") {
+		t.Errorf("got errors %q, want one for the return on line 2", errors)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	statements, errors := parse(t, "f(1, g(2))();")
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	outer, ok := statements[0].(*ast.ExpressionStmt).Expression.(*ast.Call)
+	if !ok || len(outer.Arguments) != 0 {
+		t.Fatalf("got %#v, want a call without arguments", statements[0])
+	}
+	inner, ok := outer.Callee.(*ast.Call)
+	if !ok || len(inner.Arguments) != 2 {
+		t.Fatalf("got callee %#v, want a call with two arguments", outer.Callee)
+	}
+	if _, ok := inner.Arguments[1].(*ast.Call); !ok {
+		t.Errorf("got argument %T, want *ast.Call", inner.Arguments[1])
+	}
+}
+
+func TestClasses(t *testing.T) {
+	statements, errors := parse(t, "class B < A { init(x) { this.x = x; } get() { return super.get(); } }")
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	class, ok := statements[0].(*ast.ClassStmt)
+	if !ok {
+		t.Fatalf("got %T, want *ast.ClassStmt", statements[0])
+	}
+	if class.Superclass == nil || class.Superclass.Name.Lexeme != "A" || len(class.Methods) != 2 {
+		t.Fatalf("got superclass %v and %d methods, want A and 2", class.Superclass, len(class.Methods))
+	}
+	init := class.Methods[0].(*ast.FunStmt)
+	if _, ok := init.Body[0].(*ast.ExpressionStmt).Expression.(*ast.Set); !ok {
+		t.Errorf("got %T in init, want *ast.Set", init.Body[0].(*ast.ExpressionStmt).Expression)
+	}
+	get := class.Methods[1].(*ast.FunStmt)
+	call := get.Body[0].(*ast.ReturnStmt).Value.(*ast.Call)
+	if super, ok := call.Callee.(*ast.Super); !ok || super.Method.Lexeme != "get" {
+		t.Errorf("got callee %#v, want super.get", call.Callee)
+	}
+
+	for source, want := range map[string]string{
+		"class A { var x; }": "Expect method name.",
+		"class A < { }":      "Expect superclass name.",
+		"print super;":       "Expect '.' after 'super'.",
+	} {
+		_, errors := parse(t, source)
+		if len(errors) == 0 || !strings.HasSuffix(errors[0], " "+want) {
+			t.Errorf("%s: got errors %q, want %q", source, errors, want)
+		}
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print "${ 1 + }";`, "Ln 1, Col 9 Expect expression."},
+		{`print "ab ${ 1 + } cd";`, "Ln 1, Col 12 Expect expression."},
+		{`print "${ (1 }";`, "Ln 1, Col 9 Expect ')' after expression."},
+		{`print "a${ "b${ 1 * }" }";`, "Ln 1, Col 15 Expect expression."},
+		{"print \"${ 1 +\n}\";", "Ln 1, Col 9 Expect expression."},
+		{`print "${ 1 2 }";`, "Ln 1, Col 13 Expect '}' after interpolated expression."},
+	}
+	for _, test := range tests {
+		_, errors := parse(t, test.source)
+		if len(errors) != 1 || errors[0] != test.want {
+			t.Errorf("%q: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	statements, errors := parse(t, `print "a${1 + 2}b${"c${x}"}";`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	expr := statements[0].(*ast.PrintStmt).Expression.(*ast.Interpolation)
+	if len(expr.Parts) != 4 {
+		t.Fatalf("got %d parts, want 4: %s", len(expr.Parts), expr)
+	}
+	if _, ok := expr.Parts[1].(*ast.Binary); !ok {
+		t.Errorf("part 1 is %T, want *ast.Binary", expr.Parts[1])
+	}
+	if _, ok := expr.Parts[3].(*ast.Interpolation); !ok {
+		t.Errorf("part 3 is %T, want a nested *ast.Interpolation", expr.Parts[3])
+	}
+}
+
+func TestMatchGuards(t *testing.T) {
+	statements, errors := parse(t, `
+match (x) {
+  case n if (ok) => print n;
+  case n if (n > 1) and (n < 5) => print n;
+  case n if any(xs, (x) => x > n) => print n;
+}
+`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	cases := statements[0].(*ast.MatchStmt).Cases
+	if _, ok := cases[0].Guard.(*ast.Grouping); !ok {
+		t.Errorf("case 1: got guard %T, want *ast.Grouping", cases[0].Guard)
+	}
+	if _, ok := cases[0].Body.(*ast.PrintStmt); !ok {
+		t.Errorf("case 1: got body %T, want *ast.PrintStmt", cases[0].Body)
+	}
+	if _, ok := cases[1].Guard.(*ast.Logical); !ok {
+		t.Errorf("case 2: got guard %T, want *ast.Logical", cases[1].Guard)
+	}
+	call, ok := cases[2].Guard.(*ast.Call)
+	if !ok {
+		t.Fatalf("case 3: got guard %T, want *ast.Call", cases[2].Guard)
+	}
+	// Arrow functions nested in the guard are still arrow functions.
+	if lambda, ok := call.Arguments[1].(*ast.Lambda); !ok || !lambda.Arrow {
+		t.Errorf("case 3: got argument %s, want an arrow function", call.Arguments[1])
+	}
+}
+
+func TestArrowFunctions(t *testing.T) {
+	statements, errors := parse(t, `var f = (a: number, b): number => a + b;`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	lambda, ok := statements[0].(*ast.VarStmt).Initializer.(*ast.Lambda)
+	if !ok || !lambda.Arrow || len(lambda.Params) != 2 {
+		t.Errorf("got %s, want an arrow function with two parameters", statements[0])
+	}
+}
+
+func TestLoopJumps(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{`while (true) break;`, ""},
+		{`for (;;) { if (x) continue; { break; } }`, ""},
+		{`while (a) while (b) break; `, ""},
+		{`while (a) match (x) { case 1 => break; default => continue; }`, ""},
+		{`break;`, "Ln 1, Col 5 Can't use 'break' outside of a loop."},
+		{`if (x) continue;`, "Ln 1, Col 15 Can't use 'continue' outside of a loop."},
+		{`while (x) { fun f() { break; } }`, "Ln 1, Col 27 Can't use 'break' outside of a loop."},
+		{`while (x) { var f = fun () { continue; }; }`, "Ln 1, Col 37 Can't use 'continue' outside of a loop."},
+		{`while (x) { class A { m() { break; } } }`, "Ln 1, Col 33 Can't use 'break' outside of a loop."},
+		{`for (var i = 0; i < 3; i++) {} break;`, "Ln 1, Col 36 Can't use 'break' outside of a loop."},
+		{`while (x) break`, "at end: Expect ';' after 'break'."},
+	}
+	for _, test := range tests {
+		_, errors := parse(t, test.source)
+		if test.want == "" && len(errors) > 0 || test.want != "" && (len(errors) == 0 || errors[0] != test.want) {
+			t.Errorf("%s: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestForContinue(t *testing.T) {
+	// The increment of a for loop runs after the body, continue or not, so
+	// the desugared while loop keeps it apart from the body.
+	statements, errors := parse(t, `for (var i = 0; i < 3; i++) continue;`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	block, ok := statements[0].(*ast.BlockStmt)
+	if !ok || len(block.Statements) != 2 {
+		t.Fatalf("got %s, want the initializer and the loop in a block", statements[0])
+	}
+	loop := block.Statements[1].(*ast.WhileStmt)
+	if _, ok := loop.Body.(*ast.ContinueStmt); !ok || loop.Increment == nil {
+		t.Errorf("got a loop with body %s and increment %v", loop.Body, loop.Increment)
+	}
+}
+
+// expression parses source as an expression statement.
+func expression(t *testing.T, source string) ast.Expression {
+	t.Helper()
+	statements, errors := parse(t, source+";")
+	if len(errors) > 0 {
+		t.Fatalf("%s: unexpected errors %q", source, errors)
+	}
+	return statements[0].(*ast.ExpressionStmt).Expression
+}
+
+func TestConditional(t *testing.T) {
+	// The conditional is right-associative.
+	expr := expression(t, "a ? b : c ? d : e").(*ast.Conditional)
+	if _, ok := expr.Else.(*ast.Conditional); !ok {
+		t.Errorf("got else branch %s, want a conditional", expr.Else)
+	}
+	// It binds more loosely than or and more tightly than assignment.
+	expr = expression(t, "a or b ? c : d").(*ast.Conditional)
+	if _, ok := expr.Condition.(*ast.Logical); !ok {
+		t.Errorf("got condition %s, want a or b", expr.Condition)
+	}
+	assign := expression(t, "x = a ? b : c").(*ast.Assign)
+	if _, ok := assign.Value.(*ast.Conditional); !ok {
+		t.Errorf("got value %s, want a conditional", assign.Value)
+	}
+	// Any expression fits between ? and :.
+	expr = expression(t, "a ? b = 1, c : d").(*ast.Conditional)
+	if _, ok := expr.Then.(*ast.Comma); !ok {
+		t.Errorf("got then branch %s, want a comma expression", expr.Then)
+	}
+}
+
+func TestComma(t *testing.T) {
+	// The comma is left-associative and binds more loosely than
+	// assignment.
+	comma := expression(t, "a = 1, b ? c : d, e").(*ast.Comma)
+	if left, ok := comma.Left.(*ast.Comma); !ok {
+		t.Errorf("got left %s, want a comma expression", comma.Left)
+	} else if _, ok := left.Left.(*ast.Assign); !ok {
+		t.Errorf("got %s, want an assignment first", left.Left)
+	}
+	// Where commas separate items, they don't make comma expressions.
+	if call := expression(t, "f(a, b)").(*ast.Call); len(call.Arguments) != 2 {
+		t.Errorf("got %d arguments, want 2", len(call.Arguments))
+	}
+	if list := expression(t, "[a, (b, c)]").(*ast.List); len(list.Elements) != 2 {
+		t.Errorf("got %d elements, want 2", len(list.Elements))
+	}
+}
+
+func TestBeautifyOperators(t *testing.T) {
+	for _, source := range []string{
+		"a ? b : c ? d : e",
+		"(a ? b : c) ? d : e",
+		"a ? (b, c) : d",
+		"(a, b) ? c : d",
+		"f((a, b), c)",
+		"x = a ? b : c",
+		"(x = a) ? b : c",
+	} {
+		if got := ast.Beautify(expression(t, source)); got != source {
+			t.Errorf("%s: beautified to %s", source, got)
+		}
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print a ? b;", "Ln 1, Col 12 Expect ':' after then branch of conditional expression started at Ln 1, Col 9."},
+		{"print a ?\n  b\n  c : d;", "Ln 3, Col 3 Expect ':' after then branch of conditional expression started at Ln 1, Col 9."},
+		{"print a ? b : ;", "Ln 1, Col 15 Expect expression."},
+	}
+	for _, test := range tests {
+		_, errors := parse(t, test.source)
+		if len(errors) == 0 || errors[0] != test.want {
+			t.Errorf("%q: got errors %q, want %q first", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestAssignmentTargets(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{"a += 1;", ""},
+		{"a.b -= 1;", ""},
+		{"a[0] *= 2;", ""},
+		{"a.b[c].d /= 2;", ""},
+		{"a++; --a.b; a[0]--; ++a[0];", ""},
+		{"1 += 2;", "Ln 1, Col 4 Invalid assignment target."},
+		{"a + b -= 1;", "Ln 1, Col 8 Invalid assignment target."},
+		{"f() *= 2;", "Ln 1, Col 6 Invalid assignment target."},
+		{"a[1:] = 2;", "Ln 1, Col 7 Invalid assignment target."},
+		{"f()++;", "Ln 1, Col 5 Invalid operand for '++'."},
+		{"--1;", "Ln 1, Col 2 Invalid operand for '--'."},
+		{"(a)++;", "Ln 1, Col 5 Invalid operand for '++'."},
+	}
+	for _, test := range tests {
+		_, errors := parse(t, test.source)
+		if test.want == "" && len(errors) > 0 || test.want != "" && (len(errors) == 0 || errors[0] != test.want) {
+			t.Errorf("%s: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestLambdas(t *testing.T) {
+	tests := []struct {
+		source string
+		arrow  bool
+		params int
+	}{
+		{"fun () {}", false, 0},
+		{"fun (a, b) {\n  return a + b;\n}", false, 2},
+		{"() => nil", true, 0},
+		{"(a) => a", true, 1},
+		{"(a, b) => (c) => a + b + c", true, 2},
+	}
+	for _, test := range tests {
+		lambda, ok := expression(t, test.source).(*ast.Lambda)
+		if !ok || lambda.Arrow != test.arrow || len(lambda.Params) != test.params {
+			t.Errorf("%s: got %v", test.source, lambda)
+			continue
+		}
+		if got := ast.Beautify(lambda); got != test.source {
+			t.Errorf("%s: beautified to %s", test.source, got)
+		}
+	}
+	// A parenthesized expression isn't a lambda without an arrow.
+	if _, ok := expression(t, "(a)").(*ast.Grouping); !ok {
+		t.Error("(a) is not a grouping")
+	}
+}
+
+func TestLambdaNames(t *testing.T) {
+	statements, errors := parse(t, `
+var f = () => 1;
+g = fun () {};
+o.h = () => 2;
+var outer = () => () => 3;
+call(() => 4);
+`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	lambdas := []*ast.Lambda{
+		statements[0].(*ast.VarStmt).Initializer.(*ast.Lambda),
+		statements[1].(*ast.ExpressionStmt).Expression.(*ast.Assign).Value.(*ast.Lambda),
+		statements[2].(*ast.ExpressionStmt).Expression.(*ast.Set).Value.(*ast.Lambda),
+		statements[3].(*ast.VarStmt).Initializer.(*ast.Lambda),
+		statements[3].(*ast.VarStmt).Initializer.(*ast.Lambda).Body[0].(*ast.ReturnStmt).Value.(*ast.Lambda),
+		statements[4].(*ast.ExpressionStmt).Expression.(*ast.Call).Arguments[0].(*ast.Lambda),
+	}
+	// Only lambdas assigned straight to a name take it.
+	for n, want := range []string{"f", "g", "h", "outer", "", ""} {
+		if got := lambdas[n].Name.Lexeme; got != want {
+			t.Errorf("lambda %d: got name %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestClassMembers(t *testing.T) {
+	statements, errors := parse(t, `
+class C {
+  m(a) {}
+  static make() {}
+  size {}
+  set size(value) {}
+  static() {}
+  set(key, value) {}
+}`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	want := []struct {
+		name string
+		kind ast.FunctionKind
+	}{
+		{"m", ast.Method}, {"make", ast.StaticMethod}, {"size", ast.Getter},
+		{"size", ast.Setter}, {"static", ast.Method}, {"set", ast.Method},
+	}
+	methods := statements[0].(*ast.ClassStmt).Methods
+	if len(methods) != len(want) {
+		t.Fatalf("got %d methods, want %d", len(methods), len(want))
+	}
+	for n, method := range methods {
+		method := method.(*ast.FunStmt)
+		if method.Name.Lexeme != want[n].name || method.Kind != want[n].kind {
+			t.Errorf("method %d: got %s of kind %d, want %s of kind %d", n, method.Name.Lexeme, method.Kind, want[n].name, want[n].kind)
+		}
+	}
+}
+
+func TestClassMemberErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"class C { set x() {} }", "Ln 1, Col 15 A setter must take exactly one parameter."},
+		{"class C { set x(a, b) {} }", "Ln 1, Col 15 A setter must take exactly one parameter."},
+		{"class C { init { } }", "Ln 1, Col 14 An initializer can't be a getter."},
+		{"class C { get x() {} }", "Ln 1, Col 13 Expect '(' or '{' after method name."},
+		{"class C { static x; }", "Ln 1, Col 19 Expect '(' after method name."},
+	}
+	for _, test := range tests {
+		_, errors := parse(t, test.source)
+		if len(errors) == 0 || errors[0] != test.want {
+			t.Errorf("%s: got errors %q, want %q first", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestConstNeedsInitializer(t *testing.T) {
+	_, errors := parse(t, "const a;")
+	want := "Ln 1, Col 8 Expect '=' after constant name; a constant needs an initializer."
+	if len(errors) != 1 || errors[0] != want {
+		t.Errorf("got errors %q, want %q", errors, want)
+	}
+}
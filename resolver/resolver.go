@@ -0,0 +1,522 @@
+// Package resolver walks a parsed program before it runs, binding every
+// variable reference to the declaration it refers to and reporting errors
+// the parser can't see, such as assignments to constants.
+package resolver
+
+import (
+	"Glox/ast"
+	"Glox/token"
+	"fmt"
+)
+
+// Kind says what introduced a name.
+type Kind int
+
+const (
+	Variable Kind = iota
+	Constant
+	Parameter
+	Function
+	Class
+	Import
+)
+
+var kindNames = []string{"variable", "constant", "parameter", "function", "class", "import"}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Declaration is a name introduced into some scope.
+type Declaration struct {
+	Name token.Token
+	Kind Kind
+	slot int // index of the name in its scope, in declaration order.
+}
+
+// Global is the Slot of a Binding to a name at the top level of a file,
+// which includes names never declared, such as natives. Those are looked
+// up by name, since the top level grows as the file runs.
+const Global = -1
+
+// Binding locates the variable a use of a name refers to: Slot in the
+// scope Depth scopes out from the use. Scopes count blocks, function
+// bodies with their parameters, catch clauses, match cases and the scopes
+// binding "this" in methods and "super" in subclasses.
+type Binding struct {
+	Depth int
+	Slot  int
+}
+
+// Reference is a use of a name. Declaration is what the name refers to, or
+// nil when it was never declared, as for natives.
+type Reference struct {
+	Name        token.Token
+	Declaration *Declaration
+}
+
+// Resolver checks statements one batch at a time. Global declarations are
+// remembered between calls to Resolve, so a REPL can keep one resolver for
+// the whole session. Names it never saw declared, such as natives, are left
+// for the interpreter to look up.
+type Resolver struct {
+	// scopes[0] is the global scope; the last entry is the innermost.
+	scopes []map[string]*Declaration
+	errors []string
+
+	declarations []*Declaration
+	references   []Reference
+	bindings     map[ast.Expression]Binding
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{scopes: []map[string]*Declaration{{}}}
+}
+
+// Resolve checks statements. When it finds errors the global scope is
+// left as it was, since the statements will not run.
+func (r *Resolver) Resolve(statements []ast.Statement) {
+	r.errors = nil
+	r.declarations = nil
+	r.references = nil
+	r.bindings = make(map[ast.Expression]Binding)
+	globals := make(map[string]*Declaration, len(r.scopes[0]))
+	for name, declaration := range r.scopes[0] {
+		globals[name] = declaration
+	}
+	r.resolveStatements(statements)
+	// Function bodies may use globals declared after them.
+	for n, reference := range r.references {
+		if reference.Declaration == nil {
+			r.references[n].Declaration = r.scopes[0][reference.Name.Lexeme]
+		}
+	}
+	if len(r.errors) > 0 {
+		r.scopes = []map[string]*Declaration{globals}
+	}
+}
+
+// Errors returns the errors found by the last call to Resolve.
+func (r *Resolver) Errors() []string {
+	return r.errors
+}
+
+// Bindings returns where the variables, assignments, this and super
+// expressions the last call to Resolve saw refer to. The binding of super
+// locates "super"; "this" is then the only name one scope further in.
+func (r *Resolver) Bindings() map[ast.Expression]Binding {
+	return r.bindings
+}
+
+// Declarations returns the names the last call to Resolve saw declared.
+func (r *Resolver) Declarations() []*Declaration {
+	return r.declarations
+}
+
+// References returns the uses of names the last call to Resolve saw,
+// reads and assignments alike. Declarations aren't uses.
+func (r *Resolver) References() []Reference {
+	return r.references
+}
+
+func (r *Resolver) resolveStatements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *Resolver) resolveStmt(stmt ast.Statement) {
+	stmt.Accept(r)
+}
+
+func (r *Resolver) resolveExpr(expr ast.Expression) {
+	expr.Accept(r)
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, map[string]*Declaration{})
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare adds name to the innermost scope. Redeclaring a constant in the
+// same scope is an error.
+func (r *Resolver) declare(name token.Token, kind Kind) {
+	scope := r.scopes[len(r.scopes)-1]
+	if previous, ok := scope[name.Lexeme]; ok && previous.Kind == Constant {
+		r.error(name, fmt.Sprintf("Cannot redeclare constant '%s' declared at Ln %d, Col %d.", name.Lexeme, previous.Name.Line, previous.Name.Col))
+		return
+	}
+	r.declarations = append(r.declarations, r.define(name, kind))
+}
+
+// define adds name to the innermost scope without recording a declaration,
+// as for "this" and "super". Redeclaring a name reuses its slot.
+func (r *Resolver) define(name token.Token, kind Kind) *Declaration {
+	scope := r.scopes[len(r.scopes)-1]
+	declaration := &Declaration{Name: name, Kind: kind, slot: len(scope)}
+	if previous, ok := scope[name.Lexeme]; ok {
+		declaration.slot = previous.slot
+	}
+	scope[name.Lexeme] = declaration
+	return declaration
+}
+
+// lookup finds the declaration name refers to, or nil if it was never
+// declared, and records the reference and where expr binds.
+func (r *Resolver) lookup(expr ast.Expression, name token.Token) *Declaration {
+	found := r.bind(expr, name)
+	r.references = append(r.references, Reference{Name: name, Declaration: found})
+	return found
+}
+
+// bind records the binding of expr, a use of name, and returns the
+// declaration it refers to.
+func (r *Resolver) bind(expr ast.Expression, name token.Token) *Declaration {
+	for n := len(r.scopes) - 1; n > 0; n-- {
+		if declaration, ok := r.scopes[n][name.Lexeme]; ok {
+			r.bindings[expr] = Binding{Depth: len(r.scopes) - 1 - n, Slot: declaration.slot}
+			return declaration
+		}
+	}
+	r.bindings[expr] = Binding{Depth: len(r.scopes) - 1, Slot: Global}
+	return r.scopes[0][name.Lexeme]
+}
+
+func (r *Resolver) assign(expr ast.Expression, name token.Token) {
+	if declaration := r.lookup(expr, name); declaration != nil && declaration.Kind == Constant {
+		r.error(name, fmt.Sprintf("Cannot assign to constant '%s' declared at Ln %d, Col %d.", name.Lexeme, declaration.Name.Line, declaration.Name.Col))
+	}
+}
+
+// assignTarget checks the target of a compound assignment or an increment.
+func (r *Resolver) assignTarget(target ast.Expression) {
+	if variable, ok := target.(*ast.Variable); ok {
+		r.assign(variable, variable.Name)
+		return
+	}
+	r.resolveExpr(target)
+}
+
+func (r *Resolver) resolveFunction(params []token.Token, body []ast.Statement) {
+	r.beginScope()
+	for _, param := range params {
+		r.declare(param, Parameter)
+	}
+	r.resolveStatements(body)
+	r.endScope()
+}
+
+func (r *Resolver) resolvePattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		r.resolveExpr(pattern.Value)
+	case *ast.BindingPattern:
+		if pattern.Name.Lexeme != "_" {
+			r.declare(pattern.Name, Variable)
+		}
+	case *ast.ListPattern:
+		for _, element := range pattern.Elements {
+			r.resolvePattern(element)
+		}
+	case *ast.InstancePattern:
+		r.resolveExpr(pattern.Class)
+		for _, sub := range pattern.Patterns {
+			r.resolvePattern(sub)
+		}
+	}
+}
+
+func (r *Resolver) error(tok token.Token, msg string) {
+	r.errors = append(r.errors, fmt.Sprintf("Ln %d, Col %d %s", tok.Line, tok.Col, msg))
+}
+
+func (r *Resolver) VisitBlockStmt(stmt *ast.BlockStmt) interface{} {
+	r.beginScope()
+	r.resolveStatements(stmt.Statements)
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitBreakStmt(stmt *ast.BreakStmt) interface{} {
+	return nil
+}
+
+// VisitClassStmt mirrors the scopes of a running class: methods close over
+// a scope binding "super" when there is a superclass, and are bound to a
+// scope holding "this" when called.
+func (r *Resolver) VisitClassStmt(stmt *ast.ClassStmt) interface{} {
+	if stmt.Superclass != nil {
+		r.resolveExpr(stmt.Superclass)
+	}
+	r.declare(stmt.Name, Class)
+	if stmt.Superclass != nil {
+		r.beginScope()
+		r.define(token.Token{Type: token.SUPER, Lexeme: "super"}, Variable)
+		defer r.endScope()
+	}
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		r.beginScope()
+		r.define(token.Token{Type: token.THIS, Lexeme: "this"}, Variable)
+		r.resolveFunction(method.Params, method.Body)
+		r.endScope()
+	}
+	return nil
+}
+
+func (r *Resolver) VisitContinueStmt(stmt *ast.ContinueStmt) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitExportStmt(stmt *ast.ExportStmt) interface{} {
+	r.resolveStmt(stmt.Declaration)
+	return nil
+}
+
+func (r *Resolver) VisitExpressionStmt(stmt *ast.ExpressionStmt) interface{} {
+	r.resolveExpr(stmt.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitFunctionStmt(stmt *ast.FunStmt) interface{} {
+	r.declare(stmt.Name, Function)
+	r.resolveFunction(stmt.Params, stmt.Body)
+	return nil
+}
+
+func (r *Resolver) VisitIfStmt(stmt *ast.IfStmt) interface{} {
+	r.resolveExpr(stmt.Condition)
+	r.resolveStmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		r.resolveStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitImportStmt(stmt *ast.ImportStmt) interface{} {
+	if stmt.Names == nil {
+		r.declare(stmt.Alias, Import)
+		return nil
+	}
+	for _, name := range stmt.Names {
+		r.declare(name, Import)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitMatchStmt(stmt *ast.MatchStmt) interface{} {
+	r.resolveExpr(stmt.Subject)
+	for _, c := range stmt.Cases {
+		for _, pattern := range c.Patterns {
+			r.beginScope()
+			r.resolvePattern(pattern)
+			if c.Guard != nil {
+				r.resolveExpr(c.Guard)
+			}
+			r.resolveStmt(c.Body)
+			r.endScope()
+		}
+	}
+	if stmt.Default != nil {
+		r.resolveStmt(stmt.Default)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitPrintStmt(stmt *ast.PrintStmt) interface{} {
+	r.resolveExpr(stmt.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitReturnStmt(stmt *ast.ReturnStmt) interface{} {
+	if stmt.Value != nil {
+		r.resolveExpr(stmt.Value)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitThrowStmt(stmt *ast.ThrowStmt) interface{} {
+	r.resolveExpr(stmt.Value)
+	return nil
+}
+
+func (r *Resolver) VisitTryStmt(stmt *ast.TryStmt) interface{} {
+	r.beginScope()
+	r.resolveStatements(stmt.Body)
+	r.endScope()
+	if stmt.Catch != nil {
+		r.beginScope()
+		r.declare(stmt.Param, Variable)
+		r.resolveStatements(stmt.Catch.Statements)
+		r.endScope()
+	}
+	if stmt.Finally != nil {
+		r.resolveStmt(stmt.Finally)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitVarStmt(stmt *ast.VarStmt) interface{} {
+	if stmt.Initializer != nil {
+		r.resolveExpr(stmt.Initializer)
+	}
+	if stmt.Const {
+		r.declare(stmt.Name, Constant)
+	} else {
+		r.declare(stmt.Name, Variable)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitWhileStmt(stmt *ast.WhileStmt) interface{} {
+	r.resolveExpr(stmt.Condition)
+	r.resolveStmt(stmt.Body)
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitAssignExpr(expr *ast.Assign) interface{} {
+	r.resolveExpr(expr.Value)
+	r.assign(expr, expr.Name)
+	return nil
+}
+
+func (r *Resolver) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitCallExpr(expr *ast.Call) interface{} {
+	r.resolveExpr(expr.Callee)
+	for _, argument := range expr.Arguments {
+		r.resolveExpr(argument)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitCommaExpr(expr *ast.Comma) interface{} {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitCompoundAssignExpr(expr *ast.CompoundAssign) interface{} {
+	r.assignTarget(expr.Target)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitConditionalExpr(expr *ast.Conditional) interface{} {
+	r.resolveExpr(expr.Condition)
+	r.resolveExpr(expr.Then)
+	r.resolveExpr(expr.Else)
+	return nil
+}
+
+func (r *Resolver) VisitGetExpr(expr *ast.Get) interface{} {
+	r.resolveExpr(expr.Object)
+	return nil
+}
+
+func (r *Resolver) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	r.resolveExpr(expr.Expression)
+	return nil
+}
+
+func (r *Resolver) VisitIncrementExpr(expr *ast.Increment) interface{} {
+	r.assignTarget(expr.Target)
+	return nil
+}
+
+func (r *Resolver) VisitIndexExpr(expr *ast.Index) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil
+}
+
+func (r *Resolver) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	for _, part := range expr.Parts {
+		r.resolveExpr(part)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	r.resolveFunction(expr.Params, expr.Body)
+	return nil
+}
+
+func (r *Resolver) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	return nil
+}
+
+func (r *Resolver) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	r.resolveExpr(expr.Left)
+	r.resolveExpr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitMapExpr(expr *ast.Map) interface{} {
+	for n, key := range expr.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(expr.Values[n])
+	}
+	return nil
+}
+
+func (r *Resolver) VisitSetExpr(expr *ast.Set) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	r.resolveExpr(expr.Value)
+	return nil
+}
+
+func (r *Resolver) VisitSliceExpr(expr *ast.Slice) interface{} {
+	r.resolveExpr(expr.Object)
+	if expr.Start != nil {
+		r.resolveExpr(expr.Start)
+	}
+	if expr.End != nil {
+		r.resolveExpr(expr.End)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitSuperExpr(expr *ast.Super) interface{} {
+	r.bind(expr, expr.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitThisExpr(expr *ast.This) interface{} {
+	r.bind(expr, expr.Keyword)
+	return nil
+}
+
+func (r *Resolver) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	r.resolveExpr(expr.Right)
+	return nil
+}
+
+func (r *Resolver) VisitVariableExpr(expr *ast.Variable) interface{} {
+	r.lookup(expr, expr.Name)
+	return nil
+}
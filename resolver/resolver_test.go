@@ -0,0 +1,129 @@
+package resolver
+
+import (
+	"Glox/ast"
+	"Glox/parser"
+	"Glox/scanner"
+	"strings"
+	"testing"
+)
+
+// resolve parses and resolves source, failing the test on any error.
+func resolve(t *testing.T, source string) ([]ast.Statement, *Resolver) {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	r := NewResolver()
+	if len(s.Errors()) == 0 && len(p.Errors()) == 0 {
+		r.Resolve(statements)
+	}
+	if errors := append(append(s.Errors(), p.Errors()...), r.Errors()...); len(errors) > 0 {
+		t.Fatalf("resolving %q: %s", source, strings.Join(errors, "; "))
+	}
+	return statements, r
+}
+
+// uses returns the bindings of the uses of name, in no particular order.
+func uses(r *Resolver, name string) []Binding {
+	var bindings []Binding
+	for expr, binding := range r.Bindings() {
+		if variable, ok := expr.(*ast.Variable); ok && variable.Name.Lexeme == name {
+			bindings = append(bindings, binding)
+		}
+	}
+	return bindings
+}
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   Binding
+	}{
+		{"global", `var a = 1; print a;`, Binding{Depth: 0, Slot: Global}},
+		{"undeclared", `print a;`, Binding{Depth: 0, Slot: Global}},
+		{"block", `{ var x = 1; var a = 2; print a; }`, Binding{Depth: 0, Slot: 1}},
+		{"enclosing block", `{ var a = 1; { print a; } }`, Binding{Depth: 1, Slot: 0}},
+		{"parameter", `fun f(x, a) { return a; }`, Binding{Depth: 0, Slot: 1}},
+		{"global from a block", `{ { print a; } }`, Binding{Depth: 2, Slot: Global}},
+		{"redeclaration", `{ var a = 1; var b = 2; var a = 3; print a; }`, Binding{Depth: 0, Slot: 0}},
+		{"catch", `try {} catch (a) { print a; }`, Binding{Depth: 0, Slot: 0}},
+		{"match case", `match (1) { case [x, a] => print a; }`, Binding{Depth: 0, Slot: 1}},
+		// Methods run in a scope binding this, inside one binding super.
+		{"method", `{ var a = 1; class A {} class B < A { m() { return a; } } }`, Binding{Depth: 3, Slot: 0}},
+	}
+	for _, test := range tests {
+		_, r := resolve(t, test.source)
+		got := uses(r, "a")
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLaterDeclaration(t *testing.T) {
+	// A function binds to what is in scope where it is declared, not to a
+	// later declaration in the same block.
+	_, r := resolve(t, `
+var a = "global";
+{
+  fun show() { print a; }
+  var a = "block";
+  print a;
+}`)
+	got := uses(r, "a")
+	want := map[Binding]bool{{Depth: 2, Slot: Global}: true, {Depth: 0, Slot: 1}: true}
+	if len(got) != 2 || !want[got[0]] || !want[got[1]] {
+		t.Errorf("got %+v, want the global from show and slot 1 of the block", got)
+	}
+}
+
+func TestThisAndSuper(t *testing.T) {
+	statements, r := resolve(t, `
+class A { m() { return 1; } }
+class B < A { m() { return this.x + super.m(); } }`)
+	method := statements[1].(*ast.ClassStmt).Methods[0].(*ast.FunStmt)
+	sum := method.Body[0].(*ast.ReturnStmt).Value.(*ast.Binary)
+	this := sum.Left.(*ast.Get).Object
+	super := sum.Right.(*ast.Call).Callee
+	if got := r.Bindings()[this]; got != (Binding{Depth: 1, Slot: 0}) {
+		t.Errorf("this: got %+v, want depth 1, slot 0", got)
+	}
+	if got := r.Bindings()[super]; got != (Binding{Depth: 2, Slot: 0}) {
+		t.Errorf("super: got %+v, want depth 2, slot 0", got)
+	}
+}
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // the error, or "" for none.
+	}{
+		{"const a = 1; a = 2;", "Ln 1, Col 14 Cannot assign to constant 'a' declared at Ln 1, Col 7."},
+		{"const a = 1; a += 2;", "Ln 1, Col 14 Cannot assign to constant 'a' declared at Ln 1, Col 7."},
+		{"const a = 1; a++;", "Ln 1, Col 14 Cannot assign to constant 'a' declared at Ln 1, Col 7."},
+		{"const a = 1;\nfun f() { --a; }", "Ln 2, Col 13 Cannot assign to constant 'a' declared at Ln 1, Col 7."},
+		{"const a = 1; var a = 2;", "Ln 1, Col 18 Cannot redeclare constant 'a' declared at Ln 1, Col 7."},
+		{"const a = 1; fun a() {}", "Ln 1, Col 18 Cannot redeclare constant 'a' declared at Ln 1, Col 7."},
+		{"{ const a = 1; const a = 2; }", "Ln 1, Col 22 Cannot redeclare constant 'a' declared at Ln 1, Col 9."},
+		{"const a = 1; { var a = 2; a = 3; }", ""},
+		{"const a = 1; fun f(a) { a = 2; }", ""},
+		{"var a = 1; const b = a; a = b;", ""},
+	}
+	for _, test := range tests {
+		s := scanner.NewScanner(test.source)
+		p := parser.NewParser(s.ScanTokens())
+		statements := p.Parse()
+		if errors := append(s.Errors(), p.Errors()...); len(errors) > 0 {
+			t.Fatalf("%s: unexpected errors %q", test.source, errors)
+		}
+		r := NewResolver()
+		r.Resolve(statements)
+		errors := r.Errors()
+		if test.want == "" && len(errors) > 0 || test.want != "" && (len(errors) != 1 || errors[0] != test.want) {
+			t.Errorf("%s: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
@@ -3,17 +3,30 @@ package scanner
 import (
 	"Glox/token"
 	"fmt"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Scanner struct {
-	source  []rune
-	tokens  []token.Token
-	errors  []string
-	start   int
-	current int
-	line    int
-	col     int
+	source         []rune
+	tokens         []token.Token
+	errors         []string
+	start          int
+	current        int
+	line           int
+	col            int
+	interpolations []interpolation
+	trivia         bool // return comments and whitespace too.
+}
+
+// interpolation tracks an open "${" so the scanner knows which '}' resumes
+// the enclosing string.
+type interpolation struct {
+	line  int
+	col   int
+	depth int // unmatched '{' seen inside the embedded expression.
 }
 
 func NewScanner(source string) *Scanner {
@@ -29,27 +42,63 @@ func NewScanner(source string) *Scanner {
 	return s
 }
 
+// NewTriviaScanner returns a scanner in trivia mode: ScanTokens also
+// returns the comments and the runs of whitespace of the source, as COMMENT
+// and WHITESPACE tokens, so the lexemes of the tokens spell out the source
+// exactly. The parser doesn't expect trivia; give it the tokens
+// WithoutTrivia returns.
+func NewTriviaScanner(source string) *Scanner {
+	s := NewScanner(source)
+	s.trivia = true
+	return s
+}
+
+// WithoutTrivia returns the tokens that aren't trivia.
+func WithoutTrivia(tokens []token.Token) []token.Token {
+	code := make([]token.Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if !tok.Type.IsTrivia() {
+			code = append(code, tok)
+		}
+	}
+	return code
+}
+
 func (s *Scanner) Errors() []string {
 	return s.errors
 }
 
 var keywords = map[string]token.TokenType{
-	"and":    token.AND,
-	"class":  token.CLASS,
-	"else":   token.ELSE,
-	"false":  token.FALSE,
-	"for":    token.FOR,
-	"fun":    token.FUN,
-	"if":     token.IF,
-	"nil":    token.NIL,
-	"or":     token.OR,
-	"print":  token.PRINT,
-	"return": token.RETURN,
-	"super":  token.SUPER,
-	"this":   token.THIS,
-	"true":   token.TRUE,
-	"var":    token.VAR,
-	"while":  token.WHILE,
+	"and":      token.AND,
+	"as":       token.AS,
+	"break":    token.BREAK,
+	"case":     token.CASE,
+	"catch":    token.CATCH,
+	"class":    token.CLASS,
+	"const":    token.CONST,
+	"continue": token.CONTINUE,
+	"default":  token.DEFAULT,
+	"else":     token.ELSE,
+	"export":   token.EXPORT,
+	"false":    token.FALSE,
+	"finally":  token.FINALLY,
+	"for":      token.FOR,
+	"from":     token.FROM,
+	"fun":      token.FUN,
+	"if":       token.IF,
+	"import":   token.IMPORT,
+	"match":    token.MATCH,
+	"nil":      token.NIL,
+	"or":       token.OR,
+	"print":    token.PRINT,
+	"return":   token.RETURN,
+	"super":    token.SUPER,
+	"this":     token.THIS,
+	"throw":    token.THROW,
+	"true":     token.TRUE,
+	"try":      token.TRY,
+	"var":      token.VAR,
+	"while":    token.WHILE,
 }
 
 func (s *Scanner) ScanTokens() []token.Token {
@@ -58,6 +107,10 @@ func (s *Scanner) ScanTokens() []token.Token {
 		s.start = s.current
 		s.scanToken()
 	}
+	if n := len(s.interpolations); n > 0 {
+		open := s.interpolations[n-1]
+		s.errorAt(open.line, open.col, "Unterminated string interpolation.")
+	}
 	s.tokens = append(s.tokens, token.Token{Type: token.EOF, Lexeme: ""})
 	return s.tokens
 }
@@ -70,21 +123,64 @@ func (s *Scanner) scanToken() {
 	case rune(')'):
 		s.addToken(token.RIGHT_PAREN, ")")
 	case rune('{'):
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1].depth += 1
+		}
 		s.addToken(token.LEFT_BRACE, "{")
 	case rune('}'):
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1].depth == 0 {
+				// This brace closes "${", resume the string.
+				if s.lastToken().Type == token.INTERPOLATION {
+					s.errorAt(s.line, s.col, "Expect expression in string interpolation.")
+				}
+				s.interpolations = s.interpolations[:n-1]
+				s.string()
+				return
+			}
+			s.interpolations[n-1].depth -= 1
+		}
 		s.addToken(token.RIGHT_BRACE, "}")
+	case rune('['):
+		s.addToken(token.LEFT_BRACKET, "[")
+	case rune(']'):
+		s.addToken(token.RIGHT_BRACKET, "]")
 	case rune(','):
 		s.addToken(token.COMMA, ",")
+	case rune(':'):
+		s.addToken(token.COLON, ":")
 	case rune('.'):
 		s.addToken(token.DOT, ".")
 	case rune('-'):
-		s.addToken(token.MINUS, ".")
+		if s.match('-') {
+			s.addToken(token.MINUS_MINUS, "--")
+		} else if s.match('=') {
+			s.addToken(token.MINUS_EQUAL, "-=")
+		} else {
+			s.addToken(token.MINUS, "-")
+		}
 	case rune('+'):
-		s.addToken(token.PLUS, "+")
+		if s.match('+') {
+			s.addToken(token.PLUS_PLUS, "++")
+		} else if s.match('=') {
+			s.addToken(token.PLUS_EQUAL, "+=")
+		} else {
+			s.addToken(token.PLUS, "+")
+		}
+	case rune('?'):
+		s.addToken(token.QUESTION, "?")
 	case rune(';'):
 		s.addToken(token.SEMICOLON, ";")
 	case rune('*'):
-		s.addToken(token.STAR, "*")
+		if s.match('=') {
+			s.addToken(token.STAR_EQUAL, "*=")
+		} else {
+			s.addToken(token.STAR, "*")
+		}
+	case rune('\\'):
+		s.addToken(token.BACKSLASH, "\\")
+	case rune('%'):
+		s.addToken(token.PERCENT, "%")
 	case rune('!'):
 		if s.match('=') {
 			s.addToken(token.BANG_EQUAL, "!=")
@@ -94,6 +190,8 @@ func (s *Scanner) scanToken() {
 	case rune('='):
 		if s.match('=') {
 			s.addToken(token.EQUAL_EQUAL, "==")
+		} else if s.match('>') {
+			s.addToken(token.ARROW, "=>")
 		} else {
 			s.addToken(token.EQUAL, "=")
 		}
@@ -115,14 +213,16 @@ func (s *Scanner) scanToken() {
 			for !s.isAtEnd() && s.peek() != rune('\n') {
 				s.advance()
 			}
+			s.addComment()
+		} else if s.match('*') {
+			s.blockComment()
+		} else if s.match('=') {
+			s.addToken(token.SLASH_EQUAL, "/=")
 		} else {
 			s.addToken(token.SLASH, "/")
 		}
-	case rune(' '), rune('\r'), rune('\t'):
-		s.col += 1
-	case rune('\n'):
-		s.line += 1
-		s.col = 0
+	case rune(' '), rune('\r'), rune('\t'), rune('\n'):
+		s.whitespace(c)
 	case rune('"'):
 		s.string()
 	default:
@@ -131,8 +231,33 @@ func (s *Scanner) scanToken() {
 		} else if isAlpha(c) {
 			s.identifier()
 		} else {
-			s.errors = append(s.errors, fmt.Sprintf("Ln %d, Col %d Unexpected character: '%c'", s.line, s.col, c))
+			s.errorAt(s.line, s.col, fmt.Sprintf("Unexpected character: '%c'", c))
+		}
+	}
+}
+
+// whitespace skips a run of whitespace that starts with c.
+func (s *Scanner) whitespace(c rune) {
+	for {
+		// The token ends at the last character of the run, so remember where
+		// that is before a newline moves to the next line.
+		line, col := s.line, s.col
+		if c == '\n' {
+			s.line += 1
+			s.col = 0
+		}
+		if s.isAtEnd() || !isWhitespace(s.peek()) {
+			if s.trivia {
+				s.tokens = append(s.tokens, token.Token{
+					Type:   token.WHITESPACE,
+					Lexeme: string(s.source[s.start:s.current]),
+					Line:   line,
+					Col:    col,
+				})
+			}
+			return
 		}
+		c = s.advance()
 	}
 }
 
@@ -149,38 +274,249 @@ func (s *Scanner) identifier() {
 }
 
 func (s *Scanner) number() {
-	for isDigit(s.peek()) {
-		s.advance()
+	if s.source[s.start] == '0' {
+		switch s.peek() {
+		case 'x', 'X':
+			s.radixNumber(16, "hexadecimal", isHexDigit)
+			return
+		case 'b', 'B':
+			s.radixNumber(2, "binary", isBinaryDigit)
+			return
+		case 'o', 'O':
+			s.radixNumber(8, "octal", isOctalDigit)
+			return
+		}
 	}
+	s.digits(isDigit)
+	// Literals without a fraction or an exponent are integers.
+	isFloat := false
 	// Look for a fractional part.
 	if s.peek() == '.' && isDigit(s.peekNext()) {
 		// Consume the "."
 		s.advance()
-		for isDigit(s.peek()) {
+		s.digits(isDigit)
+		isFloat = true
+	}
+	// Look for an exponent.
+	if s.peek() == 'e' || s.peek() == 'E' {
+		isFloat = true
+		s.advance()
+		if s.peek() == '+' || s.peek() == '-' {
+			s.advance()
+		}
+		if !isDigit(s.peek()) {
+			s.errorAt(s.line, s.col, "Invalid number literal: exponent has no digits.")
+			return
+		}
+		s.digits(isDigit)
+	}
+	if !s.numberEnd("number literal", isDigit) {
+		return
+	}
+	text := string(s.source[s.start:s.current])
+	digits := strings.ReplaceAll(text, "_", "")
+	var value interface{}
+	var err error
+	if isFloat {
+		value, err = strconv.ParseFloat(digits, 64)
+	} else {
+		value, err = strconv.ParseInt(digits, 10, 64)
+	}
+	if err != nil {
+		s.errorAt(s.line, s.col-len(s.source[s.start:s.current])+1, "Number literal out of range.")
+		return
+	}
+	s.addLiteralToken(token.NUMBER, text, value)
+}
+
+// radixNumber scans the digits of a number written with a 0x, 0b or 0o
+// prefix. The prefix itself has not been consumed yet.
+func (s *Scanner) radixNumber(base int, name string, isRadixDigit func(rune) bool) {
+	col := s.col
+	prefix := s.advance()
+	s.digits(isRadixDigit)
+	if !s.numberEnd(name+" literal", isRadixDigit) {
+		return
+	}
+	text := string(s.source[s.start:s.current])
+	digits := strings.ReplaceAll(text[2:], "_", "")
+	if digits == "" {
+		s.errorAt(s.line, col, fmt.Sprintf("Invalid %s literal: expected digits after '0%c'.", name, prefix))
+		return
+	}
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		s.errorAt(s.line, col, "Number literal out of range.")
+		return
+	}
+	s.addLiteralToken(token.NUMBER, text, value)
+}
+
+// digits consumes a run of digits accepted by isNumberDigit along with any
+// '_' separators between them.
+func (s *Scanner) digits(isNumberDigit func(rune) bool) {
+	for isNumberDigit(s.peek()) || s.peek() == '_' {
+		s.advance()
+	}
+}
+
+// numberEnd validates the literal scanned so far: every '_' must sit
+// between two digits and the literal may not run into letters or digits of
+// another base. It reports false after recording an error.
+func (s *Scanner) numberEnd(name string, isNumberDigit func(rune) bool) bool {
+	if isAlphaNumeric(s.peek()) {
+		c := s.peek()
+		s.errorAt(s.line, s.col+1, fmt.Sprintf("Invalid digit '%c' in %s.", c, name))
+		// Skip the rest of the malformed literal.
+		for isAlphaNumeric(s.peek()) {
 			s.advance()
 		}
+		return false
+	}
+	text := s.source[s.start:s.current]
+	for i, c := range text {
+		if c != '_' {
+			continue
+		}
+		if i == 0 || i == len(text)-1 || !isNumberDigit(text[i-1]) || !isNumberDigit(text[i+1]) {
+			col := s.col - (len(text) - i) + 1
+			s.errorAt(s.line, col, fmt.Sprintf("Invalid '_' in %s: separators must be placed between digits.", name))
+			return false
+		}
 	}
-	s.addToken(token.NUMBER, string(s.source[s.start:s.current]))
+	return true
 }
 
+// string scans a string literal, or the segment of an interpolated string
+// that follows a "}". A segment that ends in "${" is emitted as an
+// INTERPOLATION token and the embedded expression is scanned as usual.
 func (s *Scanner) string() {
+	var value strings.Builder
 	for !s.isAtEnd() && s.peek() != '"' {
-		if s.peek() == '\n' {
+		c := s.advance()
+		switch c {
+		case '$':
+			if s.match('{') {
+				s.interpolations = append(s.interpolations, interpolation{line: s.line, col: s.col - 1})
+				s.addLiteralToken(token.INTERPOLATION, string(s.source[s.start:s.current]), value.String())
+				return
+			}
+			value.WriteRune(c)
+		case '\n':
 			s.line += 1
 			s.col = 0
+			value.WriteRune(c)
+		case '\\':
+			s.escape(&value)
+		default:
+			value.WriteRune(c)
 		}
-		s.advance()
 	}
 	if s.isAtEnd() {
-		s.errors = append(s.errors, fmt.Sprintf("Ln %d, Col %d Unterminated string.", s.line, s.col))
+		s.errorAt(s.line, s.col, "Unterminated string.")
 		return
 	}
 	// The closing ".
 	s.advance()
 
-	// Trim the surrounding quotes.
-	value := s.source[s.start+1 : s.current-1]
-	s.addToken(token.STRING, string(value))
+	// The lexeme keeps the raw source, quotes included.
+	s.addLiteralToken(token.STRING, string(s.source[s.start:s.current]), value.String())
+}
+
+// blockComment skips a /* ... */ comment. Block comments nest, so every
+// "/*" inside the comment needs its own "*/".
+func (s *Scanner) blockComment() {
+	// Remember the opening so an unterminated comment can point at it.
+	line, col := s.line, s.col-1
+	depth := 1
+	for depth > 0 {
+		if s.isAtEnd() {
+			s.errorAt(line, col, "Unterminated block comment.")
+			return
+		}
+		c := s.advance()
+		switch {
+		case c == '\n':
+			s.line += 1
+			s.col = 0
+		case c == '/' && s.match('*'):
+			depth += 1
+		case c == '*' && s.match('/'):
+			depth -= 1
+		}
+	}
+	s.addComment()
+}
+
+// escape decodes the escape sequence that follows a backslash inside a
+// string literal and writes the result into value.
+func (s *Scanner) escape(value *strings.Builder) {
+	// Diagnostics point at the backslash.
+	line, col := s.line, s.col
+	if s.isAtEnd() {
+		return
+	}
+	c := s.advance()
+	switch c {
+	case 'n':
+		value.WriteRune('\n')
+	case 't':
+		value.WriteRune('\t')
+	case 'r':
+		value.WriteRune('\r')
+	case '\\':
+		value.WriteRune('\\')
+	case '"':
+		value.WriteRune('"')
+	case '$':
+		value.WriteRune('$')
+	case '0':
+		value.WriteRune(0)
+	case 'x':
+		// \xHH denotes the code point U+00HH.
+		code, n := s.hexDigits(2)
+		if n != 2 {
+			s.errorAt(line, col, "Invalid escape sequence: '\\x' must be followed by exactly two hex digits.")
+			return
+		}
+		value.WriteRune(rune(code))
+	case 'u':
+		if !s.match('{') {
+			s.errorAt(line, col, "Invalid escape sequence: expected '{' after '\\u'.")
+			return
+		}
+		code, n := s.hexDigits(6)
+		if n == 0 {
+			s.errorAt(line, col, "Invalid escape sequence: '\\u{' must be followed by one to six hex digits.")
+			return
+		}
+		if !s.match('}') {
+			s.errorAt(line, col, "Invalid escape sequence: expected '}' to close '\\u{'.")
+			return
+		}
+		if !utf8.ValidRune(rune(code)) {
+			s.errorAt(line, col, fmt.Sprintf("Invalid escape sequence: U+%X is not a valid Unicode code point.", code))
+			return
+		}
+		value.WriteRune(rune(code))
+	case '\n':
+		s.line += 1
+		s.col = 0
+		s.errorAt(line, col, "Invalid escape sequence: '\\' at end of line.")
+	default:
+		s.errorAt(line, col, fmt.Sprintf("Invalid escape sequence: '\\%c'.", c))
+	}
+}
+
+// hexDigits consumes up to max hexadecimal digits and returns their value
+// along with the number of digits read.
+func (s *Scanner) hexDigits(max int) (int, int) {
+	code, n := 0, 0
+	for n < max && isHexDigit(s.peek()) {
+		code = code*16 + hexValue(s.advance())
+		n += 1
+	}
+	return code, n
 }
 
 func (s *Scanner) match(c rune) bool {
@@ -191,6 +527,7 @@ func (s *Scanner) match(c rune) bool {
 		return false
 	}
 	s.current += 1
+	s.col += 1
 	return true
 }
 
@@ -208,16 +545,45 @@ func (s *Scanner) peekNext() rune {
 	return s.source[s.current+1]
 }
 
+func isWhitespace(c rune) bool {
+	return c == ' ' || c == '\r' || c == '\t' || c == '\n'
+}
+
 func isAlpha(c rune) bool {
 	return unicode.IsLetter(c) || c == rune('_')
 }
 
 func isAlphaNumeric(c rune) bool {
-	return isAlpha(c) || isDigit(c)
+	return isAlpha(c) || unicode.IsDigit(c)
 }
 
+// isDigit accepts ASCII digits only; number literals never contain digits
+// from other scripts.
 func isDigit(c rune) bool {
-	return unicode.IsDigit(c)
+	return c >= '0' && c <= '9'
+}
+
+func isBinaryDigit(c rune) bool {
+	return c == '0' || c == '1'
+}
+
+func isOctalDigit(c rune) bool {
+	return c >= '0' && c <= '7'
+}
+
+func isHexDigit(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c rune) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	default:
+		return int(c - '0')
+	}
 }
 
 func (s *Scanner) advance() rune {
@@ -228,15 +594,40 @@ func (s *Scanner) advance() rune {
 }
 
 func (s *Scanner) addToken(t token.TokenType, lexeme string) {
+	s.addLiteralToken(t, lexeme, nil)
+}
+
+func (s *Scanner) addLiteralToken(t token.TokenType, lexeme string, literal interface{}) {
 	tok := token.Token{
-		Type:   t,
-		Lexeme: lexeme,
-		Line:   s.line,
-		Col:    s.col,
+		Type:    t,
+		Lexeme:  lexeme,
+		Literal: literal,
+		Line:    s.line,
+		Col:     s.col,
 	}
 	s.tokens = append(s.tokens, tok)
 }
 
+func (s *Scanner) addComment() {
+	if s.trivia {
+		s.addToken(token.COMMENT, string(s.source[s.start:s.current]))
+	}
+}
+
+// lastToken returns the last token scanned that isn't trivia.
+func (s *Scanner) lastToken() token.Token {
+	for n := len(s.tokens) - 1; n >= 0; n-- {
+		if !s.tokens[n].Type.IsTrivia() {
+			return s.tokens[n]
+		}
+	}
+	return token.Token{}
+}
+
+func (s *Scanner) errorAt(line int, col int, msg string) {
+	s.errors = append(s.errors, fmt.Sprintf("Ln %d, Col %d %s", line, col, msg))
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
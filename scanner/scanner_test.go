@@ -0,0 +1,309 @@
+package scanner
+
+import (
+	"Glox/token"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scan returns the tokens of source without the final EOF, and the errors.
+func scan(source string) ([]token.Token, []string) {
+	s := NewScanner(source)
+	tokens := s.ScanTokens()
+	return tokens[:len(tokens)-1], s.Errors()
+}
+
+func types(tokens []token.Token) []token.TokenType {
+	types := make([]token.TokenType, len(tokens))
+	for n, tok := range tokens {
+		types[n] = tok.Type
+	}
+	return types
+}
+
+func TestInterpolation(t *testing.T) {
+	tokens, errors := scan(`"a${x + "b${y}"}c"`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	want := []token.TokenType{
+		token.INTERPOLATION, token.IDENTIFIER, token.PLUS,
+		token.INTERPOLATION, token.IDENTIFIER, token.STRING,
+		token.STRING,
+	}
+	if got := types(tokens); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	literals := []interface{}{"a", nil, nil, "b", nil, "", "c"}
+	for n, tok := range tokens {
+		if literals[n] != nil && tok.Literal != literals[n] {
+			t.Errorf("token %d: got literal %q, want %q", n, tok.Literal, literals[n])
+		}
+	}
+}
+
+func TestInterpolationBraces(t *testing.T) {
+	// Braces inside the expression don't close the interpolation.
+	tokens, errors := scan(`"${ {"k": 1}["k"] }!"`)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	last := tokens[len(tokens)-1]
+	if last.Type != token.STRING || last.Literal != "!" {
+		t.Errorf("got last token %s, want the string segment \"!\"", last.ToString())
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"${}"`, "Ln 1, Col 4 Expect expression in string interpolation."},
+		{`"a ${ 1`, "Ln 1, Col 4 Unterminated string interpolation."},
+		{`"${ 1 } b`, "Ln 1, Col 9 Unterminated string."},
+	}
+	for _, test := range tests {
+		_, errors := scan(test.source)
+		if len(errors) == 0 || errors[0] != test.want {
+			t.Errorf("%q: got errors %q, want %q first", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"a\nb\tc\rd"`, "a\nb\tc\rd"},
+		{`"\\ \" \$ \0"`, "\\ \" $ \x00"},
+		{`"\${x}"`, "${x}"},
+		{`"\x41\x7e"`, "A~"},
+		{`"\u{e9}\u{1F600}\u{00041}"`, "é😀A"},
+		{`"\u{10FFFF}"`, "\U0010FFFF"},
+	}
+	for _, test := range tests {
+		tokens, errors := scan(test.source)
+		if len(errors) > 0 {
+			t.Errorf("%s: unexpected errors %q", test.source, errors)
+			continue
+		}
+		if len(tokens) != 1 || tokens[0].Literal != test.want || tokens[0].Lexeme != test.source {
+			t.Errorf("%s: got %v, want the string %q", test.source, tokens, test.want)
+		}
+	}
+}
+
+func TestEscapeErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`"\q"`, `Ln 1, Col 2 Invalid escape sequence: '\q'.`},
+		{`"ab \x4"`, `Ln 1, Col 5 Invalid escape sequence: '\x' must be followed by exactly two hex digits.`},
+		{`"\u41"`, `Ln 1, Col 2 Invalid escape sequence: expected '{' after '\u'.`},
+		{`"\u{}"`, `Ln 1, Col 2 Invalid escape sequence: '\u{' must be followed by one to six hex digits.`},
+		{`"\u{1234567}"`, `Ln 1, Col 2 Invalid escape sequence: expected '}' to close '\u{'.`},
+		{`"\u{D800}"`, `Ln 1, Col 2 Invalid escape sequence: U+D800 is not a valid Unicode code point.`},
+		{`"\u{110000}"`, `Ln 1, Col 2 Invalid escape sequence: U+110000 is not a valid Unicode code point.`},
+		{"\"a\\\nb\"", `Ln 1, Col 3 Invalid escape sequence: '\' at end of line.`},
+	}
+	for _, test := range tests {
+		_, errors := scan(test.source)
+		if len(errors) != 1 || errors[0] != test.want {
+			t.Errorf("%s: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []token.TokenType
+	}{
+		{"1 /* a */ + 2", []token.TokenType{token.NUMBER, token.PLUS, token.NUMBER}},
+		{"/* a /* b */ c */ x", []token.TokenType{token.IDENTIFIER}},
+		{"/* /* /* */ */ */ x", []token.TokenType{token.IDENTIFIER}},
+		{"/* a // b */ x", []token.TokenType{token.IDENTIFIER}},
+		{"/**/x/***/", []token.TokenType{token.IDENTIFIER}},
+		{`/* "*/ x`, []token.TokenType{token.IDENTIFIER}},
+		{"a */", []token.TokenType{token.IDENTIFIER, token.STAR, token.SLASH}},
+	}
+	for _, test := range tests {
+		tokens, errors := scan(test.source)
+		if len(errors) > 0 {
+			t.Errorf("%q: unexpected errors %q", test.source, errors)
+		}
+		if got := types(tokens); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.source, got, test.want)
+		}
+	}
+
+	// Lines inside a comment are counted.
+	tokens, _ := scan("/* a\n/* b\n*/\n*/ x")
+	if x := tokens[0]; x.Line != 4 || x.Col != 4 {
+		t.Errorf("got x at Ln %d, Col %d, want Ln 4, Col 4", x.Line, x.Col)
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"x /* a", "Ln 1, Col 3 Unterminated block comment."},
+		{"/* a\n/* b */\n", "Ln 1, Col 1 Unterminated block comment."},
+		{"/*/", "Ln 1, Col 1 Unterminated block comment."},
+	}
+	for _, test := range tests {
+		_, errors := scan(test.source)
+		if len(errors) != 1 || errors[0] != test.want {
+			t.Errorf("%q: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestRadixNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int64
+	}{
+		{"0x1F", 31},
+		{"0XfF", 255},
+		{"0b1010", 10},
+		{"0B1", 1},
+		{"0o17", 15},
+		{"0O777", 511},
+		{"0xdead_beef", 0xdeadbeef},
+		{"0b1111_0000", 240},
+		{"0x7FFF_FFFF_FFFF_FFFF", 1<<63 - 1},
+		{"1_000_000", 1000000},
+	}
+	for _, test := range tests {
+		tokens, errors := scan(test.source)
+		if len(errors) > 0 {
+			t.Errorf("%s: unexpected errors %q", test.source, errors)
+			continue
+		}
+		if len(tokens) != 1 || tokens[0].Literal != test.want || tokens[0].Lexeme != test.source {
+			t.Errorf("%s: got %v, want %d", test.source, tokens, test.want)
+		}
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"0x", "Ln 1, Col 1 Invalid hexadecimal literal: expected digits after '0x'."},
+		{"0b_", "Ln 1, Col 3 Invalid '_' in binary literal: separators must be placed between digits."},
+		{"0b102", "Ln 1, Col 5 Invalid digit '2' in binary literal."},
+		{"0o78", "Ln 1, Col 4 Invalid digit '8' in octal literal."},
+		{"0xfg", "Ln 1, Col 4 Invalid digit 'g' in hexadecimal literal."},
+		{"0x_1", "Ln 1, Col 3 Invalid '_' in hexadecimal literal: separators must be placed between digits."},
+		{"1__0", "Ln 1, Col 2 Invalid '_' in number literal: separators must be placed between digits."},
+		{"1_", "Ln 1, Col 2 Invalid '_' in number literal: separators must be placed between digits."},
+		{"1_.5", "Ln 1, Col 2 Invalid '_' in number literal: separators must be placed between digits."},
+		{"1e", "Ln 1, Col 2 Invalid number literal: exponent has no digits."},
+		{"1e+", "Ln 1, Col 3 Invalid number literal: exponent has no digits."},
+		{"12abc", "Ln 1, Col 3 Invalid digit 'a' in number literal."},
+		{"0x1_0000_0000_0000_0000", "Ln 1, Col 1 Number literal out of range."},
+		{"x = 9223372036854775808", "Ln 1, Col 5 Number literal out of range."},
+	}
+	for _, test := range tests {
+		_, errors := scan(test.source)
+		if len(errors) != 1 || errors[0] != test.want {
+			t.Errorf("%s: got errors %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestNumberKinds(t *testing.T) {
+	// Literals without a fraction or an exponent are integers.
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"0", int64(0)},
+		{"42", int64(42)},
+		{"9223372036854775807", int64(1<<63 - 1)},
+		{"9007199254740993", int64(9007199254740993)},
+		{"1.5", 1.5},
+		{"1.0", 1.0},
+		{"1e3", 1000.0},
+		{"2E-2", 0.02},
+		{"1_0.2_5e+0_1", 102.5},
+	}
+	for _, test := range tests {
+		tokens, errors := scan(test.source)
+		if len(errors) > 0 {
+			t.Errorf("%s: unexpected errors %q", test.source, errors)
+			continue
+		}
+		if len(tokens) != 1 || tokens[0].Literal != test.want {
+			t.Errorf("%s: got %#v, want %#v", test.source, tokens[0].Literal, test.want)
+		}
+	}
+
+	// A dot without digits after it isn't a fraction.
+	tokens, _ := scan("1.x")
+	if got := types(tokens); !reflect.DeepEqual(got, []token.TokenType{token.NUMBER, token.DOT, token.IDENTIFIER}) || tokens[0].Literal != int64(1) {
+		t.Errorf("1.x: got %v", tokens)
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	sources := []string{
+		"var a = 1; // one\n\n/* two\n  /* nested */ */\tprint a;\r\n",
+		"print \"a${ b /* c */ }d\";  \n",
+		"// only a comment",
+		"  \n\t",
+		"",
+	}
+	for _, source := range sources {
+		s := NewTriviaScanner(source)
+		tokens := s.ScanTokens()
+		if len(s.Errors()) > 0 {
+			t.Errorf("%q: unexpected errors %q", source, s.Errors())
+			continue
+		}
+		// The lexemes spell out the source.
+		var text strings.Builder
+		for _, tok := range tokens {
+			text.WriteString(tok.Lexeme)
+		}
+		if text.String() != source {
+			t.Errorf("%q: the lexemes spell %q", source, text.String())
+		}
+		// Without the trivia, the tokens are those of the usual scanner.
+		if got, want := WithoutTrivia(tokens), NewScanner(source).ScanTokens(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v without trivia, want %v", source, got, want)
+		}
+	}
+}
+
+func TestTriviaPositions(t *testing.T) {
+	s := NewTriviaScanner("a  // c\n/* d\ne */ b")
+	var got []string
+	for _, tok := range s.ScanTokens() {
+		got = append(got, fmt.Sprintf("%s %q %d:%d", tok.Type, tok.Lexeme, tok.Line, tok.Col))
+	}
+	// Like other tokens, trivia sits at its last character.
+	want := []string{
+		`IDENTIFIER "a" 1:1`,
+		`WHITESPACE "  " 1:3`,
+		`COMMENT "// c" 1:7`,
+		`WHITESPACE "\n" 1:8`,
+		`COMMENT "/* d\ne */" 3:4`,
+		`WHITESPACE " " 3:5`,
+		`IDENTIFIER "b" 3:6`,
+		`EOF "" 0:0`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
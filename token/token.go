@@ -10,13 +10,19 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
+	COLON
 	DOT
 	MINUS
 	PLUS
+	QUESTION
 	SEMICOLON
 	SLASH
 	STAR
+	BACKSLASH
+	PERCENT
 
 	// One or two character tokens
 	BANG
@@ -27,30 +33,56 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	ARROW
+	PLUS_EQUAL
+	MINUS_EQUAL
+	STAR_EQUAL
+	SLASH_EQUAL
+	PLUS_PLUS
+	MINUS_MINUS
 
 	// Literals.
 	IDENTIFIER
 	STRING
+	INTERPOLATION // string segment that ends in "${".
 	NUMBER
 
 	// keywords
 	AND
+	AS
+	BREAK
+	CASE
+	CATCH
 	CLASS
+	CONST
+	CONTINUE
+	DEFAULT
 	ELSE
+	EXPORT
 	FALSE
+	FINALLY
 	FUN
 	FOR
+	FROM
 	IF
+	IMPORT
+	MATCH
 	NIL
 	OR
 	PRINT
 	RETURN
 	SUPER
 	THIS
+	THROW
 	TRUE
+	TRY
 	VAR
 	WHILE
 
+	// Trivia, which the scanner only returns in trivia mode.
+	COMMENT
+	WHITESPACE // spaces, tabs and newlines.
+
 	EOF
 )
 
@@ -60,13 +92,19 @@ var tokenNames = []string{
 	"RIGHT_PAREN",
 	"LEFT_BRACE",
 	"RIGHT_BRACE",
+	"LEFT_BRACKET",
+	"RIGHT_BRACKET",
 	"COMMA",
+	"COLON",
 	"DOT",
 	"MINUS",
 	"PLUS",
+	"QUESTION",
 	"SEMICOLON",
 	"SLASH",
 	"STAR",
+	"BACKSLASH",
+	"PERCENT",
 
 	// One or two character tokens
 	"BANG",
@@ -77,39 +115,87 @@ var tokenNames = []string{
 	"GREATER_EQUAL",
 	"LESS",
 	"LESS_EQUAL",
+	"ARROW",
+	"PLUS_EQUAL",
+	"MINUS_EQUAL",
+	"STAR_EQUAL",
+	"SLASH_EQUAL",
+	"PLUS_PLUS",
+	"MINUS_MINUS",
 
 	// Literals.
 	"IDENTIFIER",
 	"STRING",
+	"INTERPOLATION",
 	"NUMBER",
 
 	// keywords
 	"AND",
+	"AS",
+	"BREAK",
+	"CASE",
+	"CATCH",
 	"CLASS",
+	"CONST",
+	"CONTINUE",
+	"DEFAULT",
 	"ELSE",
+	"EXPORT",
 	"FALSE",
+	"FINALLY",
 	"FUN",
 	"FOR",
+	"FROM",
 	"IF",
+	"IMPORT",
+	"MATCH",
 	"NIL",
 	"OR",
 	"PRINT",
 	"RETURN",
 	"SUPER",
 	"THIS",
+	"THROW",
 	"TRUE",
+	"TRY",
 	"VAR",
 	"WHILE",
 
+	"COMMENT",
+	"WHITESPACE",
+
 	"EOF",
 }
 
+func (t TokenType) String() string {
+	if int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return fmt.Sprintf("TokenType(%d)", uint(t))
+}
+
+// TypeNamed returns the token type whose String is name.
+func TypeNamed(name string) (TokenType, bool) {
+	for t, n := range tokenNames {
+		if n == name {
+			return TokenType(t), true
+		}
+	}
+	return 0, false
+}
+
+// IsTrivia reports whether t is a comment or whitespace.
+func (t TokenType) IsTrivia() bool {
+	return t == COMMENT || t == WHITESPACE
+}
+
 // token unit
 type Token struct {
-	Type   TokenType
-	Lexeme string
-	Line   int
-	Col    int
+	Type    TokenType
+	Lexeme  string
+	Literal interface{} // decoded value for literal tokens, nil otherwise.
+	Line    int
+	Col     int
 }
 
 func (t *Token) ToString() string {
@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"Glox/token"
+	"sort"
+)
+
+// OpCode is the first byte of every instruction. The comments list the
+// operands that follow it; u8 and u16 operands are unsigned and big endian.
+type OpCode byte
+
+const (
+	OpConstant     OpCode = iota // u16 constant
+	OpNil                        //
+	OpTrue                       //
+	OpFalse                      //
+	OpPop                        //
+	OpDup                        //
+	OpDupTwo                     // duplicates the top two values
+	OpBury                       // u8 depth: moves the top value depth slots down
+	OpGetLocal                   // u8 slot
+	OpSetLocal                   // u8 slot
+	OpGetUpvalue                 // u8 upvalue
+	OpSetUpvalue                 // u8 upvalue
+	OpDefineGlobal               // u16 name
+	OpDefineConst                // u16 name
+	OpGetGlobal                  // u16 name
+	OpSetGlobal                  // u16 name
+	OpGetProperty                // u16 name
+	OpSetProperty                // u16 name
+	OpHasProperty                // u16 name: is there a field or a getter
+	OpGetSuper                   // u16 name
+	OpGetIndex                   //
+	OpSetIndex                   //
+	OpSlice                      //
+	OpEqual                      //
+	OpNotEqual                   //
+	OpGreater                    //
+	OpGreaterEqual               //
+	OpLess                       //
+	OpLessEqual                  //
+	OpAdd                        //
+	OpSubtract                   //
+	OpMultiply                   //
+	OpDivide                     //
+	OpIntDivide                  //
+	OpModulo                     //
+	OpNot                        //
+	OpNegate                     //
+	OpIncrement                  // ++ or --, after the instruction's token
+	OpPrint                      //
+	OpJump                       // u16 forward offset
+	OpJumpIfFalse                // u16 forward offset, keeps the condition
+	OpLoop                       // u16 backward offset
+	OpCall                       // u8 argument count
+	OpClosure                    // u16 function, then u8 isLocal and u8 index per upvalue
+	OpCloseUpvalue               //
+	OpReturn                     //
+	OpClass                      // u16 name
+	OpInherit                    //
+	OpMethod                     // u16 name, u8 ast.FunctionKind
+	OpList                       // u16 element count
+	OpMap                        //
+	OpMapEntry                   //
+	OpInterpolate                // u16 part count
+	OpThrow                      //
+	OpTry                        // u8 handler kind, u16 forward offset to the handler
+	OpEndTry                     //
+	OpRethrow                    //
+	OpImport                     // u16 path
+	OpImportName                 // u16 name
+	OpExport                     // u16 name
+	OpMatchList                  // u16 length
+	OpMatchClass                 //
+	OpNoMatch                    //
+)
+
+// Handler kinds of OpTry.
+const (
+	catchHandler   = 0
+	finallyHandler = 1
+)
+
+// Chunk is the bytecode of one function along with its constant pool and
+// the table mapping instructions back to the source.
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	// Positions is sorted by Offset. Each entry covers the instructions up
+	// to the next one.
+	Positions []Position
+}
+
+// Position records the token an instruction was compiled from. Runtime
+// errors report it and stack traces use its line.
+type Position struct {
+	Offset int
+	Token  token.Token
+}
+
+func (c *Chunk) write(b byte, tok token.Token) {
+	if n := len(c.Positions); n == 0 || c.Positions[n-1].Token != tok {
+		c.Positions = append(c.Positions, Position{Offset: len(c.Code), Token: tok})
+	}
+	c.Code = append(c.Code, b)
+}
+
+// Token returns the token of the instruction that contains offset.
+func (c *Chunk) Token(offset int) token.Token {
+	n := sort.Search(len(c.Positions), func(n int) bool {
+		return c.Positions[n].Offset > offset
+	})
+	if n == 0 {
+		return token.Token{}
+	}
+	return c.Positions[n-1].Token
+}
@@ -0,0 +1,936 @@
+package vm
+
+import (
+	"Glox/ast"
+	"Glox/interpreter"
+	"Glox/token"
+	"fmt"
+	"strings"
+)
+
+// CompileError lists the reasons a program couldn't be compiled. They are
+// limits of the bytecode, such as the number of locals in a function; every
+// other error is reported by the parser and the resolver beforehand.
+type CompileError struct {
+	Errors []string
+}
+
+func (e *CompileError) Error() string {
+	return strings.Join(e.Errors, "\n")
+}
+
+type functionType int
+
+const (
+	typeScript functionType = iota
+	typeFunction
+	typeMethod
+	typeInitializer
+)
+
+type local struct {
+	name     string // "" for the hidden slots the compiler allocates.
+	depth    int
+	captured bool
+}
+
+type upvalue struct {
+	index   byte
+	isLocal bool
+}
+
+// loop tracks the jumps out of the loop being compiled. locals and
+// handlers are counted when the loop starts, so break and continue know
+// what to discard.
+type loop struct {
+	locals    int
+	handlers  int
+	breaks    []int
+	continues []int
+}
+
+// compiler compiles one function. Nested functions get a compiler of their
+// own that points back to the enclosing one to resolve upvalues.
+type compiler struct {
+	enclosing *compiler
+	function  *Function
+	kind      functionType
+	locals    []local
+	upvalues  []upvalue
+	depth     int
+	loops     []*loop
+	// handlers are the try handlers installed at the current point of the
+	// function, innermost last: the block of a finally handler or nil for a
+	// catch handler.
+	handlers  []*ast.BlockStmt
+	constants map[interface{}]int
+	token     token.Token // the position of the instructions being emitted.
+	errors    *[]string
+}
+
+// Compile translates resolved statements into a function taking no
+// arguments. name is what stack traces call it.
+func Compile(statements []ast.Statement, name string) (*Function, error) {
+	c := newCompiler(nil, typeScript, name)
+	c.statements(statements)
+	c.emitReturn()
+	if len(*c.errors) > 0 {
+		return nil, &CompileError{Errors: *c.errors}
+	}
+	return c.function, nil
+}
+
+func newCompiler(enclosing *compiler, kind functionType, name string) *compiler {
+	c := &compiler{
+		enclosing: enclosing,
+		function:  &Function{Name: name, Chunk: &Chunk{}},
+		kind:      kind,
+		constants: make(map[interface{}]int),
+	}
+	if enclosing != nil {
+		c.errors = enclosing.errors
+		c.token = enclosing.token
+	} else {
+		c.errors = &[]string{}
+	}
+	// Slot 0 holds the function being called, or the receiver of a method.
+	if kind == typeMethod || kind == typeInitializer {
+		c.locals = append(c.locals, local{name: "this"})
+	} else {
+		c.locals = append(c.locals, local{})
+	}
+	return c
+}
+
+func (c *compiler) error(msg string) {
+	*c.errors = append(*c.errors, fmt.Sprintf("Ln %d, Col %d %s", c.token.Line, c.token.Col, msg))
+}
+
+func (c *compiler) statements(statements []ast.Statement) {
+	for _, stmt := range statements {
+		stmt.Accept(c)
+	}
+}
+
+func (c *compiler) statement(stmt ast.Statement) {
+	stmt.Accept(c)
+}
+
+func (c *compiler) expression(expr ast.Expression) {
+	expr.Accept(c)
+}
+
+// Emitting bytecode
+
+func (c *compiler) chunk() *Chunk {
+	return c.function.Chunk
+}
+
+func (c *compiler) emit(op OpCode, operands ...byte) {
+	c.chunk().write(byte(op), c.token)
+	for _, b := range operands {
+		c.chunk().write(b, c.token)
+	}
+}
+
+// emitAt emits an instruction that reports tok when it fails.
+func (c *compiler) emitAt(tok token.Token, op OpCode, operands ...byte) {
+	c.token = tok
+	c.emit(op, operands...)
+}
+
+func short(n int) []byte {
+	return []byte{byte(n >> 8), byte(n)}
+}
+
+func (c *compiler) makeConstant(value interface{}) int {
+	switch value.(type) {
+	case string, int64, float64:
+		if n, ok := c.constants[value]; ok {
+			return n
+		}
+	}
+	if len(c.chunk().Constants) > 0xffff {
+		c.error("Too many constants in one chunk.")
+		return 0
+	}
+	n := len(c.chunk().Constants)
+	c.chunk().Constants = append(c.chunk().Constants, value)
+	switch value.(type) {
+	case string, int64, float64:
+		c.constants[value] = n
+	}
+	return n
+}
+
+func (c *compiler) identifier(name token.Token) []byte {
+	return short(c.makeConstant(name.Lexeme))
+}
+
+// emitJump emits a jump with a placeholder offset and returns where the
+// offset goes.
+func (c *compiler) emitJump(op OpCode) int {
+	c.emit(op, 0xff, 0xff)
+	return len(c.chunk().Code) - 2
+}
+
+// patchJump points the jump whose offset is at offset to the next
+// instruction.
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > 0xffff {
+		c.error("Too much code to jump over.")
+	}
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *compiler) emitLoop(start int) {
+	offset := len(c.chunk().Code) - start + 3
+	if offset > 0xffff {
+		c.error("Loop body too large.")
+	}
+	c.emit(OpLoop, short(offset)...)
+}
+
+func (c *compiler) emitReturn() {
+	if c.kind == typeInitializer {
+		// init always returns the instance, even after a bare return.
+		c.emit(OpGetLocal, 0)
+	} else {
+		c.emit(OpNil)
+	}
+	c.emit(OpReturn)
+}
+
+// Scopes and variables
+
+func (c *compiler) beginScope() {
+	c.depth++
+}
+
+func (c *compiler) endScope() {
+	c.depth--
+	n := len(c.locals)
+	for n > 0 && c.locals[n-1].depth > c.depth {
+		n--
+	}
+	c.popLocals(c.locals[n:])
+	c.locals = c.locals[:n]
+}
+
+// popLocals discards the slots of locals from the stack, closing the ones
+// closures captured. It leaves the compiler's bookkeeping alone.
+func (c *compiler) popLocals(locals []local) {
+	for n := len(locals) - 1; n >= 0; n-- {
+		if locals[n].captured {
+			c.emit(OpCloseUpvalue)
+		} else {
+			c.emit(OpPop)
+		}
+	}
+}
+
+// addLocal names the slot of the value on top of the stack.
+func (c *compiler) addLocal(name string) byte {
+	if len(c.locals) > 0xff {
+		c.error("Too many local variables in function.")
+		return 0
+	}
+	c.locals = append(c.locals, local{name: name, depth: c.depth})
+	return byte(len(c.locals) - 1)
+}
+
+func (c *compiler) resolveLocal(name string) int {
+	for n := len(c.locals) - 1; n >= 0; n-- {
+		if c.locals[n].name == name {
+			return n
+		}
+	}
+	return -1
+}
+
+// scopeLocal finds name among the locals of the innermost scope.
+func (c *compiler) scopeLocal(name string) int {
+	for n := len(c.locals) - 1; n >= 0 && c.locals[n].depth == c.depth; n-- {
+		if c.locals[n].name == name {
+			return n
+		}
+	}
+	return -1
+}
+
+func (c *compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+	if n := c.enclosing.resolveLocal(name); n >= 0 {
+		c.enclosing.locals[n].captured = true
+		return c.addUpvalue(byte(n), true)
+	}
+	if n := c.enclosing.resolveUpvalue(name); n >= 0 {
+		return c.addUpvalue(byte(n), false)
+	}
+	return -1
+}
+
+func (c *compiler) addUpvalue(index byte, isLocal bool) int {
+	for n, u := range c.upvalues {
+		if u.index == index && u.isLocal == isLocal {
+			return n
+		}
+	}
+	if len(c.upvalues) > 0xff {
+		c.error("Too many closure variables in function.")
+		return 0
+	}
+	c.upvalues = append(c.upvalues, upvalue{index: index, isLocal: isLocal})
+	return len(c.upvalues) - 1
+}
+
+// getVariable loads a local, a captured variable or, failing both, a global
+// looked up by name when the instruction runs.
+func (c *compiler) getVariable(name token.Token) {
+	if n := c.resolveLocal(name.Lexeme); n >= 0 {
+		c.emitAt(name, OpGetLocal, byte(n))
+	} else if n := c.resolveUpvalue(name.Lexeme); n >= 0 {
+		c.emitAt(name, OpGetUpvalue, byte(n))
+	} else {
+		c.emitAt(name, OpGetGlobal, c.identifier(name)...)
+	}
+}
+
+// setVariable stores the value on top of the stack, leaving it there.
+func (c *compiler) setVariable(name token.Token) {
+	if n := c.resolveLocal(name.Lexeme); n >= 0 {
+		c.emitAt(name, OpSetLocal, byte(n))
+	} else if n := c.resolveUpvalue(name.Lexeme); n >= 0 {
+		c.emitAt(name, OpSetUpvalue, byte(n))
+	} else {
+		c.emitAt(name, OpSetGlobal, c.identifier(name)...)
+	}
+}
+
+// defineVariable declares name with the value on top of the stack. At the
+// top level it becomes a global, elsewhere the value's slot becomes a local.
+// Declaring a name again in the same scope reuses its slot, like the
+// interpreter redefines it in the same environment.
+func (c *compiler) defineVariable(name token.Token, isConst bool) {
+	if c.kind == typeScript && c.depth == 0 {
+		if isConst {
+			c.emitAt(name, OpDefineConst, c.identifier(name)...)
+		} else {
+			c.emitAt(name, OpDefineGlobal, c.identifier(name)...)
+		}
+		return
+	}
+	if n := c.scopeLocal(name.Lexeme); n >= 0 {
+		c.emitAt(name, OpSetLocal, byte(n))
+		c.emit(OpPop)
+		return
+	}
+	c.addLocal(name.Lexeme)
+}
+
+// declareLocal reserves the slot of a local that is about to be pushed, so
+// the value can refer to itself, and reports whether it did. Globals and
+// redeclared locals are stored by defineVariable instead.
+func (c *compiler) declareLocal(name token.Token) bool {
+	if (c.kind == typeScript && c.depth == 0) || c.scopeLocal(name.Lexeme) >= 0 {
+		return false
+	}
+	c.addLocal(name.Lexeme)
+	return true
+}
+
+// closure compiles a function body and emits the closure creating it.
+func (c *compiler) closure(name string, params []token.Token, body []ast.Statement, kind functionType, at token.Token) {
+	f := newCompiler(c, kind, name)
+	f.token = at
+	f.beginScope()
+	for _, param := range params {
+		f.addLocal(param.Lexeme)
+	}
+	f.function.Arity = len(params)
+	f.statements(body)
+	f.emitReturn()
+	f.function.UpvalueCount = len(f.upvalues)
+
+	operands := short(c.makeConstant(f.function))
+	for _, u := range f.upvalues {
+		isLocal := byte(0)
+		if u.isLocal {
+			isLocal = 1
+		}
+		operands = append(operands, isLocal, u.index)
+	}
+	c.emitAt(at, OpClosure, operands...)
+}
+
+// exitHandlers uninstalls the try handlers above the first count ones, for
+// a jump out of their try statements, running the finally blocks on the
+// way.
+func (c *compiler) exitHandlers(count int) {
+	handlers := c.handlers
+	for n := len(handlers) - 1; n >= count; n-- {
+		c.emit(OpEndTry)
+		if handlers[n] != nil {
+			c.handlers = handlers[:n]
+			c.block(handlers[n].Statements)
+		}
+	}
+	c.handlers = handlers
+}
+
+// exitLoop prepares a break or continue: it leaves the try statements and
+// the scopes inside the innermost loop.
+func (c *compiler) exitLoop(keyword token.Token) *loop {
+	l := c.loops[len(c.loops)-1]
+	c.token = keyword
+	c.exitHandlers(l.handlers)
+	c.popLocals(c.locals[l.locals:])
+	return l
+}
+
+func (c *compiler) block(statements []ast.Statement) {
+	c.beginScope()
+	c.statements(statements)
+	c.endScope()
+}
+
+// Statements
+
+func (c *compiler) VisitBlockStmt(stmt *ast.BlockStmt) interface{} {
+	c.block(stmt.Statements)
+	return nil
+}
+
+func (c *compiler) VisitBreakStmt(stmt *ast.BreakStmt) interface{} {
+	l := c.exitLoop(stmt.Keyword)
+	l.breaks = append(l.breaks, c.emitJump(OpJump))
+	return nil
+}
+
+func (c *compiler) VisitContinueStmt(stmt *ast.ContinueStmt) interface{} {
+	l := c.exitLoop(stmt.Keyword)
+	l.continues = append(l.continues, c.emitJump(OpJump))
+	return nil
+}
+
+// VisitClassStmt evaluates the superclass before the class name is
+// declared, like the interpreter, so class A < A finds an outer A. Methods
+// reach the superclass through a local named super that they capture.
+func (c *compiler) VisitClassStmt(stmt *ast.ClassStmt) interface{} {
+	if stmt.Superclass == nil {
+		local := c.declareLocal(stmt.Name)
+		c.emitAt(stmt.Name, OpClass, c.identifier(stmt.Name)...)
+		if !local {
+			c.defineVariable(stmt.Name, false)
+		}
+	} else {
+		slot := -1
+		if !(c.kind == typeScript && c.depth == 0) {
+			if slot = c.scopeLocal(stmt.Name.Lexeme); slot < 0 {
+				// Reserve the slot now and name it once the superclass
+				// has been evaluated.
+				c.emitAt(stmt.Name, OpNil)
+				slot = int(c.addLocal(""))
+			}
+		}
+		c.getVariable(stmt.Superclass.Name)
+		c.emitAt(stmt.Name, OpClass, c.identifier(stmt.Name)...)
+		c.emitAt(stmt.Superclass.Name, OpInherit)
+		if slot >= 0 {
+			c.locals[slot].name = stmt.Name.Lexeme
+			c.emitAt(stmt.Name, OpSetLocal, byte(slot))
+			c.emit(OpPop)
+		} else {
+			c.defineVariable(stmt.Name, false)
+		}
+		// The superclass is left on the stack.
+		c.beginScope()
+		c.addLocal("super")
+	}
+
+	c.getVariable(stmt.Name)
+	for _, method := range stmt.Methods {
+		method := method.(*ast.FunStmt)
+		kind := typeMethod
+		if method.Kind == ast.Method && method.Name.Lexeme == "init" {
+			kind = typeInitializer
+		}
+		c.closure(method.Name.Lexeme, method.Params, method.Body, kind, method.Name)
+		c.emitAt(method.Name, OpMethod, append(c.identifier(method.Name), byte(method.Kind))...)
+	}
+	c.emit(OpPop)
+	if stmt.Superclass != nil {
+		c.endScope()
+	}
+	return nil
+}
+
+func (c *compiler) VisitExportStmt(stmt *ast.ExportStmt) interface{} {
+	c.statement(stmt.Declaration)
+	var name token.Token
+	switch declaration := stmt.Declaration.(type) {
+	case *ast.VarStmt:
+		name = declaration.Name
+	case *ast.FunStmt:
+		name = declaration.Name
+	case *ast.ClassStmt:
+		name = declaration.Name
+	default:
+		return nil
+	}
+	c.emitAt(stmt.Keyword, OpExport, c.identifier(name)...)
+	return nil
+}
+
+func (c *compiler) VisitExpressionStmt(stmt *ast.ExpressionStmt) interface{} {
+	c.expression(stmt.Expression)
+	c.emit(OpPop)
+	return nil
+}
+
+func (c *compiler) VisitFunctionStmt(stmt *ast.FunStmt) interface{} {
+	local := c.declareLocal(stmt.Name)
+	c.closure(stmt.Name.Lexeme, stmt.Params, stmt.Body, typeFunction, stmt.Name)
+	if !local {
+		c.defineVariable(stmt.Name, false)
+	}
+	return nil
+}
+
+func (c *compiler) VisitIfStmt(stmt *ast.IfStmt) interface{} {
+	c.expression(stmt.Condition)
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emit(OpPop)
+	c.statement(stmt.ThenBranch)
+	elseJump := c.emitJump(OpJump)
+	c.patchJump(thenJump)
+	c.emit(OpPop)
+	if stmt.ElseBranch != nil {
+		c.statement(stmt.ElseBranch)
+	}
+	c.patchJump(elseJump)
+	return nil
+}
+
+// VisitImportStmt binds the module, or the names imported from it, as
+// globals. Imports only appear at the top level of a file.
+func (c *compiler) VisitImportStmt(stmt *ast.ImportStmt) interface{} {
+	c.emitAt(stmt.Path, OpImport, short(c.makeConstant(stmt.Path.Literal))...)
+	if stmt.Names == nil {
+		c.defineVariable(stmt.Alias, false)
+		return nil
+	}
+	for _, name := range stmt.Names {
+		c.emitAt(name, OpImportName, c.identifier(name)...)
+		c.defineVariable(name, false)
+	}
+	c.emit(OpPop)
+	return nil
+}
+
+func (c *compiler) VisitPrintStmt(stmt *ast.PrintStmt) interface{} {
+	c.expression(stmt.Expression)
+	c.emit(OpPrint)
+	return nil
+}
+
+// VisitReturnStmt runs the finally blocks of the enclosing try statements
+// before returning. The value is kept in a hidden local meanwhile.
+func (c *compiler) VisitReturnStmt(stmt *ast.ReturnStmt) interface{} {
+	c.token = stmt.Keyword
+	if c.kind == typeInitializer {
+		if stmt.Value != nil {
+			c.expression(stmt.Value)
+			c.emit(OpPop)
+		}
+		c.emitAt(stmt.Keyword, OpGetLocal, 0)
+	} else if stmt.Value != nil {
+		c.expression(stmt.Value)
+	} else {
+		c.emit(OpNil)
+	}
+	if len(c.handlers) == 0 {
+		c.emitAt(stmt.Keyword, OpReturn)
+		return nil
+	}
+	c.beginScope()
+	slot := c.addLocal("")
+	c.exitHandlers(0)
+	c.emitAt(stmt.Keyword, OpGetLocal, slot)
+	c.emit(OpReturn)
+	// The slot is gone once the function returns; nothing to pop.
+	c.depth--
+	c.locals = c.locals[:slot]
+	return nil
+}
+
+func (c *compiler) VisitThrowStmt(stmt *ast.ThrowStmt) interface{} {
+	c.expression(stmt.Value)
+	c.emitAt(stmt.Keyword, OpThrow)
+	return nil
+}
+
+// VisitTryStmt installs a handler for the finally block, if any, and one
+// for the catch clause around the body. A handler receives the error: the
+// catch clause binds it to its parameter, the finally block raises it again
+// once it is done. Jumps out of the statement run the finally block
+// themselves, see exitHandlers.
+func (c *compiler) VisitTryStmt(stmt *ast.TryStmt) interface{} {
+	var finallyTry int
+	if stmt.Finally != nil {
+		finallyTry = c.emitTry(finallyHandler)
+		c.handlers = append(c.handlers, stmt.Finally)
+	}
+	if stmt.Catch != nil {
+		catchTry := c.emitTry(catchHandler)
+		c.handlers = append(c.handlers, nil)
+		c.block(stmt.Body)
+		c.handlers = c.handlers[:len(c.handlers)-1]
+		c.emit(OpEndTry)
+		end := c.emitJump(OpJump)
+
+		c.patchJump(catchTry)
+		c.beginScope()
+		c.addLocal(stmt.Param.Lexeme)
+		c.statements(stmt.Catch.Statements)
+		c.endScope()
+		c.patchJump(end)
+	} else {
+		c.block(stmt.Body)
+	}
+	if stmt.Finally != nil {
+		c.handlers = c.handlers[:len(c.handlers)-1]
+		c.emit(OpEndTry)
+		c.block(stmt.Finally.Statements)
+		end := c.emitJump(OpJump)
+
+		c.patchJump(finallyTry)
+		c.beginScope()
+		slot := c.addLocal("")
+		c.statements(stmt.Finally.Statements)
+		c.emit(OpGetLocal, slot)
+		c.emit(OpRethrow)
+		c.endScope()
+		c.patchJump(end)
+	}
+	return nil
+}
+
+func (c *compiler) emitTry(kind byte) int {
+	c.emit(OpTry, kind, 0xff, 0xff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *compiler) VisitVarStmt(stmt *ast.VarStmt) interface{} {
+	if stmt.Initializer != nil {
+		c.expression(stmt.Initializer)
+	} else {
+		c.emitAt(stmt.Name, OpNil)
+	}
+	c.defineVariable(stmt.Name, stmt.Const)
+	return nil
+}
+
+// VisitWhileStmt compiles the condition, the body and the increment of a
+// desugared for loop. continue jumps to the increment.
+func (c *compiler) VisitWhileStmt(stmt *ast.WhileStmt) interface{} {
+	start := len(c.chunk().Code)
+	c.expression(stmt.Condition)
+	exit := c.emitJump(OpJumpIfFalse)
+	c.emit(OpPop)
+
+	l := &loop{locals: len(c.locals), handlers: len(c.handlers)}
+	c.loops = append(c.loops, l)
+	c.statement(stmt.Body)
+	c.loops = c.loops[:len(c.loops)-1]
+
+	for _, jump := range l.continues {
+		c.patchJump(jump)
+	}
+	if stmt.Increment != nil {
+		c.expression(stmt.Increment)
+		c.emit(OpPop)
+	}
+	c.emitLoop(start)
+	c.patchJump(exit)
+	c.emit(OpPop)
+	for _, jump := range l.breaks {
+		c.patchJump(jump)
+	}
+	return nil
+}
+
+// Expressions
+
+func (c *compiler) VisitAssignExpr(expr *ast.Assign) interface{} {
+	c.expression(expr.Value)
+	c.setVariable(expr.Name)
+	return nil
+}
+
+var binaryOps = map[token.TokenType]OpCode{
+	token.PLUS:          OpAdd,
+	token.MINUS:         OpSubtract,
+	token.STAR:          OpMultiply,
+	token.SLASH:         OpDivide,
+	token.BACKSLASH:     OpIntDivide,
+	token.PERCENT:       OpModulo,
+	token.EQUAL_EQUAL:   OpEqual,
+	token.BANG_EQUAL:    OpNotEqual,
+	token.GREATER:       OpGreater,
+	token.GREATER_EQUAL: OpGreaterEqual,
+	token.LESS:          OpLess,
+	token.LESS_EQUAL:    OpLessEqual,
+}
+
+func (c *compiler) VisitBinaryExpr(expr *ast.Binary) interface{} {
+	c.expression(expr.Left)
+	c.expression(expr.Right)
+	c.emitAt(expr.Operator, binaryOps[expr.Operator.Type])
+	return nil
+}
+
+func (c *compiler) VisitCallExpr(expr *ast.Call) interface{} {
+	c.expression(expr.Callee)
+	for _, argument := range expr.Arguments {
+		c.expression(argument)
+	}
+	c.emitAt(expr.Paren, OpCall, byte(len(expr.Arguments)))
+	return nil
+}
+
+func (c *compiler) VisitCommaExpr(expr *ast.Comma) interface{} {
+	c.expression(expr.Left)
+	c.emit(OpPop)
+	c.expression(expr.Right)
+	return nil
+}
+
+// VisitCompoundAssignExpr evaluates the object and index of the target
+// once, duplicating them for the read and the write.
+func (c *compiler) VisitCompoundAssignExpr(expr *ast.CompoundAssign) interface{} {
+	operator := interpreter.BinaryOperator(expr.Operator)
+	switch target := expr.Target.(type) {
+	case *ast.Variable:
+		c.getVariable(target.Name)
+		c.expression(expr.Value)
+		c.emitAt(operator, binaryOps[operator.Type])
+		c.setVariable(target.Name)
+	case *ast.Get:
+		c.expression(target.Object)
+		c.emit(OpDup)
+		c.emitAt(target.Name, OpGetProperty, c.identifier(target.Name)...)
+		c.expression(expr.Value)
+		c.emitAt(operator, binaryOps[operator.Type])
+		c.emitAt(target.Name, OpSetProperty, c.identifier(target.Name)...)
+	case *ast.Index:
+		c.expression(target.Object)
+		c.expression(target.Index)
+		c.emit(OpDupTwo)
+		c.emitAt(target.Bracket, OpGetIndex)
+		c.expression(expr.Value)
+		c.emitAt(operator, binaryOps[operator.Type])
+		c.emitAt(target.Bracket, OpSetIndex)
+	}
+	return nil
+}
+
+// VisitIncrementExpr leaves the old value under the target's object and
+// index for a postfix increment, so it is what remains once the new one
+// has been stored.
+func (c *compiler) VisitIncrementExpr(expr *ast.Increment) interface{} {
+	switch target := expr.Target.(type) {
+	case *ast.Variable:
+		c.getVariable(target.Name)
+		if !expr.Prefix {
+			c.emit(OpDup)
+		}
+		c.emitAt(expr.Operator, OpIncrement)
+		c.setVariable(target.Name)
+	case *ast.Get:
+		c.expression(target.Object)
+		c.emit(OpDup)
+		c.emitAt(target.Name, OpGetProperty, c.identifier(target.Name)...)
+		if !expr.Prefix {
+			c.emit(OpDup)
+			c.emit(OpBury, 2)
+		}
+		c.emitAt(expr.Operator, OpIncrement)
+		c.emitAt(target.Name, OpSetProperty, c.identifier(target.Name)...)
+	case *ast.Index:
+		c.expression(target.Object)
+		c.expression(target.Index)
+		c.emit(OpDupTwo)
+		c.emitAt(target.Bracket, OpGetIndex)
+		if !expr.Prefix {
+			c.emit(OpDup)
+			c.emit(OpBury, 3)
+		}
+		c.emitAt(expr.Operator, OpIncrement)
+		c.emitAt(target.Bracket, OpSetIndex)
+	}
+	if !expr.Prefix {
+		c.emit(OpPop)
+	}
+	return nil
+}
+
+func (c *compiler) VisitConditionalExpr(expr *ast.Conditional) interface{} {
+	c.expression(expr.Condition)
+	elseJump := c.emitJump(OpJumpIfFalse)
+	c.emit(OpPop)
+	c.expression(expr.Then)
+	endJump := c.emitJump(OpJump)
+	c.patchJump(elseJump)
+	c.emit(OpPop)
+	c.expression(expr.Else)
+	c.patchJump(endJump)
+	return nil
+}
+
+func (c *compiler) VisitGetExpr(expr *ast.Get) interface{} {
+	c.expression(expr.Object)
+	c.emitAt(expr.Name, OpGetProperty, c.identifier(expr.Name)...)
+	return nil
+}
+
+func (c *compiler) VisitGroupingExpr(expr *ast.Grouping) interface{} {
+	c.expression(expr.Expression)
+	return nil
+}
+
+func (c *compiler) VisitIndexExpr(expr *ast.Index) interface{} {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	c.emitAt(expr.Bracket, OpGetIndex)
+	return nil
+}
+
+func (c *compiler) VisitInterpolationExpr(expr *ast.Interpolation) interface{} {
+	for _, part := range expr.Parts {
+		c.expression(part)
+	}
+	c.emit(OpInterpolate, short(len(expr.Parts))...)
+	return nil
+}
+
+func (c *compiler) VisitLambdaExpr(expr *ast.Lambda) interface{} {
+	name := expr.Name.Lexeme
+	if name == "" {
+		name = "<lambda>"
+	}
+	c.closure(name, expr.Params, expr.Body, typeFunction, expr.Keyword)
+	return nil
+}
+
+func (c *compiler) VisitListExpr(expr *ast.List) interface{} {
+	for _, element := range expr.Elements {
+		c.expression(element)
+	}
+	c.emitAt(expr.Bracket, OpList, short(len(expr.Elements))...)
+	return nil
+}
+
+func (c *compiler) VisitLiteralExpr(expr *ast.Literal) interface{} {
+	c.token = expr.Token
+	switch expr.Value {
+	case nil:
+		c.emit(OpNil)
+	case true:
+		c.emit(OpTrue)
+	case false:
+		c.emit(OpFalse)
+	default:
+		c.emit(OpConstant, short(c.makeConstant(expr.Value))...)
+	}
+	return nil
+}
+
+func (c *compiler) VisitLogicalExpr(expr *ast.Logical) interface{} {
+	c.expression(expr.Left)
+	if expr.Operator.Type == token.OR {
+		elseJump := c.emitJump(OpJumpIfFalse)
+		endJump := c.emitJump(OpJump)
+		c.patchJump(elseJump)
+		c.emit(OpPop)
+		c.expression(expr.Right)
+		c.patchJump(endJump)
+		return nil
+	}
+	endJump := c.emitJump(OpJumpIfFalse)
+	c.emit(OpPop)
+	c.expression(expr.Right)
+	c.patchJump(endJump)
+	return nil
+}
+
+// VisitMapExpr adds the entries one at a time, so a bad key fails before
+// the following entries are evaluated.
+func (c *compiler) VisitMapExpr(expr *ast.Map) interface{} {
+	c.emitAt(expr.Brace, OpMap)
+	for n, key := range expr.Keys {
+		c.expression(key)
+		c.expression(expr.Values[n])
+		c.emitAt(expr.Brace, OpMapEntry)
+	}
+	return nil
+}
+
+func (c *compiler) VisitSetExpr(expr *ast.Set) interface{} {
+	c.expression(expr.Object)
+	c.expression(expr.Value)
+	c.emitAt(expr.Name, OpSetProperty, c.identifier(expr.Name)...)
+	return nil
+}
+
+func (c *compiler) VisitSetIndexExpr(expr *ast.SetIndex) interface{} {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	c.expression(expr.Value)
+	c.emitAt(expr.Bracket, OpSetIndex)
+	return nil
+}
+
+func (c *compiler) VisitSliceExpr(expr *ast.Slice) interface{} {
+	c.expression(expr.Object)
+	for _, bound := range []ast.Expression{expr.Start, expr.End} {
+		if bound != nil {
+			c.expression(bound)
+		} else {
+			c.emitAt(expr.Bracket, OpNil)
+		}
+	}
+	c.emitAt(expr.Bracket, OpSlice)
+	return nil
+}
+
+func (c *compiler) VisitSuperExpr(expr *ast.Super) interface{} {
+	c.getVariable(expr.Keyword)
+	c.getVariable(token.Token{Type: token.THIS, Lexeme: "this", Line: expr.Keyword.Line, Col: expr.Keyword.Col})
+	c.emitAt(expr.Method, OpGetSuper, c.identifier(expr.Method)...)
+	return nil
+}
+
+func (c *compiler) VisitThisExpr(expr *ast.This) interface{} {
+	c.getVariable(expr.Keyword)
+	return nil
+}
+
+func (c *compiler) VisitUnaryExpr(expr *ast.Unary) interface{} {
+	c.expression(expr.Right)
+	if expr.Operator.Type == token.BANG {
+		c.emitAt(expr.Operator, OpNot)
+	} else {
+		c.emitAt(expr.Operator, OpNegate)
+	}
+	return nil
+}
+
+func (c *compiler) VisitVariableExpr(expr *ast.Variable) interface{} {
+	c.getVariable(expr.Name)
+	return nil
+}
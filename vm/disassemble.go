@@ -0,0 +1,171 @@
+package vm
+
+import (
+	"Glox/interpreter"
+	"fmt"
+	"io"
+)
+
+var opNames = []string{
+	"OpConstant",
+	"OpNil",
+	"OpTrue",
+	"OpFalse",
+	"OpPop",
+	"OpDup",
+	"OpDupTwo",
+	"OpBury",
+	"OpGetLocal",
+	"OpSetLocal",
+	"OpGetUpvalue",
+	"OpSetUpvalue",
+	"OpDefineGlobal",
+	"OpDefineConst",
+	"OpGetGlobal",
+	"OpSetGlobal",
+	"OpGetProperty",
+	"OpSetProperty",
+	"OpHasProperty",
+	"OpGetSuper",
+	"OpGetIndex",
+	"OpSetIndex",
+	"OpSlice",
+	"OpEqual",
+	"OpNotEqual",
+	"OpGreater",
+	"OpGreaterEqual",
+	"OpLess",
+	"OpLessEqual",
+	"OpAdd",
+	"OpSubtract",
+	"OpMultiply",
+	"OpDivide",
+	"OpIntDivide",
+	"OpModulo",
+	"OpNot",
+	"OpNegate",
+	"OpIncrement",
+	"OpPrint",
+	"OpJump",
+	"OpJumpIfFalse",
+	"OpLoop",
+	"OpCall",
+	"OpClosure",
+	"OpCloseUpvalue",
+	"OpReturn",
+	"OpClass",
+	"OpInherit",
+	"OpMethod",
+	"OpList",
+	"OpMap",
+	"OpMapEntry",
+	"OpInterpolate",
+	"OpThrow",
+	"OpTry",
+	"OpEndTry",
+	"OpRethrow",
+	"OpImport",
+	"OpImportName",
+	"OpExport",
+	"OpMatchList",
+	"OpMatchClass",
+	"OpNoMatch",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("OpCode(%d)", byte(op))
+}
+
+// Disassemble writes a listing of the bytecode of f, followed by the
+// listings of the functions declared in it. Each line shows the offset of
+// an instruction, its source line, or '|' when that is the line of the
+// previous instruction, the opcode and its operands.
+func Disassemble(w io.Writer, f *Function) {
+	fmt.Fprintf(w, "== %s ==\n", f.Name)
+	chunk := f.Chunk
+	for offset := 0; offset < len(chunk.Code); {
+		offset = disassembleInstruction(w, chunk, offset)
+	}
+	for _, constant := range chunk.Constants {
+		if nested, ok := constant.(*Function); ok {
+			fmt.Fprintln(w)
+			Disassemble(w, nested)
+		}
+	}
+}
+
+// disassembleInstruction writes the instruction at offset and returns the
+// offset of the next one.
+func disassembleInstruction(w io.Writer, chunk *Chunk, offset int) int {
+	line := chunk.Token(offset).Line
+	if offset > 0 && line == chunk.Token(offset-1).Line {
+		fmt.Fprintf(w, "%04d    | ", offset)
+	} else {
+		fmt.Fprintf(w, "%04d %4d ", offset, line)
+	}
+
+	code := chunk.Code
+	op := OpCode(code[offset])
+	u8 := func(n int) int { return int(code[offset+n]) }
+	u16 := func(n int) int { return int(code[offset+n])<<8 | int(code[offset+n+1]) }
+	switch op {
+	case OpConstant, OpDefineGlobal, OpDefineConst, OpGetGlobal, OpSetGlobal,
+		OpGetProperty, OpSetProperty, OpHasProperty, OpGetSuper, OpClass,
+		OpImport, OpImportName, OpExport:
+		n := u16(1)
+		fmt.Fprintf(w, "%-16s %4d %s\n", op, n, constantString(chunk.Constants[n]))
+		return offset + 3
+	case OpBury, OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		fmt.Fprintf(w, "%-16s %4d\n", op, u8(1))
+		return offset + 2
+	case OpList, OpInterpolate, OpMatchList:
+		fmt.Fprintf(w, "%-16s %4d\n", op, u16(1))
+		return offset + 3
+	case OpJump, OpJumpIfFalse:
+		fmt.Fprintf(w, "%-16s %4d -> %04d\n", op, offset, offset+3+u16(1))
+		return offset + 3
+	case OpLoop:
+		fmt.Fprintf(w, "%-16s %4d -> %04d\n", op, offset, offset+3-u16(1))
+		return offset + 3
+	case OpTry:
+		kind := "catch"
+		if u8(1) == finallyHandler {
+			kind = "finally"
+		}
+		fmt.Fprintf(w, "%-16s %4s -> %04d\n", op, kind, offset+4+u16(2))
+		return offset + 4
+	case OpMethod:
+		n := u16(1)
+		fmt.Fprintf(w, "%-16s %4d %s (kind %d)\n", op, n, constantString(chunk.Constants[n]), u8(3))
+		return offset + 4
+	case OpClosure:
+		n := u16(1)
+		function := chunk.Constants[n].(*Function)
+		fmt.Fprintf(w, "%-16s %4d %s\n", op, n, function)
+		offset += 3
+		for i := 0; i < function.UpvalueCount; i++ {
+			kind := "upvalue"
+			if code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(w, "%04d    |   %-14s %4d\n", offset, kind, code[offset+1])
+			offset += 2
+		}
+		return offset
+	default:
+		fmt.Fprintf(w, "%s\n", op)
+		return offset + 1
+	}
+}
+
+// constantString shows a constant the way it is written in source, so
+// strings are quoted.
+func constantString(constant interface{}) string {
+	if function, ok := constant.(*Function); ok {
+		return function.String()
+	}
+	return interpreter.Inspect(constant)
+}
@@ -0,0 +1,256 @@
+package vm
+
+import (
+	"Glox/token"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// A .loxc file holds a compiled script:
+//
+//	magic    "LOXC"
+//	version  u16, big endian
+//	checksum u32, big endian: CRC-32 (IEEE) of the body
+//	body     the script's function
+//
+// A function is its name, arity, upvalue count, code, constant pool and
+// position table. Integers in the body are unsigned varints, and strings
+// are a length followed by their bytes. Each constant, and the literal of
+// each position token, starts with a tag byte naming its type.
+//
+// Version is bumped whenever the format or the instruction set changes, so
+// files compiled by another version of glox are rejected instead of
+// misbehaving.
+const (
+	loxcMagic   = "LOXC"
+	LoxcVersion = 1
+)
+
+// Constant and literal tags.
+const (
+	tagNil byte = iota
+	tagInt
+	tagFloat
+	tagString
+	tagFunction
+)
+
+var ErrNotLoxc = errors.New("not a compiled Glox file")
+
+// ErrMalformed is the error, possibly wrapped, for a .loxc file that is
+// cut short, has bytes out of place or holds bytecode that can't run.
+var ErrMalformed = errors.New("compiled file is malformed")
+
+// IsLoxc reports whether data starts like a .loxc file.
+func IsLoxc(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(loxcMagic))
+}
+
+// Encode writes f, the function Compile returns for a script, in the .loxc
+// format.
+func Encode(w io.Writer, f *Function) error {
+	e := &encoder{}
+	if err := e.function(f); err != nil {
+		return err
+	}
+	header := make([]byte, 0, 10)
+	header = append(header, loxcMagic...)
+	header = binary.BigEndian.AppendUint16(header, LoxcVersion)
+	header = binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(e.buf))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf)
+	return err
+}
+
+// Decode reads a function written by Encode. It fails on files of another
+// version, on files whose checksum doesn't match and on bytecode the VM
+// can't run safely.
+func Decode(data []byte) (*Function, error) {
+	if !IsLoxc(data) {
+		return nil, ErrNotLoxc
+	}
+	if len(data) < 10 {
+		return nil, ErrMalformed
+	}
+	if version := binary.BigEndian.Uint16(data[4:]); version != LoxcVersion {
+		return nil, fmt.Errorf("compiled file has version %d, but this glox runs version %d; recompile it from source", version, LoxcVersion)
+	}
+	body := data[10:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[6:]) {
+		return nil, errors.New("compiled file is corrupt: checksum mismatch")
+	}
+	d := &decoder{buf: body}
+	f := d.function()
+	if d.err == nil && d.pos != len(d.buf) {
+		d.fail()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := verify(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint(n int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(n))
+}
+
+func (e *encoder) string(s string) {
+	e.uint(len(s))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) function(f *Function) error {
+	e.string(f.Name)
+	e.uint(f.Arity)
+	e.uint(f.UpvalueCount)
+	e.uint(len(f.Chunk.Code))
+	e.buf = append(e.buf, f.Chunk.Code...)
+	e.uint(len(f.Chunk.Constants))
+	for _, constant := range f.Chunk.Constants {
+		if err := e.value(constant); err != nil {
+			return err
+		}
+	}
+	e.uint(len(f.Chunk.Positions))
+	for _, position := range f.Chunk.Positions {
+		e.uint(position.Offset)
+		if err := e.token(position.Token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) token(tok token.Token) error {
+	e.uint(int(tok.Type))
+	e.string(tok.Lexeme)
+	e.uint(tok.Line)
+	e.uint(tok.Col)
+	return e.value(tok.Literal)
+}
+
+func (e *encoder) value(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		e.buf = append(e.buf, tagNil)
+	case int64:
+		e.buf = append(e.buf, tagInt)
+		e.buf = binary.AppendVarint(e.buf, value)
+	case float64:
+		e.buf = append(e.buf, tagFloat)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(value))
+	case string:
+		e.buf = append(e.buf, tagString)
+		e.string(value)
+	case *Function:
+		e.buf = append(e.buf, tagFunction)
+		return e.function(value)
+	default:
+		return fmt.Errorf("can't serialize constant of type %T", value)
+	}
+	return nil
+}
+
+// decoder reads the body of a .loxc file. The first error sticks, and
+// every read after it returns zero values.
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = ErrMalformed
+	}
+}
+
+func (d *decoder) uint() int {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(d.buf[d.pos:])
+	if size <= 0 || n > math.MaxInt32 {
+		d.fail()
+		return 0
+	}
+	d.pos += size
+	return int(n)
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil || n > len(d.buf)-d.pos {
+		d.fail()
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uint()))
+}
+
+func (d *decoder) function() *Function {
+	f := &Function{Name: d.string(), Arity: d.uint(), UpvalueCount: d.uint(), Chunk: &Chunk{}, decoded: true}
+	f.Chunk.Code = append([]byte{}, d.bytes(d.uint())...)
+	for n := d.uint(); n > 0 && d.err == nil; n-- {
+		f.Chunk.Constants = append(f.Chunk.Constants, d.value())
+	}
+	for n := d.uint(); n > 0 && d.err == nil; n-- {
+		f.Chunk.Positions = append(f.Chunk.Positions, Position{Offset: d.uint(), Token: d.token()})
+	}
+	return f
+}
+
+func (d *decoder) token() token.Token {
+	tok := token.Token{Type: token.TokenType(d.uint()), Lexeme: d.string(), Line: d.uint(), Col: d.uint()}
+	tok.Literal = d.value()
+	return tok
+}
+
+func (d *decoder) value() interface{} {
+	tag := d.bytes(1)
+	if tag == nil {
+		return nil
+	}
+	switch tag[0] {
+	case tagNil:
+		return nil
+	case tagInt:
+		n, size := binary.Varint(d.buf[d.pos:])
+		if size <= 0 {
+			d.fail()
+			return nil
+		}
+		d.pos += size
+		return n
+	case tagFloat:
+		b := d.bytes(8)
+		if b == nil {
+			return nil
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	case tagString:
+		return d.string()
+	case tagFunction:
+		return d.function()
+	}
+	d.fail()
+	return nil
+}
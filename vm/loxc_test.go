@@ -0,0 +1,213 @@
+package vm
+
+import (
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+const program = `
+class Counter {
+  init(start) { this.count = start; }
+  next() { this.count += 1; return this.count; }
+}
+class Named < Counter {
+  init(name) { super.init(0); this.name = name; }
+  toString { return "${this.name}: ${this.count}"; }
+}
+fun adder(n) { return (x) => x + n; }
+var c = Named("c");
+c.next();
+print c.toString;
+print adder(2)(3.5);
+var m = {"a": [1, 2, 3][1:], "b": nil};
+try {
+  throw Error("oops");
+} catch (e) {
+  print e.message;
+} finally {
+  print m;
+}
+for (var i = 0; i < 3; i++) {
+  match (i) {
+    case 0 => continue;
+    case n if n > 1 => print "big ${n}";
+    default => print i;
+  }
+}
+`
+
+// compile compiles source to the function of a script.
+func compile(t *testing.T, source string) *Function {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	r := resolver.NewResolver()
+	if len(s.Errors()) == 0 && len(p.Errors()) == 0 {
+		r.Resolve(statements)
+	}
+	if errors := append(append(s.Errors(), p.Errors()...), r.Errors()...); len(errors) > 0 {
+		t.Fatalf("compiling %q: %s", source, strings.Join(errors, "; "))
+	}
+	function, err := Compile(statements, "<script>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return function
+}
+
+func encode(t *testing.T, f *Function) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// run runs f on a new VM and returns what it printed and its error.
+func run(t *testing.T, f *Function) (string, error) {
+	t.Helper()
+	var err error
+	output := captureStdout(t, func() {
+		err = NewVM().Run(f)
+	})
+	return output, err
+}
+
+func disassemble(f *Function) string {
+	var out strings.Builder
+	Disassemble(&out, f)
+	return out.String()
+}
+
+func TestLoxcRoundTrip(t *testing.T) {
+	function := compile(t, program)
+	data := encode(t, function)
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := disassemble(decoded), disassemble(function); got != want {
+		t.Errorf("decoded bytecode differs:\n%s\nwant\n%s", got, want)
+	}
+	if again := encode(t, decoded); !bytes.Equal(again, data) {
+		t.Errorf("encoding the decoded function gives different bytes")
+	}
+	want, err := run(t, function)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := run(t, decoded); got != want || err != nil {
+		t.Errorf("decoded script printed %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	data := encode(t, compile(t, `print 1;`))
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not loxc", []byte("print 1;"), "not a compiled Glox file"},
+		{"short", data[:8], "compiled file is malformed"},
+		{"version", append([]byte("LOXC\x00\x09"), data[6:]...), "compiled file has version 9"},
+		{"checksum", append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^1), "checksum mismatch"},
+		{"truncated body", fixChecksum(data[:len(data)-3]), "compiled file is malformed"},
+		{"trailing bytes", fixChecksum(append(append([]byte{}, data...), 0)), "compiled file is malformed"},
+	}
+	for _, test := range tests {
+		if _, err := Decode(test.data); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+// fixChecksum updates the checksum in the header of a .loxc file to match
+// its body, so the body reaches the decoder.
+func fixChecksum(data []byte) []byte {
+	binary.BigEndian.PutUint32(data[6:], crc32.ChecksumIEEE(data[10:]))
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	callee := &Function{Name: "f", UpvalueCount: 1, Chunk: &Chunk{Code: []byte{byte(OpGetUpvalue), 0, byte(OpReturn)}}}
+	tests := []struct {
+		name      string
+		code      []byte
+		constants []interface{}
+		want      string
+	}{
+		{"unknown opcode", []byte{0xee}, nil, "unknown opcode 238"},
+		{"missing operand", []byte{byte(OpNil), byte(OpConstant), 0}, nil, "OpConstant is cut short"},
+		{"constant", []byte{byte(OpConstant), 0, 1, byte(OpReturn)}, []interface{}{nil}, "constant 1 out of range"},
+		{"name", []byte{byte(OpGetGlobal), 0, 0, byte(OpReturn)}, []interface{}{int64(1)}, "OpGetGlobal needs a string constant"},
+		{"closure", []byte{byte(OpClosure), 0, 0, byte(OpReturn)}, []interface{}{"f"}, "OpClosure needs a function constant"},
+		{"upvalue", []byte{byte(OpGetUpvalue), 0, byte(OpReturn)}, nil, "upvalue 0 out of range"},
+		{"captured upvalue", []byte{byte(OpClosure), 0, 0, 0, 0, byte(OpReturn)}, []interface{}{callee}, "upvalue 0 out of range"},
+		{"captured local", []byte{byte(OpClosure), 0, 0, 1, 3, byte(OpReturn)}, []interface{}{callee}, "captured local slot 3 out of range"},
+		{"local", []byte{byte(OpGetLocal), 1, byte(OpReturn)}, nil, "local slot 1 out of range"},
+		{"underflow", []byte{byte(OpAdd), byte(OpReturn)}, nil, "OpAdd needs 2 values, the stack holds 1"},
+		{"call", []byte{byte(OpCall), 1, byte(OpReturn)}, nil, "OpCall needs 2 values, the stack holds 1"},
+		{"jump outside", []byte{byte(OpJump), 0, 9, byte(OpReturn)}, nil, "jump to 12, outside the code"},
+		{"jump inside", []byte{byte(OpLoop), 0, 2, byte(OpReturn)}, nil, "jump to 1, inside an instruction"},
+		{"handler kind", []byte{byte(OpTry), 7, 0, 0, byte(OpReturn)}, nil, "unknown handler kind 7"},
+		{"heights", []byte{byte(OpTrue), byte(OpJumpIfFalse), 0, 1, byte(OpNil), byte(OpReturn)}, nil, "the stack holds"},
+		{"no return", []byte{byte(OpNil)}, nil, "the code ends without returning"},
+	}
+	for _, test := range tests {
+		function := &Function{Name: "<script>", Chunk: &Chunk{Code: test.code, Constants: test.constants}}
+		_, err := Decode(encode(t, function))
+		if !errors.Is(err, ErrMalformed) || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want a malformed file error containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestDecodeMutations(t *testing.T) {
+	// Damage every byte of the body in a few ways and make the checksum
+	// match. Decode either rejects the file or returns bytecode that can
+	// be disassembled.
+	data := encode(t, compile(t, program))
+	for pos := 10; pos < len(data); pos++ {
+		for _, mask := range []byte{0x01, 0x04, 0x80, 0xff} {
+			mutated := append([]byte{}, data...)
+			mutated[pos] ^= mask
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("byte %d ^ %#x: panic: %v", pos, mask, r)
+					}
+				}()
+				if function, err := Decode(fixChecksum(mutated)); err == nil {
+					disassemble(function)
+				}
+			}()
+		}
+	}
+}
+
+func TestMalformedAtRuntime(t *testing.T) {
+	// The verifier doesn't know the types of values: this asks nil whether
+	// it has a property, which compiled code only asks of instances.
+	function := &Function{Name: "<script>", Chunk: &Chunk{
+		Code:      []byte{byte(OpNil), byte(OpHasProperty), 0, 0, byte(OpReturn)},
+		Constants: []interface{}{"x"},
+	}}
+	decoded, err := Decode(encode(t, function))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, decoded); !errors.Is(err, ErrMalformed) {
+		t.Errorf("got %v, want a malformed file error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package vm
+
+import (
+	"Glox/ast"
+)
+
+// patternSlots maps the bindings of a case alternative, and the nested
+// patterns that need their value kept while it is tested, to local slots.
+type patternSlots struct {
+	names  map[string]byte
+	values map[ast.Pattern]byte
+}
+
+// VisitMatchStmt keeps the subject in a hidden local and tries the case
+// alternatives in order. Each alternative gets a scope with its bindings
+// allocated up front, so every failed test leaves the stack alike: the
+// locals of the scope and the false result of the test.
+func (c *compiler) VisitMatchStmt(stmt *ast.MatchStmt) interface{} {
+	c.beginScope()
+	c.expression(stmt.Subject)
+	subject := c.addLocal("")
+
+	var ends []int
+	for _, cs := range stmt.Cases {
+		for _, pattern := range cs.Patterns {
+			c.beginScope()
+			start := len(c.locals)
+			slots := &patternSlots{names: make(map[string]byte), values: make(map[ast.Pattern]byte)}
+			c.declarePattern(pattern, slots)
+
+			fails := &[]int{}
+			c.testPattern(pattern, subject, slots, fails)
+			if cs.Guard != nil {
+				c.expression(cs.Guard)
+				c.failUnless(fails)
+			}
+			c.statement(cs.Body)
+			locals := append([]local{}, c.locals[start:]...)
+			c.endScope()
+			ends = append(ends, c.emitJump(OpJump))
+
+			for _, jump := range *fails {
+				c.patchJump(jump)
+			}
+			c.emit(OpPop)
+			c.popLocals(locals)
+		}
+	}
+	if stmt.Default == nil {
+		c.emitAt(stmt.Keyword, OpGetLocal, subject)
+		c.emit(OpNoMatch)
+	} else {
+		c.statement(stmt.Default)
+	}
+	for _, jump := range ends {
+		c.patchJump(jump)
+	}
+	c.endScope()
+	return nil
+}
+
+// declarePattern allocates the slots of the bindings in pattern and of the
+// values its nested patterns are tested against.
+func (c *compiler) declarePattern(pattern ast.Pattern, slots *patternSlots) {
+	var elements []ast.Pattern
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		if _, ok := slots.names[pattern.Name.Lexeme]; !ok && pattern.Name.Lexeme != "_" {
+			c.emitAt(pattern.Name, OpNil)
+			slots.names[pattern.Name.Lexeme] = c.addLocal(pattern.Name.Lexeme)
+		}
+		return
+	case *ast.ListPattern:
+		elements = pattern.Elements
+	case *ast.InstancePattern:
+		elements = pattern.Patterns
+	}
+	for _, element := range elements {
+		if _, ok := element.(*ast.BindingPattern); !ok {
+			c.emit(OpNil)
+			slots.values[element] = c.addLocal("")
+		}
+		c.declarePattern(element, slots)
+	}
+}
+
+// testPattern emits the test of the value in slot against pattern. Each
+// failing test jumps to one of fails with false on the stack.
+func (c *compiler) testPattern(pattern ast.Pattern, slot byte, slots *patternSlots, fails *[]int) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		c.emit(OpGetLocal, slot)
+		c.expression(pattern.Value)
+		c.emit(OpEqual)
+		c.failUnless(fails)
+	case *ast.BindingPattern:
+		if pattern.Name.Lexeme != "_" {
+			c.emit(OpGetLocal, slot)
+			c.emit(OpSetLocal, slots.names[pattern.Name.Lexeme])
+			c.emit(OpPop)
+		}
+	case *ast.ListPattern:
+		c.emitAt(pattern.Bracket, OpGetLocal, slot)
+		c.emit(OpMatchList, short(len(pattern.Elements))...)
+		c.failUnless(fails)
+		for n, element := range pattern.Elements {
+			if binding, ok := element.(*ast.BindingPattern); ok && binding.Name.Lexeme == "_" {
+				continue
+			}
+			c.emitAt(pattern.Bracket, OpGetLocal, slot)
+			c.emit(OpConstant, short(c.makeConstant(int64(n)))...)
+			c.emit(OpGetIndex)
+			c.storeAndTest(element, slots, fails)
+		}
+	case *ast.InstancePattern:
+		c.emit(OpGetLocal, slot)
+		c.getVariable(pattern.Class.Name)
+		c.emit(OpMatchClass)
+		c.failUnless(fails)
+		for n, field := range pattern.Fields {
+			element := pattern.Patterns[n]
+			c.emitAt(field, OpGetLocal, slot)
+			c.emit(OpHasProperty, c.identifier(field)...)
+			c.failUnless(fails)
+			c.emitAt(field, OpGetLocal, slot)
+			c.emit(OpGetProperty, c.identifier(field)...)
+			c.storeAndTest(element, slots, fails)
+		}
+	}
+}
+
+// storeAndTest tests the value on top of the stack, an element or a field,
+// against a nested pattern. Bindings take the value straight into their
+// slot, other patterns first store it in the slot declarePattern gave them.
+func (c *compiler) storeAndTest(pattern ast.Pattern, slots *patternSlots, fails *[]int) {
+	binding, ok := pattern.(*ast.BindingPattern)
+	if !ok {
+		c.emit(OpSetLocal, slots.values[pattern])
+		c.emit(OpPop)
+		c.testPattern(pattern, slots.values[pattern], slots, fails)
+		return
+	}
+	if binding.Name.Lexeme != "_" {
+		c.emit(OpSetLocal, slots.names[binding.Name.Lexeme])
+	}
+	c.emit(OpPop)
+}
+
+func (c *compiler) failUnless(fails *[]int) {
+	*fails = append(*fails, c.emitJump(OpJumpIfFalse))
+	c.emit(OpPop)
+}
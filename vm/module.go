@@ -0,0 +1,42 @@
+package vm
+
+import (
+	"Glox/interpreter"
+	"Glox/token"
+	"strings"
+)
+
+// importModule pushes the module at path, compiling it and calling its top
+// level the first time it is imported. The module is pushed when that call
+// returns. Modules are cached by their canonical path.
+func (vm *VM) importModule(path token.Token) {
+	importer := vm.file
+	if module := vm.frames[len(vm.frames)-1].closure.globals.module; module != nil {
+		importer = module.path
+	}
+	resolved := interpreter.ResolveModule(path, importer)
+	for n, importing := range vm.importing {
+		if importing == resolved {
+			cycle := append(append([]string{}, vm.importing[n:]...), resolved)
+			runtimeError(path, "Import cycle: %s.", strings.Join(cycle, " -> "))
+		}
+	}
+	if module, ok := vm.modules[resolved]; ok {
+		vm.push(module)
+		return
+	}
+
+	statements := interpreter.ParseModule(path, resolved)
+	module := &Module{path: resolved, exports: make(map[string]bool)}
+	module.globals = newGlobals(nativeGlobals())
+	module.globals.module = module
+	function, err := Compile(statements, module.String())
+	if err != nil {
+		runtimeError(path, "Could not compile module %s:\n    %s", path.Lexeme, strings.Join(err.(*CompileError).Errors, "\n    "))
+	}
+
+	closure := &Closure{Function: function, globals: module.globals}
+	vm.push(closure)
+	vm.callClosure(closure, 0).module = module
+	vm.importing = append(vm.importing, resolved)
+}
@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"Glox/interpreter"
+	"Glox/token"
+	"time"
+	"unicode/utf8"
+)
+
+// nativeGlobals returns a new scope holding only the built-in functions, to
+// enclose the globals of the main script or of a module.
+func nativeGlobals() *globals {
+	g := newGlobals(nil)
+	defineNatives(g)
+	return g
+}
+
+// defineNatives installs the built-in functions in g. They behave like the
+// interpreter's.
+func defineNatives(g *globals) {
+	natives := []*Native{
+		{Name: "clock", Arity: 0, fn: nativeClock},
+		{Name: "Error", Arity: 1, fn: nativeError},
+		{Name: "len", Arity: 1, fn: nativeLen},
+		{Name: "push", Arity: 2, fn: nativePush},
+		{Name: "pop", Arity: 1, fn: nativePop},
+		{Name: "keys", Arity: 1, fn: nativeKeys},
+		{Name: "values", Arity: 1, fn: nativeValues},
+		{Name: "has", Arity: 2, fn: nativeHas},
+		{Name: "remove", Arity: 2, fn: nativeRemove},
+	}
+	for _, native := range natives {
+		g.values[native.Name] = native
+	}
+}
+
+func nativeClock(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return float64(time.Now().UnixNano()) / float64(time.Second)
+}
+
+// nativeError builds an error object whose line and stack trace point at
+// the call.
+func nativeError(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return &interpreter.LoxError{Message: interpreter.Stringify(arguments[0]), Line: paren.Line, Stack: vm.stackTrace(paren.Line)}
+}
+
+func nativeLen(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	switch value := arguments[0].(type) {
+	case *interpreter.List:
+		return int64(len(value.Elements))
+	case *interpreter.Map:
+		return int64(value.Len())
+	case string:
+		return int64(utf8.RuneCountInString(value))
+	}
+	runtimeError(paren, "len() expects a list, a map or a string.")
+	return nil
+}
+
+func nativePush(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	list := checkList(paren, "push", arguments[0])
+	list.Push(arguments[1])
+	return list
+}
+
+func nativePop(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return checkList(paren, "pop", arguments[0]).Pop(paren)
+}
+
+func checkList(paren token.Token, name string, value interface{}) *interpreter.List {
+	list, ok := value.(*interpreter.List)
+	if !ok {
+		runtimeError(paren, "%s() expects a list as its first argument.", name)
+	}
+	return list
+}
+
+func nativeKeys(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "keys", arguments[0]).Keys()
+}
+
+func nativeValues(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "values", arguments[0]).Values()
+}
+
+func nativeHas(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "has", arguments[0]).Has(paren, arguments[1])
+}
+
+func nativeRemove(vm *VM, paren token.Token, arguments []interface{}) interface{} {
+	return checkMap(paren, "remove", arguments[0]).Remove(paren, arguments[1])
+}
+
+func checkMap(paren token.Token, name string, value interface{}) *interpreter.Map {
+	m, ok := value.(*interpreter.Map)
+	if !ok {
+		runtimeError(paren, "%s() expects a map as its first argument.", name)
+	}
+	return m
+}
@@ -0,0 +1,205 @@
+package vm
+
+import (
+	"Glox/token"
+	"path/filepath"
+)
+
+// Values on the VM stack are the same Go values the tree-walker uses for
+// numbers, strings, booleans, nil, lists, maps and errors, so both engines
+// print and compare them alike. Functions, classes, instances and modules
+// have their own representation below.
+
+// Function is a compiled function: its bytecode and how to call it.
+type Function struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        *Chunk
+	decoded      bool // read from a .loxc file rather than compiled.
+}
+
+func (f *Function) String() string {
+	return "<fn " + f.Name + ">"
+}
+
+// Closure is a function together with the variables it captured and the
+// globals of the script or module it was declared in.
+type Closure struct {
+	Function *Function
+	Upvalues []*Upvalue
+	globals  *globals
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+func (c *Closure) TypeName() string {
+	return "function"
+}
+
+// Upvalue is a variable captured by a closure. It refers to a stack slot
+// while the variable's scope is alive and holds the value once it closes.
+type Upvalue struct {
+	slot   int
+	closed bool
+	value  interface{}
+}
+
+// BoundMethod is a method with this bound to an instance, or to the class
+// for static methods.
+type BoundMethod struct {
+	Receiver interface{}
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+func (b *BoundMethod) TypeName() string {
+	return "function"
+}
+
+// Native is a built-in function implemented in Go. Arity is -1 when it
+// accepts any number of arguments.
+type Native struct {
+	Name  string
+	Arity int
+	fn    func(vm *VM, paren token.Token, arguments []interface{}) interface{}
+}
+
+func (n *Native) String() string {
+	return "<native fn " + n.Name + ">"
+}
+
+func (n *Native) TypeName() string {
+	return "function"
+}
+
+// Class is a class value. Calling it creates an instance and runs its init
+// method, if any.
+type Class struct {
+	Name       string
+	Superclass *Class
+	Methods    map[string]*Closure
+	Getters    map[string]*Closure
+	Setters    map[string]*Closure
+	Statics    map[string]*Closure
+}
+
+func NewClass(name string) *Class {
+	return &Class{
+		Name:    name,
+		Methods: make(map[string]*Closure),
+		Getters: make(map[string]*Closure),
+		Setters: make(map[string]*Closure),
+		Statics: make(map[string]*Closure),
+	}
+}
+
+// find looks a member up in the class and then in its superclasses.
+func (c *Class) find(name string, members func(c *Class) map[string]*Closure) *Closure {
+	for class := c; class != nil; class = class.Superclass {
+		if member, ok := members(class)[name]; ok {
+			return member
+		}
+	}
+	return nil
+}
+
+func (c *Class) FindMethod(name string) *Closure {
+	return c.find(name, func(c *Class) map[string]*Closure { return c.Methods })
+}
+
+func (c *Class) FindGetter(name string) *Closure {
+	return c.find(name, func(c *Class) map[string]*Closure { return c.Getters })
+}
+
+func (c *Class) FindSetter(name string) *Closure {
+	return c.find(name, func(c *Class) map[string]*Closure { return c.Setters })
+}
+
+func (c *Class) FindStatic(name string) *Closure {
+	return c.find(name, func(c *Class) map[string]*Closure { return c.Statics })
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+func (c *Class) TypeName() string {
+	return "class"
+}
+
+// Instance is an object created by calling a class.
+type Instance struct {
+	Class  *Class
+	Fields map[string]interface{}
+}
+
+// IsA reports whether the instance's class is class or inherits from it.
+func (o *Instance) IsA(class *Class) bool {
+	for c := o.Class; c != nil; c = c.Superclass {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *Instance) String() string {
+	return o.Class.Name + " instance"
+}
+
+func (o *Instance) TypeName() string {
+	return "instance"
+}
+
+// Module is the value bound by import "path" as name.
+type Module struct {
+	path    string
+	globals *globals
+	exports map[string]bool
+}
+
+func (m *Module) get(name token.Token) interface{} {
+	if m.exports[name.Lexeme] {
+		return m.globals.values[name.Lexeme]
+	}
+	runtimeError(name, "Module '%s' has no export '%s'.", filepath.Base(m.path), name.Lexeme)
+	return nil
+}
+
+func (m *Module) String() string {
+	return "<module " + filepath.Base(m.path) + ">"
+}
+
+func (m *Module) TypeName() string {
+	return "module"
+}
+
+// globals is the top-level scope of the main script or of a module, or the
+// scope holding only the natives that each of those is enclosed in. A module
+// can't reach the globals of the file importing it.
+type globals struct {
+	values    map[string]interface{}
+	consts    map[string]token.Token
+	enclosing *globals
+	module    *Module // nil for the main script.
+}
+
+func newGlobals(enclosing *globals) *globals {
+	return &globals{
+		values:    make(map[string]interface{}),
+		consts:    make(map[string]token.Token),
+		enclosing: enclosing,
+	}
+}
+
+// pending is the value a finally handler receives: the error it has to
+// raise again once the finally block is done.
+type pending struct {
+	err error
+}
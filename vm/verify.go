@@ -0,0 +1,279 @@
+package vm
+
+import (
+	"fmt"
+)
+
+// verify checks that the bytecode of f and of the functions in its
+// constant pool can run without reading past its code, its constants, its
+// upvalues or the values of its frame. The compiler never emits code that
+// fails these checks, but a damaged or hand-made .loxc file may contain
+// it, and Decode rejects it instead of letting the VM crash. What verify
+// can't see, like the types of values, the VM checks as it runs them.
+//
+// Besides looking at every instruction on its own, verify follows the
+// jumps to find the height of the frame's stack at each instruction, which
+// has to be the same along every path to it. Slot 0 of the frame holds the
+// callee and the arguments follow, so a function starts at Arity+1.
+func verify(f *Function) error {
+	v := &verifier{function: f, code: f.Chunk.Code}
+	if err := v.verify(); err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+	for _, constant := range f.Chunk.Constants {
+		if nested, ok := constant.(*Function); ok {
+			if err := verify(nested); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// operandSizes is the size of the operands of each opcode, not counting
+// the upvalue pairs following OpClosure.
+var operandSizes = map[OpCode]int{
+	OpConstant: 2, OpBury: 1, OpGetLocal: 1, OpSetLocal: 1, OpGetUpvalue: 1,
+	OpSetUpvalue: 1, OpDefineGlobal: 2, OpDefineConst: 2, OpGetGlobal: 2,
+	OpSetGlobal: 2, OpGetProperty: 2, OpSetProperty: 2, OpHasProperty: 2,
+	OpGetSuper: 2, OpJump: 2, OpJumpIfFalse: 2, OpLoop: 2, OpCall: 1,
+	OpClosure: 2, OpClass: 2, OpMethod: 3, OpList: 2, OpInterpolate: 2,
+	OpTry: 3, OpImport: 2, OpImportName: 2, OpExport: 2, OpMatchList: 2,
+}
+
+// maxUpvalues is the most upvalues the compiler gives a function.
+const maxUpvalues = 0x100
+
+type verifier struct {
+	function *Function
+	code     []byte
+	// heights[offset] is the stack height before the instruction at
+	// offset, or -1 for offsets that don't start an instruction or that
+	// no path reaches yet.
+	heights []int
+	starts  []bool
+	pending []int // reached instructions whose successors are unchecked.
+}
+
+func (v *verifier) verify() error {
+	f := v.function
+	if f.Arity > 255 || f.UpvalueCount > maxUpvalues {
+		return fmt.Errorf("%s has %d parameters and %d upvalues", f.Name, f.Arity, f.UpvalueCount)
+	}
+	v.starts = make([]bool, len(v.code))
+	for offset := 0; offset < len(v.code); {
+		size, err := v.instruction(offset)
+		if err != nil {
+			return fmt.Errorf("%s, offset %d: %s", f.Name, offset, err)
+		}
+		v.starts[offset] = true
+		offset += size
+	}
+	v.heights = make([]int, len(v.code))
+	for n := range v.heights {
+		v.heights[n] = -1
+	}
+	if err := v.reach(0, f.Arity+1); err != nil {
+		return fmt.Errorf("%s: %s", f.Name, err)
+	}
+	for len(v.pending) > 0 {
+		offset := v.pending[len(v.pending)-1]
+		v.pending = v.pending[:len(v.pending)-1]
+		if err := v.flow(offset); err != nil {
+			return fmt.Errorf("%s, offset %d: %s", f.Name, offset, err)
+		}
+	}
+	return nil
+}
+
+// instruction checks the opcode and the operands of the instruction at
+// offset on their own and returns its size.
+func (v *verifier) instruction(offset int) (int, error) {
+	op := OpCode(v.code[offset])
+	if int(op) >= len(opNames) {
+		return 0, fmt.Errorf("unknown opcode %d", op)
+	}
+	size := 1 + operandSizes[op]
+	if offset+size > len(v.code) {
+		return 0, fmt.Errorf("%s is cut short", op)
+	}
+	switch op {
+	case OpConstant:
+		_, err := v.constant(offset)
+		return size, err
+	case OpDefineGlobal, OpDefineConst, OpGetGlobal, OpSetGlobal, OpGetProperty,
+		OpSetProperty, OpHasProperty, OpGetSuper, OpClass, OpMethod, OpImport,
+		OpImportName, OpExport:
+		constant, err := v.constant(offset)
+		if _, ok := constant.(string); err == nil && !ok {
+			err = fmt.Errorf("%s needs a string constant", op)
+		}
+		return size, err
+	case OpGetUpvalue, OpSetUpvalue:
+		if index := int(v.code[offset+1]); index >= v.function.UpvalueCount {
+			return 0, fmt.Errorf("upvalue %d out of range", index)
+		}
+	case OpClosure:
+		constant, err := v.constant(offset)
+		if err != nil {
+			return 0, err
+		}
+		function, ok := constant.(*Function)
+		if !ok {
+			return 0, fmt.Errorf("%s needs a function constant", op)
+		}
+		size += 2 * function.UpvalueCount
+		if offset+size > len(v.code) {
+			return 0, fmt.Errorf("%s is cut short", op)
+		}
+		for n := offset + 3; n < offset+size; n += 2 {
+			isLocal, index := v.code[n], int(v.code[n+1])
+			if isLocal > 1 {
+				return 0, fmt.Errorf("%s captures neither a local nor an upvalue", op)
+			}
+			if isLocal == 0 && index >= v.function.UpvalueCount {
+				return 0, fmt.Errorf("upvalue %d out of range", index)
+			}
+		}
+	case OpTry:
+		if kind := v.code[offset+1]; kind != catchHandler && kind != finallyHandler {
+			return 0, fmt.Errorf("unknown handler kind %d", kind)
+		}
+	}
+	return size, nil
+}
+
+func (v *verifier) constant(offset int) (interface{}, error) {
+	n := v.u16(offset + 1)
+	if n >= len(v.function.Chunk.Constants) {
+		return nil, fmt.Errorf("constant %d out of range", n)
+	}
+	return v.function.Chunk.Constants[n], nil
+}
+
+func (v *verifier) u16(offset int) int {
+	return int(v.code[offset])<<8 | int(v.code[offset+1])
+}
+
+// reach records that an instruction runs with the stack at height.
+func (v *verifier) reach(offset int, height int) error {
+	if offset < 0 || offset >= len(v.code) {
+		return fmt.Errorf("jump to %d, outside the code", offset)
+	}
+	if !v.starts[offset] {
+		return fmt.Errorf("jump to %d, inside an instruction", offset)
+	}
+	switch v.heights[offset] {
+	case -1:
+		v.heights[offset] = height
+		v.pending = append(v.pending, offset)
+	case height:
+	default:
+		return fmt.Errorf("the stack holds %d or %d values at %d", v.heights[offset], height, offset)
+	}
+	return nil
+}
+
+// flow checks that the instruction at offset has the values it uses and
+// passes the height after it on to the instructions that can run next.
+func (v *verifier) flow(offset int) error {
+	op := OpCode(v.code[offset])
+	height := v.heights[offset]
+	u8 := func() int { return int(v.code[offset+1]) }
+
+	// The values the instruction needs on the stack, and how it changes
+	// the height.
+	need, change := 0, 0
+	next := offset + 1 + operandSizes[op]
+	switch op {
+	case OpConstant, OpNil, OpTrue, OpFalse, OpGetGlobal, OpGetUpvalue, OpClass, OpMap, OpImport:
+		change = 1
+	case OpPop, OpDefineGlobal, OpDefineConst, OpCloseUpvalue, OpPrint:
+		need, change = 1, -1
+	case OpDup, OpImportName:
+		need, change = 1, 1
+	case OpDupTwo:
+		need, change = 2, 2
+	case OpBury:
+		need = u8() + 1
+	case OpGetLocal, OpSetLocal:
+		if slot := u8(); slot >= height {
+			return fmt.Errorf("local slot %d out of range", slot)
+		}
+		if op == OpGetLocal {
+			change = 1
+		} else {
+			need = 1
+		}
+	case OpSetUpvalue, OpSetGlobal, OpGetProperty, OpHasProperty, OpNot, OpNegate, OpIncrement, OpMatchList:
+		need = 1
+	case OpSetProperty, OpGetSuper, OpGetIndex, OpEqual, OpNotEqual, OpGreater, OpGreaterEqual,
+		OpLess, OpLessEqual, OpAdd, OpSubtract, OpMultiply, OpDivide, OpIntDivide, OpModulo,
+		OpMethod, OpMatchClass:
+		need, change = 2, -1
+	case OpSetIndex, OpSlice, OpMapEntry:
+		need, change = 3, -2
+	case OpInherit:
+		need = 2
+	case OpCall:
+		need, change = u8()+1, -u8()
+	case OpClosure:
+		function := v.function.Chunk.Constants[v.u16(offset+1)].(*Function)
+		for n := offset + 3; n < offset+3+2*function.UpvalueCount; n += 2 {
+			if v.code[n] == 1 && int(v.code[n+1]) >= height {
+				return fmt.Errorf("captured local slot %d out of range", v.code[n+1])
+			}
+		}
+		next += 2 * function.UpvalueCount
+		change = 1
+	case OpList, OpInterpolate:
+		count := v.u16(offset + 1)
+		need, change = count, 1-count
+	case OpEndTry, OpExport:
+	case OpJump, OpLoop, OpJumpIfFalse, OpTry:
+		return v.jump(op, offset, height)
+	case OpReturn, OpThrow, OpRethrow, OpNoMatch:
+		// These leave the function or raise an error.
+		if height < 1 {
+			return fmt.Errorf("%s needs 1 value, the stack holds none", op)
+		}
+		return nil
+	}
+	if height < need {
+		return fmt.Errorf("%s needs %d values, the stack holds %d", op, need, height)
+	}
+	return v.next(next, height+change)
+}
+
+// next records that execution continues at offset, after the previous
+// instruction.
+func (v *verifier) next(offset int, height int) error {
+	if offset == len(v.code) {
+		return fmt.Errorf("the code ends without returning")
+	}
+	return v.reach(offset, height)
+}
+
+// jump passes the height on past a jump, a conditional jump or a try.
+func (v *verifier) jump(op OpCode, offset int, height int) error {
+	switch op {
+	case OpJump:
+		return v.reach(offset+3+v.u16(offset+1), height)
+	case OpLoop:
+		return v.reach(offset+3-v.u16(offset+1), height)
+	case OpJumpIfFalse:
+		if height < 1 {
+			return fmt.Errorf("%s needs 1 value, the stack holds none", op)
+		}
+		if err := v.reach(offset+3+v.u16(offset+1), height); err != nil {
+			return err
+		}
+		return v.next(offset+3, height)
+	}
+	// The handler starts with the stack as it was at the try, plus the
+	// error or the pending jump.
+	if err := v.reach(offset+4+v.u16(offset+2), height+1); err != nil {
+		return err
+	}
+	return v.next(offset+4, height)
+}
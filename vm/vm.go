@@ -0,0 +1,700 @@
+// Package vm is the second execution engine of Glox. It compiles the
+// resolved ast to bytecode and runs it on a stack machine. Values, runtime
+// errors and their messages are shared with the interpreter package, so a
+// script prints the same output on either engine.
+package vm
+
+import (
+	"Glox/ast"
+	"Glox/interpreter"
+	"Glox/token"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// maxFrames bounds the call depth, so runaway recursion is reported instead
+// of exhausting the memory.
+const maxFrames = 1 << 20
+
+// VM keeps its globals and the modules it loaded between calls to
+// Interpret, so the REPL can feed it one line at a time.
+type VM struct {
+	stack    []interface{}
+	frames   []frame
+	handlers []handler
+	open     []*Upvalue // upvalues still referring to the stack, by slot.
+	globals  *globals
+
+	file      string             // canonical path of the running file, "" in the REPL.
+	modules   map[string]*Module // evaluated modules by canonical path.
+	importing []string           // files being evaluated, to detect import cycles.
+}
+
+// frame is a call in progress.
+type frame struct {
+	closure *Closure
+	ip      int
+	base    int // stack index of slot 0.
+	// A setter call evaluates to the assigned value rather than to what
+	// the setter returns.
+	setter bool
+	value  interface{}
+	module *Module // set when the frame runs the top level of a module.
+}
+
+// handler is an installed try handler. Raising an error unwinds the frames
+// and the stack to the height they had when it was installed.
+type handler struct {
+	frames    int
+	stack     int
+	importing int
+	target    int
+	finally   bool
+}
+
+func NewVM() *VM {
+	return &VM{globals: newGlobals(nativeGlobals()), modules: make(map[string]*Module)}
+}
+
+// SetFile tells the VM which file it runs, so imports resolve relative to
+// it and importing it back is reported as a cycle.
+func (vm *VM) SetFile(path string) {
+	if canonical, err := interpreter.CanonicalPath(path); err == nil {
+		vm.file = canonical
+		vm.importing = []string{canonical}
+	}
+}
+
+// Interpret compiles and runs statements. It returns a *CompileError if
+// they don't fit the bytecode, or else the runtime error or the uncaught
+// throw that stopped them, if any.
+func (vm *VM) Interpret(statements []ast.Statement) error {
+	function, err := Compile(statements, "<script>")
+	if err != nil {
+		return err
+	}
+	return vm.Run(function)
+}
+
+// Run executes a compiled script.
+func (vm *VM) Run(function *Function) error {
+	closure := &Closure{Function: function, globals: vm.globals}
+	vm.stack = append(vm.stack[:0], closure)
+	vm.frames = append(vm.frames[:0], frame{closure: closure})
+	for {
+		err := vm.execute()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrMalformed) || !vm.handle(err) {
+			vm.reset()
+			return err
+		}
+	}
+}
+
+func (vm *VM) reset() {
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.handlers = vm.handlers[:0]
+	vm.open = vm.open[:0]
+	if len(vm.importing) > 0 && vm.importing[0] == vm.file {
+		vm.importing = vm.importing[:1]
+	} else {
+		vm.importing = vm.importing[:0]
+	}
+}
+
+// handle passes an error to the innermost try handler, reporting false
+// when there is none.
+func (vm *VM) handle(err error) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	var value interface{}
+	if h.finally {
+		value = &pending{err: err}
+	} else {
+		switch err := err.(type) {
+		case *interpreter.RuntimeError:
+			value = &interpreter.LoxError{Message: err.Message, Line: err.Token.Line, Stack: err.Stack}
+		case *interpreter.Throw:
+			value = err.Value
+		}
+	}
+	vm.closeUpvalues(h.stack)
+	vm.frames = vm.frames[:h.frames]
+	vm.stack = vm.stack[:h.stack]
+	vm.importing = vm.importing[:h.importing]
+	vm.push(value)
+	vm.frames[len(vm.frames)-1].ip = h.target
+	return true
+}
+
+// execute runs instructions until the script returns or an error is
+// raised. Errors are raised as panics, like in the interpreter, and turned
+// into the returned error here.
+func (vm *VM) execute() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *interpreter.RuntimeError:
+				if r.Stack == nil {
+					r.Stack = vm.stackTrace(r.Token.Line)
+				}
+				err = r
+			case *interpreter.Throw:
+				err = r
+			case runtime.Error:
+				// Bytecode read from a file passed verify, but may still
+				// use values of the wrong type or leave upvalues open.
+				if n := len(vm.frames); n == 0 || !vm.frames[n-1].closure.Function.decoded {
+					panic(r)
+				}
+				err = fmt.Errorf("%w: %s", ErrMalformed, r)
+			default:
+				panic(r)
+			}
+		}
+	}()
+
+	for {
+		f := &vm.frames[len(vm.frames)-1]
+		op := OpCode(f.closure.Function.Chunk.Code[f.ip])
+		f.ip++
+		switch op {
+		case OpConstant:
+			vm.push(f.readConstant())
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpDup:
+			vm.push(vm.peek(0))
+		case OpDupTwo:
+			a, b := vm.peek(1), vm.peek(0)
+			vm.push(a)
+			vm.push(b)
+		case OpBury:
+			depth := int(f.readByte())
+			value := vm.pop()
+			at := len(vm.stack) - depth
+			vm.stack = append(vm.stack, nil)
+			copy(vm.stack[at+1:], vm.stack[at:])
+			vm.stack[at] = value
+
+		case OpGetLocal:
+			vm.push(vm.stack[f.base+int(f.readByte())])
+		case OpSetLocal:
+			vm.stack[f.base+int(f.readByte())] = vm.peek(0)
+		case OpGetUpvalue:
+			vm.push(vm.get(f.closure.Upvalues[f.readByte()]))
+		case OpSetUpvalue:
+			vm.set(f.closure.Upvalues[f.readByte()], vm.peek(0))
+		case OpDefineGlobal:
+			name := f.readName()
+			g := f.closure.globals
+			vm.declare(g, name)
+			g.values[name] = vm.pop()
+		case OpDefineConst:
+			name := f.readName()
+			g := f.closure.globals
+			vm.declare(g, name)
+			g.values[name] = vm.pop()
+			g.consts[name] = vm.token()
+		case OpGetGlobal:
+			vm.push(vm.getGlobal(f.closure.globals, f.readName()))
+		case OpSetGlobal:
+			vm.setGlobal(f.closure.globals, f.readName(), vm.peek(0))
+
+		case OpGetProperty:
+			vm.getProperty(f.readName())
+		case OpSetProperty:
+			vm.setProperty(f.readName())
+		case OpHasProperty:
+			name := f.readName()
+			instance := vm.pop().(*Instance)
+			_, ok := instance.Fields[name]
+			vm.push(ok || instance.Class.FindGetter(name) != nil)
+		case OpGetSuper:
+			name := f.readName()
+			this := vm.pop()
+			superclass := vm.pop().(*Class)
+			var method *Closure
+			if _, ok := this.(*Class); ok {
+				method = superclass.FindStatic(name)
+			} else {
+				method = superclass.FindMethod(name)
+			}
+			if method == nil {
+				vm.fail("Undefined property '%s'.", name)
+			}
+			vm.push(&BoundMethod{Receiver: this, Method: method})
+		case OpGetIndex:
+			index := vm.pop()
+			object := vm.pop()
+			vm.push(vm.getIndex(object, index))
+		case OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			object := vm.pop()
+			vm.setIndex(object, index, value)
+			vm.push(value)
+		case OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			list, ok := vm.pop().(*interpreter.List)
+			if !ok {
+				vm.fail("Only lists can be sliced.")
+			}
+			vm.push(list.Slice(vm.token(), start, end))
+
+		case OpEqual:
+			right := vm.pop()
+			vm.push(interpreter.IsEqual(vm.pop(), right))
+		case OpNotEqual:
+			right := vm.pop()
+			vm.push(!interpreter.IsEqual(vm.pop(), right))
+		case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpAdd, OpSubtract, OpMultiply, OpDivide, OpIntDivide, OpModulo:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(interpreter.Binary(vm.token(), left, right))
+		case OpNot:
+			vm.push(!interpreter.IsTruthy(vm.pop()))
+		case OpNegate:
+			vm.push(interpreter.Unary(vm.token(), vm.pop()))
+		case OpIncrement:
+			vm.push(interpreter.Increment(vm.token(), vm.pop()))
+		case OpPrint:
+			fmt.Println(interpreter.Stringify(vm.pop()))
+
+		case OpJump:
+			offset := f.readShort()
+			f.ip += offset
+		case OpJumpIfFalse:
+			offset := f.readShort()
+			if !interpreter.IsTruthy(vm.peek(0)) {
+				f.ip += offset
+			}
+		case OpLoop:
+			offset := f.readShort()
+			f.ip -= offset
+		case OpCall:
+			count := int(f.readByte())
+			vm.call(vm.peek(count), count)
+		case OpClosure:
+			function := f.readConstant().(*Function)
+			closure := &Closure{Function: function, Upvalues: make([]*Upvalue, function.UpvalueCount), globals: f.closure.globals}
+			for n := range closure.Upvalues {
+				isLocal, index := f.readByte(), int(f.readByte())
+				if isLocal == 1 {
+					closure.Upvalues[n] = vm.capture(f.base + index)
+				} else {
+					closure.Upvalues[n] = f.closure.Upvalues[index]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			if vm.ret() {
+				return nil
+			}
+
+		case OpClass:
+			vm.push(NewClass(f.readName()))
+		case OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				vm.fail("Superclass must be a class.")
+			}
+			vm.peek(0).(*Class).Superclass = superclass
+		case OpMethod:
+			name := f.readName()
+			kind := ast.FunctionKind(f.readByte())
+			method := vm.pop().(*Closure)
+			class := vm.peek(0).(*Class)
+			switch kind {
+			case ast.StaticMethod:
+				class.Statics[name] = method
+			case ast.Getter:
+				class.Getters[name] = method
+			case ast.Setter:
+				class.Setters[name] = method
+			default:
+				class.Methods[name] = method
+			}
+
+		case OpList:
+			count := f.readShort()
+			elements := make([]interface{}, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(interpreter.NewList(elements))
+		case OpMap:
+			vm.push(interpreter.NewMap())
+		case OpMapEntry:
+			value := vm.pop()
+			key := vm.pop()
+			vm.peek(0).(*interpreter.Map).Set(vm.token(), key, value)
+		case OpInterpolate:
+			count := f.readShort()
+			var out strings.Builder
+			for _, part := range vm.stack[len(vm.stack)-count:] {
+				out.WriteString(interpreter.Stringify(part))
+			}
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(out.String())
+
+		case OpThrow:
+			keyword := vm.token()
+			panic(&interpreter.Throw{Keyword: keyword, Value: vm.pop(), Stack: vm.stackTrace(keyword.Line)})
+		case OpTry:
+			kind := f.readByte()
+			offset := f.readShort()
+			vm.handlers = append(vm.handlers, handler{
+				frames:    len(vm.frames),
+				stack:     len(vm.stack),
+				importing: len(vm.importing),
+				target:    f.ip + offset,
+				finally:   kind == finallyHandler,
+			})
+		case OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OpRethrow:
+			panic(vm.pop().(*pending).err)
+
+		case OpImport:
+			path := vm.token()
+			path.Literal = f.readConstant()
+			vm.importModule(path)
+		case OpImportName:
+			// The name is the instruction's token, which errors report.
+			f.readShort()
+			vm.push(vm.peek(0).(*Module).get(vm.token()))
+		case OpExport:
+			name := f.readName()
+			if module := f.closure.globals.module; module != nil {
+				module.exports[name] = true
+			}
+
+		case OpMatchList:
+			count := f.readShort()
+			list, ok := vm.pop().(*interpreter.List)
+			vm.push(ok && len(list.Elements) == count)
+		case OpMatchClass:
+			class, ok := vm.pop().(*Class)
+			if !ok {
+				vm.fail("'%s' in pattern is not a class.", vm.token().Lexeme)
+			}
+			instance, ok := vm.pop().(*Instance)
+			vm.push(ok && instance.IsA(class))
+		case OpNoMatch:
+			vm.fail("No case matches %s.", interpreter.Inspect(vm.pop()))
+
+		default:
+			panic(fmt.Errorf("unknown opcode %d", op))
+		}
+	}
+}
+
+// ret returns from the innermost frame, reporting whether it was the last
+// one.
+func (vm *VM) ret() bool {
+	result := vm.pop()
+	returning := vm.frames[len(vm.frames)-1]
+	vm.closeUpvalues(returning.base)
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.stack = vm.stack[:returning.base]
+	if returning.setter {
+		result = returning.value
+	}
+	if returning.module != nil {
+		result = returning.module
+		vm.modules[returning.module.path] = returning.module
+		vm.importing = vm.importing[:len(vm.importing)-1]
+	}
+	if len(vm.frames) == 0 {
+		return true
+	}
+	vm.push(result)
+	return false
+}
+
+// Calls
+
+// call calls the callee below the top count values, its arguments. Lox
+// functions get a new frame, natives run right away.
+func (vm *VM) call(callee interface{}, count int) {
+	switch callee := callee.(type) {
+	case *Closure:
+		vm.callClosure(callee, count)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-count-1] = callee.Receiver
+		vm.callClosure(callee.Method, count)
+	case *Class:
+		initializer := callee.FindMethod("init")
+		arity := 0
+		if initializer != nil {
+			arity = initializer.Function.Arity
+		}
+		if count != arity {
+			vm.fail("Expected %d arguments but got %d.", arity, count)
+		}
+		vm.stack[len(vm.stack)-count-1] = &Instance{Class: callee, Fields: make(map[string]interface{})}
+		if initializer != nil {
+			vm.callClosure(initializer, count)
+		}
+	case *Native:
+		if callee.Arity >= 0 && count != callee.Arity {
+			vm.fail("Expected %d arguments but got %d.", callee.Arity, count)
+		}
+		arguments := append([]interface{}{}, vm.stack[len(vm.stack)-count:]...)
+		result := callee.fn(vm, vm.token(), arguments)
+		vm.stack = vm.stack[:len(vm.stack)-count-1]
+		vm.push(result)
+	default:
+		vm.fail("Can only call functions and classes.")
+	}
+}
+
+func (vm *VM) callClosure(closure *Closure, count int) *frame {
+	if count != closure.Function.Arity {
+		vm.fail("Expected %d arguments but got %d.", closure.Function.Arity, count)
+	}
+	if len(vm.frames) == maxFrames {
+		vm.fail("Stack overflow.")
+	}
+	vm.frames = append(vm.frames, frame{closure: closure, base: len(vm.stack) - count - 1})
+	return &vm.frames[len(vm.frames)-1]
+}
+
+// Properties and indexing
+
+// getProperty replaces the object on top of the stack with its property.
+// A getter is called with the object as its receiver and its result takes
+// the object's place when it returns.
+func (vm *VM) getProperty(name string) {
+	top := len(vm.stack) - 1
+	switch object := vm.stack[top].(type) {
+	case *Instance:
+		if value, ok := object.Fields[name]; ok {
+			vm.stack[top] = value
+		} else if getter := object.Class.FindGetter(name); getter != nil {
+			vm.callClosure(getter, 0)
+		} else if method := object.Class.FindMethod(name); method != nil {
+			vm.stack[top] = &BoundMethod{Receiver: object, Method: method}
+		} else {
+			vm.fail("Undefined property '%s'.", name)
+		}
+	case *Class:
+		method := object.FindStatic(name)
+		if method == nil {
+			vm.fail("Undefined static method '%s' on class %s.", name, object.Name)
+		}
+		vm.stack[top] = &BoundMethod{Receiver: object, Method: method}
+	case *interpreter.LoxError:
+		vm.stack[top] = object.Get(vm.token())
+	case *Module:
+		vm.stack[top] = object.get(vm.token())
+	default:
+		vm.fail("Only instances have properties.")
+	}
+}
+
+// setProperty stores the value on top of the stack in a property of the
+// object below it, leaving the value.
+func (vm *VM) setProperty(name string) {
+	value := vm.peek(0)
+	instance, ok := vm.peek(1).(*Instance)
+	if !ok {
+		vm.fail("Only instances have fields.")
+	}
+	if setter := instance.Class.FindSetter(name); setter != nil {
+		f := vm.callClosure(setter, 1)
+		f.setter, f.value = true, value
+		return
+	}
+	if instance.Class.FindGetter(name) != nil {
+		vm.fail("Property '%s' has a getter but no setter.", name)
+	}
+	instance.Fields[name] = value
+	vm.pop()
+	vm.stack[len(vm.stack)-1] = value
+}
+
+func (vm *VM) getIndex(object interface{}, index interface{}) interface{} {
+	switch object := object.(type) {
+	case *interpreter.List:
+		return object.Get(vm.token(), index)
+	case *interpreter.Map:
+		return object.Get(vm.token(), index)
+	}
+	vm.fail("Only lists and maps can be indexed.")
+	return nil
+}
+
+func (vm *VM) setIndex(object interface{}, index interface{}, value interface{}) {
+	switch object := object.(type) {
+	case *interpreter.List:
+		object.Set(vm.token(), index, value)
+	case *interpreter.Map:
+		object.Set(vm.token(), index, value)
+	default:
+		vm.fail("Only lists and maps support indexed assignment.")
+	}
+}
+
+// Globals
+
+// declare checks that name may be (re)declared in g, which is not the case
+// if it is already a constant there.
+func (vm *VM) declare(g *globals, name string) {
+	if declaration, ok := g.consts[name]; ok {
+		vm.fail("Cannot redeclare constant '%s' declared at Ln %d, Col %d.", name, declaration.Line, declaration.Col)
+	}
+}
+
+func (vm *VM) getGlobal(g *globals, name string) interface{} {
+	for ; g != nil; g = g.enclosing {
+		if value, ok := g.values[name]; ok {
+			return value
+		}
+	}
+	vm.fail("Undefined variable '%s'.", name)
+	return nil
+}
+
+func (vm *VM) setGlobal(g *globals, name string, value interface{}) {
+	for ; g != nil; g = g.enclosing {
+		if _, ok := g.values[name]; ok {
+			if declaration, ok := g.consts[name]; ok {
+				vm.fail("Cannot assign to constant '%s' declared at Ln %d, Col %d.", name, declaration.Line, declaration.Col)
+			}
+			g.values[name] = value
+			return
+		}
+	}
+	vm.fail("Undefined variable '%s'.", name)
+}
+
+// Upvalues
+
+// capture returns the upvalue for a stack slot, sharing it with the
+// closures that already captured the slot.
+func (vm *VM) capture(slot int) *Upvalue {
+	n := sort.Search(len(vm.open), func(n int) bool { return vm.open[n].slot >= slot })
+	if n < len(vm.open) && vm.open[n].slot == slot {
+		return vm.open[n]
+	}
+	u := &Upvalue{slot: slot}
+	vm.open = append(vm.open, nil)
+	copy(vm.open[n+1:], vm.open[n:])
+	vm.open[n] = u
+	return u
+}
+
+// closeUpvalues moves the values of the slots from slot up into their
+// upvalues, before the slots are popped.
+func (vm *VM) closeUpvalues(slot int) {
+	n := len(vm.open)
+	for ; n > 0 && vm.open[n-1].slot >= slot; n-- {
+		u := vm.open[n-1]
+		u.value, u.closed = vm.stack[u.slot], true
+	}
+	vm.open = vm.open[:n]
+}
+
+func (vm *VM) get(u *Upvalue) interface{} {
+	if u.closed {
+		return u.value
+	}
+	return vm.stack[u.slot]
+}
+
+func (vm *VM) set(u *Upvalue, value interface{}) {
+	if u.closed {
+		u.value = value
+	} else {
+		vm.stack[u.slot] = value
+	}
+}
+
+// Stack and frames
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) interface{} {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (f *frame) readByte() byte {
+	b := f.closure.Function.Chunk.Code[f.ip]
+	f.ip++
+	return b
+}
+
+func (f *frame) readShort() int {
+	code := f.closure.Function.Chunk.Code
+	f.ip += 2
+	return int(code[f.ip-2])<<8 | int(code[f.ip-1])
+}
+
+func (f *frame) readConstant() interface{} {
+	return f.closure.Function.Chunk.Constants[f.readShort()]
+}
+
+func (f *frame) readName() string {
+	return f.readConstant().(string)
+}
+
+// token returns the token of the instruction being executed.
+func (vm *VM) token() token.Token {
+	f := &vm.frames[len(vm.frames)-1]
+	return f.closure.Function.Chunk.Token(f.ip - 1)
+}
+
+// Errors
+
+// fail raises a runtime error at the instruction being executed.
+func (vm *VM) fail(format string, args ...interface{}) {
+	runtimeError(vm.token(), format, args...)
+}
+
+func runtimeError(tok token.Token, format string, args ...interface{}) {
+	panic(&interpreter.RuntimeError{Token: tok, Message: fmt.Sprintf(format, args...)})
+}
+
+// stackTrace describes the call stack, innermost frame first. line is the
+// line being executed in the innermost frame; the callers are at the line
+// of their call.
+func (vm *VM) stackTrace(line int) []string {
+	trace := []string{}
+	for n := len(vm.frames) - 1; n >= 0; n-- {
+		trace = append(trace, fmt.Sprintf("at %s (line %d)", vm.frames[n].closure.Function.Name, line))
+		if n > 0 {
+			caller := &vm.frames[n-1]
+			line = caller.closure.Function.Chunk.Token(caller.ip - 1).Line
+		}
+	}
+	return trace
+}
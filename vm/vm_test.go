@@ -0,0 +1,116 @@
+package vm
+
+import (
+	"Glox/parser"
+	"Glox/resolver"
+	"Glox/scanner"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runFiles writes files to a temporary directory and runs main.lox there,
+// so that it can import the others.
+func runFiles(t *testing.T, files map[string]string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return runFile(t, filepath.Join(dir, "main.lox"), files["main.lox"])
+}
+
+// runFile runs source as the file at path, or as REPL input when path is
+// "".
+func runFile(t *testing.T, path string, source string) (string, error) {
+	t.Helper()
+	s := scanner.NewScanner(source)
+	tokens := s.ScanTokens()
+	p := parser.NewParser(tokens)
+	statements := p.Parse()
+	r := resolver.NewResolver()
+	if len(s.Errors()) == 0 && len(p.Errors()) == 0 {
+		r.Resolve(statements)
+	}
+	if errors := append(append(s.Errors(), p.Errors()...), r.Errors()...); len(errors) > 0 {
+		t.Fatalf("compiling %q: %s", source, strings.Join(errors, "; "))
+	}
+
+	vm := NewVM()
+	if path != "" {
+		vm.SetFile(path)
+	}
+	var err error
+	output := captureStdout(t, func() {
+		err = vm.Interpret(statements)
+	})
+	return output, err
+}
+
+// captureStdout returns what f prints, print statements write to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+	done := make(chan string)
+	go func() {
+		var out bytes.Buffer
+		io.Copy(&out, reader)
+		done <- out.String()
+	}()
+	f()
+	writer.Close()
+	return <-done
+}
+
+func TestModuleIsolation(t *testing.T) {
+	output, err := runFiles(t, map[string]string{
+		"main.lox": `
+var secret = "main";
+import "module.lox" as m;
+print m.count();
+try { m.read(); } catch (e) { print e.message; }
+try { m.write(); } catch (e) { print e.message; }
+print secret;
+`,
+		"module.lox": `
+export fun count() { return len([1, 2, 3]); }
+export fun read() { return secret; }
+export fun write() { secret = "module"; }
+`,
+	})
+	want := "3\nUndefined variable 'secret'.\nUndefined variable 'secret'.\nmain\n"
+	if err != nil || output != want {
+		t.Errorf("got %q, %v, want %q", output, err, want)
+	}
+}
+
+func TestModuleNatives(t *testing.T) {
+	// Shadowing or overwriting a native stays within the file doing it.
+	output, err := runFiles(t, map[string]string{
+		"main.lox": `
+import "module.lox" as m;
+len = nil;
+print m.size("abc");
+`,
+		"module.lox": `
+export fun size(s) { return len(s); }
+clock = nil;
+`,
+	})
+	if err != nil || output != "3\n" {
+		t.Errorf("got %q, %v, want \"3\\n\"", output, err)
+	}
+}